@@ -7,7 +7,23 @@ interface ShortenResponse {
     short_code: string;
     short_url: string;
     slug_type: string;
-    error?: string;
+}
+
+interface ErrorResponse {
+    error: string;
+    code: string;
+    field?: string;
+    docs_url?: string;
+}
+
+function errorMessage(status: number, data: ErrorResponse): string {
+    if (data.field === 'url') {
+        return data.error ? `Invalid URL: ${data.error}.` : ERROR_INVALID_URL;
+    }
+    if (status < 500 && data.error) {
+        return data.error;
+    }
+    return ERROR_GENERIC;
 }
 
 export async function shortenUrl(url: string): Promise<string> {
@@ -17,11 +33,11 @@ export async function shortenUrl(url: string): Promise<string> {
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ url }),
         });
-        const data: ShortenResponse = await res.json();
+        const data = await res.json();
         if (!res.ok) {
-            throw new Error(data.error === 'Invalid URL' ? ERROR_INVALID_URL : ERROR_GENERIC);
+            throw new Error(errorMessage(res.status, data as ErrorResponse));
         }
-        return data.short_url || '';
+        return (data as ShortenResponse).short_url || '';
     } catch (error: any) {
         throw new Error(error.message ?? ERROR_GENERIC);
     }
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"go-url-shortner/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DELETE /api/admin/urls/*shortCode
+func (h *URLHandler) DeleteURL(c *gin.Context) {
+	err := h.urlService.DeleteURL(c.Request.Context(), c.Param("shortCode"))
+	if err != nil {
+		if errors.Is(err, services.ErrLinkNotFound) {
+			h.respondError(c, http.StatusNotFound, CodeNotFound, "short_code", "Short URL not found")
+			return
+		}
+		h.respondServiceError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DELETE /api/admin/tombstones/*shortCode
+func (h *URLHandler) ReleaseCode(c *gin.Context) {
+	if err := h.urlService.ReleaseCode(c.Request.Context(), c.Param("shortCode")); err != nil {
+		h.respondServiceError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GET /api/admin/ai/usage?date=YYYY-MM-DD
+func (h *URLHandler) GetAIUsage(c *gin.Context) {
+	if h.aiUsage == nil {
+		h.respondError(c, http.StatusNotFound, CodeNotFound, "", "AI slug generation is disabled")
+		return
+	}
+
+	day := time.Now()
+	if date := c.Query("date"); date != "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			h.respondError(c, http.StatusBadRequest, CodeInvalidDate, "date", "Date must be formatted as YYYY-MM-DD")
+			return
+		}
+		day = parsed
+	}
+
+	report, err := h.aiUsage.Usage(c.Request.Context(), day)
+	if err != nil {
+		h.respondServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"go-url-shortner/services"
+	"go-url-shortner/utils"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes for failures outside URL validation. Validation failures use
+// the codes of services.ValidationError.
+const (
+	CodeInvalidJSON    = "invalid_json"
+	CodeInvalidURL     = "invalid_url"
+	CodeInvalidAlias   = "invalid_alias"
+	CodeOwnerRequired  = "owner_required"
+	CodeNamespaceOwned = "namespace_owned"
+	CodeAliasTaken     = "alias_taken"
+	CodeThreatDetected = "threat_detected"
+	CodeRedirectLoop   = "redirect_loop"
+	CodeChainTooLong   = "chain_too_long"
+	CodeUnknownLink    = "unknown_short_link"
+	CodeUnreachable    = "destination_unreachable"
+	CodeNotFound       = "not_found"
+	CodeInvalidDate    = "invalid_date"
+	CodeInternal       = "internal_error"
+)
+
+// serviceErrors maps errors returned by the URL service to responses.
+var serviceErrors = []struct {
+	err    error
+	status int
+	code   string
+	field  string
+}{
+	{services.ErrInvalidAlias, http.StatusBadRequest, CodeInvalidAlias, "alias"},
+	{services.ErrOwnerRequired, http.StatusUnauthorized, CodeOwnerRequired, "alias"},
+	{services.ErrNamespaceOwned, http.StatusForbidden, CodeNamespaceOwned, "alias"},
+	{services.ErrAliasTaken, http.StatusConflict, CodeAliasTaken, "alias"},
+	{services.ErrThreatDetected, http.StatusUnprocessableEntity, CodeThreatDetected, "url"},
+	{services.ErrRedirectLoop, http.StatusBadRequest, CodeRedirectLoop, "url"},
+	{services.ErrChainTooLong, http.StatusBadRequest, CodeChainTooLong, "url"},
+	{services.ErrUnknownShortLink, http.StatusBadRequest, CodeUnknownLink, "url"},
+	{services.ErrDestinationUnreachable, http.StatusUnprocessableEntity, CodeUnreachable, "url"},
+	{services.ErrLinkNotFound, http.StatusNotFound, CodeNotFound, "short_code"},
+	{services.ErrNoTombstone, http.StatusNotFound, CodeNotFound, "short_code"},
+}
+
+// respondError writes the standard error envelope.
+func (h *URLHandler) respondError(c *gin.Context, status int, code, field, message string) {
+	c.JSON(status, utils.NewErrorResponse(code, field, message, h.errorDocsURL))
+}
+
+// respondValidationError reports why the URL in field failed validation.
+func (h *URLHandler) respondValidationError(c *gin.Context, field string, err error) {
+	var validationErr *services.ValidationError
+	if errors.As(err, &validationErr) {
+		h.respondError(c, http.StatusBadRequest, validationErr.Code, field, validationErr.Message)
+		return
+	}
+	h.respondError(c, http.StatusBadRequest, CodeInvalidURL, field, err.Error())
+}
+
+// respondServiceError maps an error returned by the URL service.
+func (h *URLHandler) respondServiceError(c *gin.Context, err error) {
+	for _, mapping := range serviceErrors {
+		if errors.Is(err, mapping.err) {
+			h.respondError(c, mapping.status, mapping.code, mapping.field, err.Error())
+			return
+		}
+	}
+
+	var validationErr *services.ValidationError
+	if errors.As(err, &validationErr) {
+		h.respondValidationError(c, "url", err)
+		return
+	}
+
+	// Internal errors may name hosts, keys or queries; only the log sees them
+	log.Printf("Request failed: %v", err)
+	h.respondError(c, http.StatusInternalServerError, CodeInternal, "", "Internal server error")
+}
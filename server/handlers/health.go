@@ -7,8 +7,14 @@ import (
 
 // GET /health requests
 func (h *URLHandler) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"status":  "healthy",
 		"storage": "redis",
-	})
+	}
+	// Links are still created with hash codes while the circuit is open
+	if h.aiCircuit != nil {
+		response["ai"] = gin.H{"circuit": h.aiCircuit.State()}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
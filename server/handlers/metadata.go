@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/urls/*shortCode
+func (h *URLHandler) GetLinkMetadata(c *gin.Context) {
+	metadata, err := h.urlService.GetLinkMetadata(c.Request.Context(), c.Param("shortCode"))
+	if err != nil {
+		h.respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, metadata)
+}
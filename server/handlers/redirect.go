@@ -1,20 +1,88 @@
 package handlers
 
 import (
+	"errors"
+	"go-url-shortner/services"
+	"html/template"
 	"net/http"
+
 	"github.com/gin-gonic/gin"
 )
 
-// GET /:shortCode
+var interstitialTemplate = template.Must(template.New("interstitial").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Warning: suspicious link</title>
+</head>
+<body>
+<h1>This link may be unsafe</h1>
+<p>The destination has been reported as phishing or malware by {{.Reason}}.</p>
+<p>Destination: <code>{{.OriginalURL}}</code></p>
+<p><a href="{{.OriginalURL}}" rel="noopener noreferrer nofollow">Continue anyway</a></p>
+</body>
+</html>
+`))
+
+// The destination is validated against the scheme policy before a link is
+// created, so it is marked as a trusted URL for html/template, which would
+// otherwise refuse schemes such as tel: or myapp:.
+var handoffTemplate = template.Must(template.New("handoff").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>Opening link</title>
+</head>
+<body>
+<h1>Opening link</h1>
+<p>If nothing happens, <a href="{{.}}">open it here</a>.</p>
+<script>window.location.href = {{.}};</script>
+</body>
+</html>
+`))
+
+// GET /:shortCode and GET /:shortCode/*path
 func (h *URLHandler) RedirectToURL(c *gin.Context) {
-	shortCode := c.Param("shortCode")
+	// Multi-segment codes ("eng/oncall") arrive split across both params
+	shortCode := c.Param("shortCode") + c.Param("path")
 
 	originalURL, err := h.urlService.GetOriginalURL(c.Request.Context(), shortCode)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		var flagged *services.FlaggedLinkError
+		if errors.As(err, &flagged) {
+			renderInterstitial(c, flagged)
+			return
+		}
+		h.respondError(c, http.StatusNotFound, CodeNotFound, "short_code", "Short URL not found")
+		return
+	}
+
+	if h.schemes.Handoff(originalURL) {
+		renderHandoff(c, originalURL)
 		return
 	}
 
 	// 301 status code with original URL in Location header
 	c.Redirect(http.StatusMovedPermanently, originalURL)
 }
+
+// renderHandoff opens a destination from an HTML page instead of a redirect.
+func renderHandoff(c *gin.Context, originalURL string) {
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := handoffTemplate.Execute(c.Writer, template.URL(originalURL)); err != nil {
+		c.Error(err)
+	}
+}
+
+// renderInterstitial warns about a flagged destination instead of redirecting.
+func renderInterstitial(c *gin.Context, flagged *services.FlaggedLinkError) {
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := interstitialTemplate.Execute(c.Writer, flagged); err != nil {
+		c.Error(err)
+	}
+}
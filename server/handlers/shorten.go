@@ -11,16 +11,15 @@ import (
 func (h *URLHandler) CreateShortURL(c *gin.Context) {
 	var req services.URLRequest
 
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, CodeInvalidJSON, "", "Invalid JSON")
 		return
 	}
 
 	// Validate and normalize the input URL
-	validator := services.NewURLValidator()
-	normalizedURL, err := validator.NormalizeURL(req.URL)
+	normalizedURL, err := h.validator.NormalizeURLContext(c.Request.Context(), req.URL)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid URL"})
+		h.respondValidationError(c, "url", err)
 		return
 	}
 	// Use normalized URL for further processing
@@ -28,7 +27,7 @@ func (h *URLHandler) CreateShortURL(c *gin.Context) {
 
 	response, err := h.urlService.CreateShortURL(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondServiceError(c, err)
 		return
 	}
 
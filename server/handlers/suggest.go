@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type suggestRequest struct {
+	URL string `json:"url"`
+}
+
+// POST /api/slugs/suggest
+func (h *URLHandler) SuggestSlugs(c *gin.Context) {
+	var req suggestRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.respondError(c, http.StatusBadRequest, CodeInvalidJSON, "", "Invalid JSON")
+		return
+	}
+
+	normalizedURL, err := h.validator.NormalizeURLContext(c.Request.Context(), req.URL)
+	if err != nil {
+		h.respondValidationError(c, "url", err)
+		return
+	}
+
+	suggestions, err := h.urlService.SuggestSlugs(c.Request.Context(), normalizedURL)
+	if err != nil {
+		h.respondServiceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
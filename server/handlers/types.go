@@ -1,15 +1,79 @@
 package handlers
 
 import (
+	"context"
 	"go-url-shortner/services"
+	"time"
 )
 
 type URLHandler struct {
 	urlService services.URLServiceInterface
+	validator  *services.URLValidator
+	schemes    *services.SchemePolicy
+	aiCircuit  CircuitReporter
+	aiUsage    AIUsageReporter
+	// errorDocsURL is the error code reference error responses link to.
+	errorDocsURL string
 }
 
-func NewURLHandler(urlService services.URLServiceInterface) *URLHandler {
-	return &URLHandler{
+// CircuitReporter reports the state of a circuit breaker.
+type CircuitReporter interface {
+	State() string
+}
+
+// AIUsageReporter reports the AI tokens used on a day.
+type AIUsageReporter interface {
+	Usage(ctx context.Context, day time.Time) (*services.AIUsageReport, error)
+}
+
+// HandlerOption configures optional URLHandler dependencies.
+type HandlerOption func(*URLHandler)
+
+// WithValidator replaces the default URL validator, e.g. with one enforcing
+// destination policies.
+func WithValidator(validator *services.URLValidator) HandlerOption {
+	return func(h *URLHandler) {
+		h.validator = validator
+	}
+}
+
+// WithSchemePolicy serves an HTML handoff page instead of a redirect for
+// destinations whose scheme the policy hands off.
+func WithSchemePolicy(policy *services.SchemePolicy) HandlerOption {
+	return func(h *URLHandler) {
+		h.schemes = policy
+	}
+}
+
+// WithAICircuit reports the AI slug circuit breaker state in health checks.
+func WithAICircuit(circuit CircuitReporter) HandlerOption {
+	return func(h *URLHandler) {
+		h.aiCircuit = circuit
+	}
+}
+
+// WithAIUsage serves AI token usage on the admin API.
+func WithAIUsage(reporter AIUsageReporter) HandlerOption {
+	return func(h *URLHandler) {
+		h.aiUsage = reporter
+	}
+}
+
+// WithErrorDocsURL links error responses to the error code reference at url.
+// Without it, or with an empty url, responses carry no link.
+func WithErrorDocsURL(url string) HandlerOption {
+	return func(h *URLHandler) {
+		h.errorDocsURL = url
+	}
+}
+
+func NewURLHandler(urlService services.URLServiceInterface, opts ...HandlerOption) *URLHandler {
+	h := &URLHandler{
 		urlService: urlService,
+		validator:  services.NewURLValidator(),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -14,9 +18,10 @@ import (
 	"go-url-shortner/storage"
 	"go-url-shortner/utils"
 
+	"go-url-shortner/middleware"
+
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-"go-url-shortner/middleware"
 )
 
 func main() {
@@ -27,45 +32,276 @@ func main() {
 
 	// Load configuration
 	cfg := utils.Load()
+	if err := utils.ValidateErrorDocsURL(cfg.ErrorDocsURL); err != nil {
+		log.Fatalf("Invalid ERROR_DOCS_URL: %v", err)
+	}
 
 	// Initialize Redis storage
-	storage, err := storage.NewRedisStorage(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	redisStorage, err := storage.NewRedisStorage(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
 	if err != nil {
 		log.Fatalf("Failed to initialize Redis storage: %v", err)
 	}
-	defer storage.Close()
+	defer redisStorage.Close()
 
 	// Initialize AI service
 	var aiService services.AISlugServiceInterface
-	if cfg.OpenAIAPIKey != "" {
-		aiService = services.NewAISlugService(cfg.OpenAIAPIKey)
-		log.Println("AI slug generation enabled")
+	var handlerOpts []handlers.HandlerOption
+	if cfg.AIAPIKey != "" || cfg.AIBaseURL != "" || cfg.AIProvider == services.ProviderOllama {
+		provider, err := services.NewLLMProvider(services.LLMConfig{
+			Provider:    cfg.AIProvider,
+			APIKey:      cfg.AIAPIKey,
+			BaseURL:     cfg.AIBaseURL,
+			Model:       cfg.AIModel,
+			Temperature: cfg.AITemperature,
+			MaxTokens:   cfg.AIMaxTokens,
+		})
+		if err != nil {
+			log.Fatalf("Invalid AI provider configuration: %v", err)
+		}
+		// Count the tokens each tenant uses and stop at the daily budgets
+		usageConfig := services.UsageConfig{
+			DailyBudget:       int64(cfg.AIDailyTokenBudget),
+			TenantDailyBudget: int64(cfg.AITenantDailyTokenBudget),
+			CompletionReserve: int64(cfg.AIMaxTokens),
+			PromptPrice:       float64(cfg.AIPromptTokenPrice),
+			CompletionPrice:   float64(cfg.AICompletionTokenPrice),
+		}
+		if err := usageConfig.Validate(); err != nil {
+			log.Fatalf("Invalid AI token budgets: %v", err)
+		}
+		usage := services.NewUsageMeter(redisStorage, usageConfig)
+		provider = usage.Wrap(provider)
+		handlerOpts = append(handlerOpts, handlers.WithAIUsage(usage))
+
+		prompts, err := services.LoadSlugPrompts(services.SlugPromptConfig{
+			TemplateFile:        cfg.AIPromptTemplate,
+			Locale:              cfg.AISlugLocale,
+			TenantTemplateFiles: tenantSettings("AI_TENANT_PROMPT_TEMPLATES", cfg.AITenantPromptTemplates),
+			TenantLocales:       tenantSettings("AI_TENANT_SLUG_LOCALES", cfg.AITenantSlugLocales),
+		})
+		if err != nil {
+			log.Fatalf("Invalid AI prompt configuration: %v", err)
+		}
+
+		aiOpts := []services.AISlugOption{
+			services.WithUnicodeSlugOutput(cfg.UnicodeSlugs),
+			services.WithSlugPrompts(prompts),
+			services.WithDescribeMaxTokens(cfg.AIDescribeMaxTokens),
+		}
+		if cfg.PageMetadataFetch {
+			aiOpts = append(aiOpts, services.WithPageMetadata(services.NewPageFetcher(services.PageFetcherConfig{
+				Client:    outboundClient(cfg, cfg.PageMetadataTimeout),
+				MaxBytes:  int64(cfg.PageMetadataMaxBytes),
+				UserAgent: cfg.PageMetadataUserAgent,
+			})))
+		}
+		// Keep a slow or failing provider from stalling link creation
+		resilientAI := services.NewResilientAISlugService(services.NewAISlugServiceWithProvider(provider, aiOpts...), services.ResilienceConfig{
+			Timeout:          cfg.AITimeout,
+			MaxRetries:       cfg.AIMaxRetries,
+			RetryBackoff:     cfg.AIRetryBackoff,
+			FailureThreshold: cfg.AIBreakerThreshold,
+			OpenDuration:     cfg.AIBreakerOpenDuration,
+		})
+		aiService = resilientAI
+		handlerOpts = append(handlerOpts, handlers.WithAICircuit(resilientAI))
+
+		// Reuse slugs generated for the same URL by any instance
+		if cfg.AICacheEnabled {
+			aiService = services.NewCachedAISlugService(aiService, redisStorage, services.AICacheConfig{
+				TTL:            cfg.AICacheTTL,
+				MaxDomainHints: cfg.AICacheDomainHints,
+			})
+		}
+		log.Printf("AI slug generation enabled (%s)", cfg.AIProvider)
 	} else {
 		log.Println("AI slug generation disabled - no API key provided")
 	}
 
+	// Background workers run until the server shuts down
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	urlServiceOpts := []services.URLServiceOption{
+		services.WithUnicodeSlugs(cfg.UnicodeSlugs),
+		services.WithCaseInsensitiveCodes(cfg.CaseInsensitiveCodes),
+		services.WithMetadataStore(redisStorage),
+		services.WithAISlugCandidates(cfg.AICandidates),
+	}
+
+	// Canonicalize destinations before hashing
+	canonicalRules, err := services.ParseCanonicalRules(cfg.CanonicalRules, cfg.TrackingParams)
+	if err != nil {
+		log.Fatalf("Invalid URL_CANONICAL_RULES: %v", err)
+	}
+	urlServiceOpts = append(urlServiceOpts, services.WithCanonicalRules(canonicalRules))
+
+	// Keep retired codes quarantined
+	if cfg.TombstonesEnabled {
+		urlServiceOpts = append(urlServiceOpts, services.WithTombstones(redisStorage, storage.LinkTTL, cfg.CodeQuarantine))
+	}
+
+	// Initialize pre-generated code pool
+	if cfg.CodePoolEnabled {
+		poolConfig := services.CodePoolConfig{
+			LowWatermark:   cfg.CodePoolLowWatermark,
+			TargetSize:     cfg.CodePoolTargetSize,
+			CodeLength:     cfg.CodePoolCodeLength,
+			Mode:           cfg.CodePoolMode,
+			Lowercase:      cfg.CaseInsensitiveCodes,
+			RefillInterval: cfg.CodePoolRefillInterval,
+		}
+		if err := poolConfig.Validate(); err != nil {
+			log.Fatalf("Invalid code pool configuration: %v", err)
+		}
+		pool := services.NewCodePool(redisStorage, redisStorage, poolConfig)
+		go pool.Run(workerCtx)
+		urlServiceOpts = append(urlServiceOpts, services.WithCodePool(pool))
+		log.Printf("Code pool enabled (%s mode)", cfg.CodePoolMode)
+	}
+
+	// Initialize threat feed scanner
+	if len(cfg.ThreatFeedHosts)+len(cfg.ThreatFeedURLhaus)+len(cfg.ThreatFeedHashPrefixes) > 0 {
+		scanner, err := services.NewFeedScanner(services.FeedConfig{
+			HostsFiles:      cfg.ThreatFeedHosts,
+			URLhausFiles:    cfg.ThreatFeedURLhaus,
+			HashPrefixFiles: cfg.ThreatFeedHashPrefixes,
+		})
+		if err != nil {
+			log.Fatalf("Failed to load threat feeds: %v", err)
+		}
+		if err := services.ValidateThreatAction(cfg.ThreatAction); err != nil {
+			log.Fatalf("Invalid THREAT_ACTION: %v", err)
+		}
+		rescanner := services.NewThreatRescanner(scanner, redisStorage, redisStorage)
+		go rescanner.Run(workerCtx, cfg.ThreatRescanInterval)
+		urlServiceOpts = append(urlServiceOpts, services.WithThreatScanner(scanner, redisStorage, cfg.ThreatAction))
+		log.Printf("Threat feed scanning enabled (%s mode)", cfg.ThreatAction)
+	}
+
+	// Initialize URL validator
+	if err := services.ValidateCredentialsPolicy(cfg.CredentialsPolicy); err != nil {
+		log.Fatalf("Invalid URL_CREDENTIALS_POLICY: %v", err)
+	}
+	validatorOpts := []services.ValidatorOption{
+		services.WithCredentialsPolicy(cfg.CredentialsPolicy),
+	}
+	if cfg.SSRFProtection {
+		validatorOpts = append(validatorOpts, services.WithDestinationPolicy(services.DestinationPolicy{
+			Resolver:         net.DefaultResolver,
+			InternalSuffixes: cfg.InternalDomainSuffixes,
+		}))
+	}
+
+	// Initialize domain policy engine
+	var ruleSource services.RuleSource
+	if cfg.PolicyRulesSource == "redis" {
+		ruleSource = redisStorage
+	} else if cfg.PolicyRulesFile != "" {
+		ruleSource = services.FileRuleSource{Path: cfg.PolicyRulesFile}
+	}
+	if ruleSource != nil {
+		policy := services.NewPolicyEngine(ruleSource)
+		if err := policy.Reload(workerCtx); err != nil {
+			log.Fatalf("Failed to load policy rules: %v", err)
+		}
+		go policy.Watch(workerCtx, cfg.PolicyReloadInterval)
+		validatorOpts = append(validatorOpts, services.WithPolicyEngine(policy))
+	}
+	if cfg.HomographAction == services.HomographActionReject {
+		validatorOpts = append(validatorOpts, services.WithHomographCheck())
+	}
+	schemePolicy, err := services.NewSchemePolicy(cfg.AllowedSchemes, cfg.HandoffSchemes)
+	if err != nil {
+		log.Fatalf("Invalid scheme policy: %v", err)
+	}
+	validatorOpts = append(validatorOpts, services.WithSchemePolicy(schemePolicy))
+	validator := services.NewURLValidator(validatorOpts...)
+
+	// Unwrap links to our own and other shorteners
+	chain := services.NewChainResolver(services.ChainResolverConfig{
+		OwnHosts:   ownHosts(cfg),
+		Shorteners: cfg.KnownShorteners,
+		Follow:     cfg.FollowShorteners,
+		MaxHops:    cfg.MaxRedirectHops,
+		Client:     outboundClient(cfg, cfg.ShortenerTimeout),
+		Checker:    validator,
+	})
+	urlServiceOpts = append(urlServiceOpts, services.WithChainResolver(chain))
+
+	// Check destinations are reachable before shortening them
+	if err := services.ValidateReachabilityMode(cfg.ReachabilityCheck); err != nil {
+		log.Fatalf("Invalid REACHABILITY_CHECK: %v", err)
+	}
+	if cfg.ReachabilityCheck != services.ReachabilityOff {
+		checker := services.NewReachabilityChecker(services.ReachabilityConfig{
+			Client:       outboundClient(cfg, cfg.ReachabilityTimeout),
+			MaxRedirects: cfg.ReachabilityMaxRedirects,
+			MaxBodyBytes: int64(cfg.ReachabilityMaxBytes),
+		})
+		urlServiceOpts = append(urlServiceOpts, services.WithReachabilityCheck(checker, cfg.ReachabilityCheck))
+		log.Printf("Reachability check enabled (%s mode)", cfg.ReachabilityCheck)
+	}
+
+	// Return links at once and add AI slugs in the background
+	asyncAI := aiService != nil && cfg.AISlugMode == "async"
+	if asyncAI {
+		upgrader := services.NewSlugUpgrader(services.SlugUpgradeConfig{
+			Workers:    cfg.AIUpgradeWorkers,
+			QueueSize:  cfg.AIUpgradeQueue,
+			WebhookURL: cfg.AIUpgradeWebhook,
+			Client:     &http.Client{Timeout: 5 * time.Second},
+		})
+		urlServiceOpts = append(urlServiceOpts, services.WithAsyncAISlugs(upgrader))
+	}
+
 	// Initialize URL service
-	urlService := services.NewURLService(storage, aiService, cfg.ServerHost, cfg.ServerPort)
+	urlService := services.NewURLService(redisStorage, aiService, cfg.ServerHost, cfg.ServerPort, urlServiceOpts...)
+	upgradesDone := make(chan struct{})
+	if asyncAI {
+		go func() {
+			defer close(upgradesDone)
+			urlService.RunSlugUpgrades(workerCtx)
+		}()
+		log.Printf("Asynchronous AI slugs enabled with %d workers", cfg.AIUpgradeWorkers)
+	}
 
 	// Initialize handlers
-	urlHandler := handlers.NewURLHandler(urlService)
+	handlerOpts = append(handlerOpts,
+		handlers.WithValidator(validator),
+		handlers.WithSchemePolicy(schemePolicy),
+		handlers.WithErrorDocsURL(cfg.ErrorDocsURL),
+	)
+	urlHandler := handlers.NewURLHandler(urlService, handlerOpts...)
 
 	// Setup Gin router
 	router := gin.Default()
 	// Allow frontend origin
 	frontendOrigin := "http://localhost:3000"
 	router.Use(middleware.CORSMiddleware(frontendOrigin))
+	router.Use(middleware.TenantMiddleware())
 
 	// Routes
 	router.POST("/api/urls", urlHandler.CreateShortURL)
-	router.GET("/:shortCode", urlHandler.RedirectToURL)
+	router.GET("/api/urls/*shortCode", urlHandler.GetLinkMetadata)
+	router.POST("/api/slugs/suggest", urlHandler.SuggestSlugs)
 	router.GET("/health", urlHandler.HealthCheck)
+	router.GET("/metrics", gin.WrapH(expvar.Handler()))
+
+	admin := router.Group("/api/admin", middleware.AdminAuthMiddleware(cfg.AdminToken, cfg.ErrorDocsURL))
+	admin.DELETE("/urls/*shortCode", urlHandler.DeleteURL)
+	admin.DELETE("/tombstones/*shortCode", urlHandler.ReleaseCode)
+	admin.GET("/ai/usage", urlHandler.GetAIUsage)
+	// Static routes above take precedence; everything else is a short code,
+	// including multi-segment ones such as /eng/oncall
+	router.GET("/:shortCode", urlHandler.RedirectToURL)
+	router.GET("/:shortCode/*path", urlHandler.RedirectToURL)
 
 	// Create HTTP server
-       server := &http.Server{
-	       Addr:    "0.0.0.0:" + cfg.ServerPort,
-	       Handler: router,
-       }
+	server := &http.Server{
+		Addr:    "0.0.0.0:" + cfg.ServerPort,
+		Handler: router,
+	}
 
 	// Start server in a goroutine
 	go func() {
@@ -81,13 +317,54 @@ func main() {
 	<-quit
 	log.Println("Shutting down server...")
 
-	// Give outstanding requests a deadline for completion
+	// Give outstanding requests a deadline for completion. Workers run until
+	// then, since requests may still queue slug upgrades.
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+
+	stopWorkers()
+	if asyncAI {
+		// Let the upgrade workers finish and fail the upgrades still queued
+		<-upgradesDone
 	}
 
 	log.Println("Server exited")
 }
+
+// ownHosts lists the hosts our short links are served from.
+func ownHosts(cfg *utils.Config) []string {
+	hosts := []string{net.JoinHostPort(cfg.ServerHost, cfg.ServerPort)}
+	if cfg.ServerPort == "80" || cfg.ServerPort == "443" {
+		hosts = append(hosts, cfg.ServerHost)
+	}
+	if base, err := url.Parse(cfg.BaseURL); err == nil && base.Host != "" {
+		hosts = append(hosts, base.Host)
+	}
+	return hosts
+}
+
+// tenantSettings reads "tenant=value" entries of the list setting name.
+func tenantSettings(name string, entries []string) map[string]string {
+	settings := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		tenant, value, ok := strings.Cut(entry, "=")
+		if !ok || tenant == "" || value == "" {
+			log.Fatalf("Invalid %s entry %q, expected tenant=value", name, entry)
+		}
+		settings[strings.TrimSpace(tenant)] = strings.TrimSpace(value)
+	}
+	return settings
+}
+
+// outboundClient returns the client for requests to user-supplied URLs,
+// refusing internal addresses unless SSRF protection is off.
+func outboundClient(cfg *utils.Config, timeout time.Duration) *http.Client {
+	if cfg.SSRFProtection {
+		return services.NewSafeHTTPClient(timeout)
+	}
+	return &http.Client{Timeout: timeout}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"go-url-shortner/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes of requests refused by AdminAuthMiddleware.
+const (
+	CodeAdminDisabled     = "admin_disabled"
+	CodeInvalidAdminToken = "invalid_admin_token"
+)
+
+// AdminAuthMiddleware only lets requests through that carry the configured
+// token as "Authorization: Bearer <token>". Admin routes are disabled when no
+// token is configured. Refusals link to the error reference at docsURL.
+func AdminAuthMiddleware(token, docsURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, utils.NewErrorResponse(CodeAdminDisabled, "", "Admin API is disabled", docsURL))
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, utils.NewErrorResponse(CodeInvalidAdminToken, "Authorization", "Invalid admin token", docsURL))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"go-url-shortner/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantMiddleware identifies callers by their X-API-Key header. The key is
+// hashed into a stable tenant ID so raw keys never reach storage or logs.
+// Requests without a key are treated as anonymous.
+func TenantMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			ctx := services.ContextWithTenant(c.Request.Context(), TenantID(apiKey))
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	}
+}
+
+// TenantID derives the tenant ID for an API key.
+func TenantID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
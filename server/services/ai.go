@@ -2,91 +2,212 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"go-url-shortner/utils"
 	"log"
 	"regexp"
 	"strings"
-	"github.com/sashabaranov/go-openai"
 )
 
 type AISlugService struct {
-	client *openai.Client
+	provider          LLMProvider
+	unicodeSlugs      bool
+	pages             PageMetadataFetcher
+	prompts           *SlugPrompts
+	describeMaxTokens int
 }
 
-func NewAISlugService(apiKey string) *AISlugService {
+// AISlugOption configures optional AISlugService behaviour.
+type AISlugOption func(*AISlugService)
+
+// WithUnicodeSlugOutput keeps non-ASCII letters in generated slugs instead of
+// stripping them, so pages in other languages get native-script slugs.
+func WithUnicodeSlugOutput(enabled bool) AISlugOption {
+	return func(s *AISlugService) {
+		s.unicodeSlugs = enabled
+	}
+}
+
+// WithPageMetadata adds the destination page's title and description to the
+// prompt. Pages that cannot be fetched are described by their URL alone.
+func WithPageMetadata(fetcher PageMetadataFetcher) AISlugOption {
+	return func(s *AISlugService) {
+		s.pages = fetcher
+	}
+}
+
+// WithSlugPrompts renders prompts from the configured templates, picking the
+// template and slug language by tenant.
+func WithSlugPrompts(prompts *SlugPrompts) AISlugOption {
+	return func(s *AISlugService) {
+		s.prompts = prompts
+	}
+}
+
+// NewAISlugService generates slugs with OpenAI's default model.
+func NewAISlugService(apiKey string, opts ...AISlugOption) *AISlugService {
 	if apiKey == "" {
 		return nil
 	}
 
-	client := openai.NewClient(apiKey)
-	return &AISlugService{
-		client: client,
+	provider, _ := NewLLMProvider(LLMConfig{
+		Provider:    ProviderOpenAI,
+		APIKey:      apiKey,
+		Temperature: 0.7,
+		MaxTokens:   128,
+	})
+	return NewAISlugServiceWithProvider(provider, opts...)
+}
+
+// NewAISlugServiceWithProvider generates slugs with any LLM backend.
+func NewAISlugServiceWithProvider(provider LLMProvider, opts ...AISlugOption) *AISlugService {
+	if provider == nil {
+		return nil
+	}
+
+	s := &AISlugService{
+		provider:          provider,
+		describeMaxTokens: DefaultDescribeMaxTokens,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Generates an AI-powered slug for a given URL
 func (s *AISlugService) GenerateSlug(ctx context.Context, originalURL string) (string, error) {
-	if s.client == nil {
-		return "", fmt.Errorf("OpenAI client not initialized")
-	}
-
-	// Extract domain and path from URL for better context
-	domain := extractDomain(originalURL)
-
-	prompt := fmt.Sprintf(`Generate exactly one short, catchy, and memorable URL slug for this website:
-	URL: %s
-	Domain: %s
-
-	Requirements:
-	- 3 to 8 characters
-	- Memorable and relevant to the website's name or purpose
-	- Use only lowercase letters, numbers, and hyphens
-	- No spaces, underscores, or special characters
-	- Avoid generic or overused slugs
-
-	Examples:
-	- For "https://best-books-lover.com/good-books/book" -> "bestbook" or "gbooks"
-	- For "https://travel-tips-expert.com/top-destinations/2025" -> "travexp" or "topdest"
-
-	Output:
-	Only return the slug itself with no explanation or formatting.`, originalURL, domain)
-
-	resp, err := s.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: openai.GPT3Dot5Turbo,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			MaxTokens:   3,
-			Temperature: 0.7,
-		},
-	)
+	if s.provider == nil {
+		return "", fmt.Errorf("LLM provider not initialized")
+	}
 
+	prompt, err := s.slugPrompt(ctx, originalURL, 1)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate AI slug: %w", err)
+		return "", err
 	}
 
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("no response from OpenAI")
+	completion, err := s.provider.Complete(ctx, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate AI slug: %w", err)
 	}
 
-	slug := strings.TrimSpace(resp.Choices[0].Message.Content)
+	slug := strings.TrimSpace(completion.Text)
 
 	// Clean and validate the slug
-	cleanSlug := cleanSlug(slug)
+	cleanSlug := cleanSlug(slug, s.unicodeSlugs)
 
 	if cleanSlug == "" {
 		return "", fmt.Errorf("generated slug is empty after cleaning")
 	}
 
-	log.Printf("AI generated slug '%s' for URL: %s", cleanSlug, originalURL)
+	log.Printf("AI generated slug '%s' for URL: %s", cleanSlug, utils.RedactURL(originalURL))
 	return cleanSlug, nil
 }
 
+// GenerateSlugCandidates asks for n slugs in one call and returns the usable
+// ones, best first.
+func (s *AISlugService) GenerateSlugCandidates(ctx context.Context, originalURL string, n int) ([]string, error) {
+	if s.provider == nil {
+		return nil, fmt.Errorf("LLM provider not initialized")
+	}
+	if n <= 1 {
+		slug, err := s.GenerateSlug(ctx, originalURL)
+		if err != nil {
+			return nil, err
+		}
+		return []string{slug}, nil
+	}
+
+	prompt, err := s.slugPrompt(ctx, originalURL, n)
+	if err != nil {
+		return nil, err
+	}
+
+	completion, err := s.provider.Complete(ctx, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate AI slugs: %w", err)
+	}
+
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, raw := range parseSlugList(completion.Text) {
+		slug := cleanSlug(raw, s.unicodeSlugs)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		candidates = append(candidates, slug)
+		if len(candidates) == n {
+			break
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no usable slugs in AI response")
+	}
+
+	log.Printf("AI generated slugs %v for URL: %s", candidates, utils.RedactURL(originalURL))
+	return candidates, nil
+}
+
+// slugPrompt asks for count slugs for originalURL using the caller's tenant
+// template: a bare slug when count is one and a JSON array otherwise.
+func (s *AISlugService) slugPrompt(ctx context.Context, originalURL string, count int) (string, error) {
+	tmpl, locale := s.prompts.forTenant(TenantFromContext(ctx))
+	data := SlugPromptData{
+		URL:       originalURL,
+		Domain:    extractDomain(originalURL),
+		Hints:     SlugHintsFromContext(ctx),
+		Locale:    locale,
+		Unicode:   s.unicodeSlugs,
+		Count:     count,
+		MinLength: MinSlugLength,
+		MaxLength: MaxSlugLength,
+	}
+	if page := s.pageMetadata(ctx, originalURL); page != nil {
+		data.Title = firstNonEmpty(page.OGTitle, page.Title)
+		data.Description = page.Description
+	}
+
+	var prompt strings.Builder
+	if err := tmpl.Execute(&prompt, data); err != nil {
+		return "", fmt.Errorf("failed to render slug prompt: %w", err)
+	}
+	return prompt.String(), nil
+}
+
+// parseSlugList reads the JSON array in completion. Models that ignore the
+// format and list slugs one per line or comma-separated are understood too.
+func parseSlugList(completion string) []string {
+	start, end := strings.Index(completion, "["), strings.LastIndex(completion, "]")
+	if start >= 0 && end > start {
+		var slugs []string
+		if err := json.Unmarshal([]byte(completion[start:end+1]), &slugs); err == nil {
+			return slugs
+		}
+	}
+
+	return strings.FieldsFunc(completion, func(r rune) bool {
+		return r == '\n' || r == ','
+	})
+}
+
+// pageMetadata describes the page at originalURL, or returns nil when no
+// fetcher is configured or the page can't be read.
+func (s *AISlugService) pageMetadata(ctx context.Context, originalURL string) *PageMetadata {
+	if s.pages == nil {
+		return nil
+	}
+
+	page, err := s.pages.Fetch(ctx, originalURL)
+	if err != nil {
+		log.Printf("Page metadata unavailable for %s: %v", utils.RedactURL(originalURL), err)
+		return nil
+	}
+	return page
+}
+
 // Extracts the domain from a URL
 func extractDomain(url string) string {
 	if strings.HasPrefix(url, "http://") {
@@ -102,21 +223,37 @@ func extractDomain(url string) string {
 	return url
 }
 
-func cleanSlug(slug string) string {
+func cleanSlug(slug string, allowUnicode bool) string {
 	// Remove any non-alphanumeric characters except hyphens
 	re := regexp.MustCompile(`[^a-z0-9-]`)
+	if allowUnicode {
+		re = regexp.MustCompile(`[^\p{L}\p{M}\p{N}-]`)
+		slug = utils.NormalizeNFC(slug)
+	}
 	clean := re.ReplaceAllString(strings.ToLower(slug), "")
 
 	// Remove leading/trailing hyphens
 	clean = strings.Trim(clean, "-")
 
-	// Ensure it's not too long
-	if len(clean) > 8 {
-		clean = clean[:8]
+	// Ensure it's not too long, counting characters rather than bytes
+	runes := []rune(clean)
+	if len(runes) > MaxSlugLength {
+		clean = strings.TrimRight(string(runes[:MaxSlugLength]), "-")
+		runes = []rune(clean)
 	}
 
 	// Ensure it's not too short
-	if len(clean) < 3 {
+	if len(runes) < MinSlugLength {
+		return ""
+	}
+
+	// Lookalike slugs could impersonate other codes
+	if allowUnicode && (utils.IsMixedScript(clean) || utils.IsWholeScriptConfusable(clean)) {
+		return ""
+	}
+
+	// Static routes would shadow the slug
+	if isReservedCode(clean) {
 		return ""
 	}
 
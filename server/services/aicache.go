@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var aiCacheMetrics = expvar.NewMap("ai_cache")
+
+type AICacheConfig struct {
+	// TTL is how long generated slugs are reused.
+	TTL time.Duration
+	// MaxDomainHints bounds how many recent slugs are kept per domain.
+	MaxDomainHints int
+}
+
+// CachedAISlugService reuses AI slugs generated for the same canonical URL
+// and passes slugs generated for the same domain to the AI as hints. The
+// cache lives in shared storage so every instance benefits. Entries are kept
+// per tenant, since tenants may have their own prompt template and locale and
+// their slugs should not hint at each other's links.
+type CachedAISlugService struct {
+	next     AISlugServiceInterface
+	store    AICacheStore
+	ttl      time.Duration
+	maxHints int
+}
+
+// aiCacheEntry records the slugs generated for a URL and how many were asked
+// for, so a request for more candidates than were generated is a miss.
+type aiCacheEntry struct {
+	Requested int      `json:"requested"`
+	Slugs     []string `json:"slugs"`
+}
+
+func NewCachedAISlugService(next AISlugServiceInterface, store AICacheStore, config AICacheConfig) *CachedAISlugService {
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.MaxDomainHints <= 0 {
+		config.MaxDomainHints = 10
+	}
+	return &CachedAISlugService{next: next, store: store, ttl: config.TTL, maxHints: config.MaxDomainHints}
+}
+
+func (c *CachedAISlugService) GenerateSlug(ctx context.Context, originalURL string) (string, error) {
+	slugs, err := c.GenerateSlugCandidates(ctx, originalURL, 1)
+	if err != nil || len(slugs) == 0 {
+		return "", err
+	}
+	return slugs[0], nil
+}
+
+func (c *CachedAISlugService) GenerateSlugCandidates(ctx context.Context, originalURL string, n int) ([]string, error) {
+	if n < 1 {
+		n = 1
+	}
+
+	scope := cacheScope(ctx)
+	urlKey := cacheURLKey(scope, originalURL)
+	var entry aiCacheEntry
+	if c.load(ctx, urlKey, &entry) && entry.Requested >= n && len(entry.Slugs) > 0 {
+		aiCacheMetrics.Add("hits", 1)
+		return limitSlugs(entry.Slugs, n), nil
+	}
+	aiCacheMetrics.Add("misses", 1)
+
+	domainKey := "domain:" + scope + cacheDomain(originalURL)
+	var hints []string
+	c.load(ctx, domainKey, &hints)
+
+	slugs, err := c.generate(ContextWithSlugHints(ctx, hints), originalURL, n)
+	if err != nil {
+		return nil, err
+	}
+
+	c.save(ctx, urlKey, aiCacheEntry{Requested: n, Slugs: slugs})
+	c.save(ctx, domainKey, mergeHints(slugs, hints, c.maxHints))
+	return slugs, nil
+}
+
+// ForgetSlugs drops the slugs cached for originalURL, so the next request
+// asks the AI again instead of reusing slugs that are all taken. Domain
+// hints are kept.
+func (c *CachedAISlugService) ForgetSlugs(ctx context.Context, originalURL string) error {
+	if err := c.store.DeleteAICache(ctx, cacheURLKey(cacheScope(ctx), originalURL)); err != nil {
+		return err
+	}
+	aiCacheMetrics.Add("forgotten", 1)
+	return nil
+}
+
+// DescribeLink is not cached; descriptions are stored with the link.
+func (c *CachedAISlugService) DescribeLink(ctx context.Context, originalURL string) (*LinkDescription, error) {
+	describer, ok := c.next.(AILinkDescriber)
+	if !ok {
+		return nil, ErrDescriptionsUnsupported
+	}
+	return describer.DescribeLink(ctx, originalURL)
+}
+
+func (c *CachedAISlugService) generate(ctx context.Context, originalURL string, n int) ([]string, error) {
+	if generator, ok := c.next.(AISlugCandidateGenerator); ok && n > 1 {
+		return generator.GenerateSlugCandidates(ctx, originalURL, n)
+	}
+
+	slug, err := c.next.GenerateSlug(ctx, originalURL)
+	if err != nil {
+		return nil, err
+	}
+	return []string{slug}, nil
+}
+
+// load decodes the cached value of key into out and reports whether there
+// was one. Cache failures are logged and treated as misses.
+func (c *CachedAISlugService) load(ctx context.Context, key string, out interface{}) bool {
+	raw, found, err := c.store.GetAICache(ctx, key)
+	if err != nil {
+		log.Printf("Failed to read AI cache: %v", err)
+		return false
+	}
+	if !found {
+		return false
+	}
+	return json.Unmarshal(raw, out) == nil
+}
+
+func (c *CachedAISlugService) save(ctx context.Context, key string, value interface{}) {
+	raw, err := json.Marshal(value)
+	if err == nil {
+		err = c.store.StoreAICache(ctx, key, raw, c.ttl)
+	}
+	if err != nil {
+		log.Printf("Failed to write AI cache: %v", err)
+	}
+}
+
+// hashCacheKey keeps cache keys short whatever the URL length.
+func hashCacheKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func cacheURLKey(scope, originalURL string) string {
+	return "url:" + scope + hashCacheKey(originalURL)
+}
+
+// cacheScope prefixes cache keys with the caller's tenant.
+func cacheScope(ctx context.Context) string {
+	tenant := TenantFromContext(ctx)
+	if tenant == "" {
+		tenant = AnonymousTenant
+	}
+	return tenant + ":"
+}
+
+func cacheDomain(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return strings.ToLower(extractDomain(rawURL))
+}
+
+// mergeHints puts the newest slugs first and keeps at most limit.
+func mergeHints(slugs, hints []string, limit int) []string {
+	merged := make([]string, 0, limit)
+	seen := make(map[string]bool)
+	for _, slug := range append(append([]string{}, slugs...), hints...) {
+		if seen[slug] || len(merged) == limit {
+			continue
+		}
+		seen[slug] = true
+		merged = append(merged, slug)
+	}
+	return merged
+}
+
+func limitSlugs(slugs []string, n int) []string {
+	if len(slugs) > n {
+		return slugs[:n]
+	}
+	return slugs
+}
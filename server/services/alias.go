@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-url-shortner/utils"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidAlias    = errors.New("invalid alias")
+	ErrAliasTaken      = errors.New("alias already in use")
+	ErrNamespaceOwned  = errors.New("namespace is owned by another tenant")
+	ErrOwnerRequired   = errors.New("namespaced aliases require an API key")
+	aliasSegmentRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+const (
+	maxAliasSegments = 3
+	maxAliasLength   = 64
+)
+
+// First path segments served by static routes. Codes starting with them
+// would never be reached by RedirectToURL, so they cannot be claimed.
+var reservedSegments = map[string]bool{
+	"api":     true,
+	"health":  true,
+	"metrics": true,
+}
+
+// isReservedCode reports whether code starts with a segment served by a
+// static route. Generated codes and AI slugs are checked the same way as
+// aliases.
+func isReservedCode(code string) bool {
+	segment, _, _ := strings.Cut(code, "/")
+	return reservedSegments[strings.ToLower(segment)]
+}
+
+// ParseAlias validates a custom alias such as "oncall" or "eng/oncall" and
+// returns it without surrounding slashes, along with its namespace (the first
+// segment of a multi-segment alias, empty otherwise). With allowUnicode set,
+// segments may also contain letters from any script and emoji; the alias is
+// then returned in NFC form.
+func ParseAlias(alias string, allowUnicode bool) (code, namespace string, err error) {
+	code = strings.Trim(alias, "/")
+	if allowUnicode {
+		code = utils.NormalizeNFC(code)
+	}
+	if code == "" {
+		return "", "", fmt.Errorf("%w: alias is empty", ErrInvalidAlias)
+	}
+	if utf8.RuneCountInString(code) > maxAliasLength {
+		return "", "", fmt.Errorf("%w: alias exceeds %d characters", ErrInvalidAlias, maxAliasLength)
+	}
+
+	segments := strings.Split(code, "/")
+	if len(segments) > maxAliasSegments {
+		return "", "", fmt.Errorf("%w: alias has more than %d segments", ErrInvalidAlias, maxAliasSegments)
+	}
+
+	for _, segment := range segments {
+		if allowUnicode {
+			if err := validateUnicodeSegment(segment); err != nil {
+				return "", "", err
+			}
+		} else if !aliasSegmentRegexp.MatchString(segment) {
+			return "", "", fmt.Errorf("%w: segment %q may only contain letters, numbers, hyphens and underscores", ErrInvalidAlias, segment)
+		}
+	}
+
+	if isReservedCode(code) {
+		return "", "", fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, segments[0])
+	}
+
+	if len(segments) > 1 {
+		namespace = segments[0]
+	}
+
+	return code, namespace, nil
+}
+
+// validateUnicodeSegment accepts letters, marks and numbers from any script
+// plus emoji sequences, and rejects segments that could impersonate another
+// code: mixed scripts, whole-script lookalikes, compatibility characters and
+// invisible formatting characters.
+func validateUnicodeSegment(segment string) error {
+	if segment == "" {
+		return fmt.Errorf("%w: alias contains an empty segment", ErrInvalidAlias)
+	}
+
+	var prev rune
+	for _, r := range segment {
+		switch {
+		case unicode.IsLetter(r), unicode.IsMark(r), unicode.IsNumber(r), r == '-', r == '_':
+		case isEmojiRune(r):
+		case r == '\u200d' && isEmojiRune(prev):
+			// Zero-width joiner inside an emoji sequence such as 👩‍💻
+		default:
+			return fmt.Errorf("%w: segment %q contains unsupported character %U", ErrInvalidAlias, segment, r)
+		}
+		prev = r
+	}
+
+	if utils.HasCompatibilityChars(segment) {
+		return fmt.Errorf("%w: segment %q contains compatibility characters", ErrInvalidAlias, segment)
+	}
+	if utils.IsMixedScript(segment) {
+		return fmt.Errorf("%w: segment %q mixes scripts", ErrInvalidAlias, segment)
+	}
+	if utils.IsWholeScriptConfusable(segment) {
+		return fmt.Errorf("%w: segment %q is confusable with %q", ErrInvalidAlias, segment, utils.ConfusableSkeleton(segment))
+	}
+
+	return nil
+}
+
+func isEmojiRune(r rune) bool {
+	return unicode.Is(unicode.So, r) ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) || // skin tone modifiers
+		r == 0xFE0F || r == 0x20E3 // emoji presentation selector, keycap
+}
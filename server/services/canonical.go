@@ -0,0 +1,289 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Canonicalization rule names, as used in configuration.
+const (
+	RuleLowercase       = "lowercase"
+	RuleDefaultPort     = "default_port"
+	RuleDotSegments     = "dot_segments"
+	RulePercentEncoding = "percent_encoding"
+	RuleSortQuery       = "sort_query"
+	RuleStripTracking   = "strip_tracking"
+)
+
+// CanonicalRules selects the normalizations applied to destinations before
+// they are hashed and stored. The first four follow RFC 3986 section 6.2.2
+// and never change what a URL points at; sorting the query and stripping
+// tracking parameters usually don't either, but are opt-in.
+type CanonicalRules struct {
+	Lowercase       bool
+	DefaultPort     bool
+	DotSegments     bool
+	PercentEncoding bool
+	SortQuery       bool
+	StripTracking   bool
+	// TrackingParams lists query parameters to strip; a trailing "*"
+	// matches any suffix, e.g. "utm_*".
+	TrackingParams []string
+}
+
+// DefaultTrackingParams are stripped when no other list is configured.
+var DefaultTrackingParams = []string{"utm_*", "fbclid"}
+
+// ParseCanonicalRules builds CanonicalRules from a list of rule names. The
+// name "none" enables nothing and allows turning every rule off.
+func ParseCanonicalRules(names, trackingParams []string) (CanonicalRules, error) {
+	rules := CanonicalRules{TrackingParams: trackingParams}
+	if len(rules.TrackingParams) == 0 {
+		rules.TrackingParams = DefaultTrackingParams
+	}
+
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "none":
+		case RuleLowercase:
+			rules.Lowercase = true
+		case RuleDefaultPort:
+			rules.DefaultPort = true
+		case RuleDotSegments:
+			rules.DotSegments = true
+		case RulePercentEncoding:
+			rules.PercentEncoding = true
+		case RuleSortQuery:
+			rules.SortQuery = true
+		case RuleStripTracking:
+			rules.StripTracking = true
+		default:
+			return CanonicalRules{}, fmt.Errorf("unknown canonicalization rule %q", name)
+		}
+	}
+	return rules, nil
+}
+
+// WithCanonicalRules canonicalizes destinations before they are hashed and
+// stored, so equivalent spellings of a URL share one short code.
+func WithCanonicalRules(rules CanonicalRules) URLServiceOption {
+	return func(s *URLService) {
+		s.canonicalRules = &rules
+	}
+}
+
+// canonicalize applies the service's canonicalization rules, if any.
+func (s *URLService) canonicalize(rawURL string) (string, error) {
+	if s.canonicalRules == nil {
+		return rawURL, nil
+	}
+	return Canonicalize(rawURL, *s.canonicalRules)
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Canonicalize rewrites rawURL according to rules. It works on the escaped
+// form of each component, so reserved characters keep their meaning.
+func Canonicalize(rawURL string, rules CanonicalRules) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL format: %w", err)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return rawURL, nil
+	}
+
+	scheme := u.Scheme
+	host := u.Host
+	if rules.Lowercase {
+		scheme = strings.ToLower(scheme)
+		host = strings.ToLower(host)
+	}
+	if rules.DefaultPort {
+		if port := u.Port(); port != "" && defaultPorts[strings.ToLower(scheme)] == port {
+			host = strings.TrimSuffix(host, ":"+port)
+		}
+	}
+
+	path := u.EscapedPath()
+	query := u.RawQuery
+	fragment := u.EscapedFragment()
+
+	if rules.PercentEncoding {
+		path = normalizePercentEncoding(path)
+		query = normalizePercentEncoding(query)
+		fragment = normalizePercentEncoding(fragment)
+	}
+	if rules.DotSegments {
+		path = removeDotSegments(path)
+	}
+	if rules.StripTracking || rules.SortQuery {
+		query = canonicalQuery(query, rules)
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme)
+	b.WriteString("://")
+	if u.User != nil {
+		b.WriteString(u.User.String())
+		b.WriteString("@")
+	}
+	b.WriteString(host)
+	b.WriteString(path)
+	if query != "" || (u.ForceQuery && !rules.StripTracking) {
+		b.WriteString("?")
+		b.WriteString(query)
+	}
+	if fragment != "" {
+		b.WriteString("#")
+		b.WriteString(fragment)
+	}
+	return b.String(), nil
+}
+
+// normalizePercentEncoding decodes escaped unreserved characters and
+// uppercases the hex digits of all other escapes (RFC 3986 6.2.2.1-2).
+func normalizePercentEncoding(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteString(strings.ToUpper(s[i : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// removeDotSegments implements the algorithm of RFC 3986 section 5.2.4.
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	var output []string
+	input := path
+	for input != "" {
+		switch {
+		case strings.HasPrefix(input, "../"):
+			input = input[3:]
+		case strings.HasPrefix(input, "./"):
+			input = input[2:]
+		case strings.HasPrefix(input, "/./"):
+			input = input[2:]
+		case input == "/.":
+			input = "/"
+		case strings.HasPrefix(input, "/../"):
+			input = input[3:]
+			if len(output) > 0 {
+				output = output[:len(output)-1]
+			}
+		case input == "/..":
+			input = "/"
+			if len(output) > 0 {
+				output = output[:len(output)-1]
+			}
+		case input == "." || input == "..":
+			input = ""
+		default:
+			start := 0
+			if input[0] == '/' {
+				start = 1
+			}
+			end := strings.Index(input[start:], "/")
+			if end == -1 {
+				end = len(input)
+			} else {
+				end += start
+			}
+			output = append(output, input[:end])
+			input = input[end:]
+		}
+	}
+	return strings.Join(output, "")
+}
+
+// canonicalQuery strips tracking parameters and sorts the remaining ones by
+// key, keeping the order of repeated keys.
+func canonicalQuery(query string, rules CanonicalRules) string {
+	if query == "" {
+		return query
+	}
+
+	var params []string
+	for _, param := range strings.Split(query, "&") {
+		if param == "" {
+			continue
+		}
+		if rules.StripTracking && isTrackingParam(queryKey(param), rules.TrackingParams) {
+			continue
+		}
+		params = append(params, param)
+	}
+
+	if rules.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			return queryKey(params[i]) < queryKey(params[j])
+		})
+	}
+	return strings.Join(params, "&")
+}
+
+func queryKey(param string) string {
+	key, _, _ := strings.Cut(param, "=")
+	if unescaped, err := url.QueryUnescape(key); err == nil {
+		return unescaped
+	}
+	return key
+}
+
+func isTrackingParam(key string, patterns []string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import "context"
+
+type contextKey string
+
+const (
+	tenantContextKey    contextKey = "tenant"
+	slugHintsContextKey contextKey = "slug_hints"
+	maxTokensContextKey contextKey = "max_tokens"
+)
+
+// ContextWithTenant returns a copy of ctx carrying the caller's tenant ID.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey, tenant)
+}
+
+// TenantFromContext returns the tenant ID stored on ctx, or "" for anonymous callers.
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey).(string)
+	return tenant
+}
+
+// ContextWithSlugHints returns a copy of ctx carrying slugs suggested earlier
+// for the same domain, which AI slug generation uses as examples to avoid.
+func ContextWithSlugHints(ctx context.Context, hints []string) context.Context {
+	return context.WithValue(ctx, slugHintsContextKey, hints)
+}
+
+// SlugHintsFromContext returns the slug hints stored on ctx.
+func SlugHintsFromContext(ctx context.Context) []string {
+	hints, _ := ctx.Value(slugHintsContextKey).([]string)
+	return hints
+}
+
+// ContextWithMaxTokens returns a copy of ctx carrying how many tokens a
+// completion may return, overriding the provider's configured limit for calls
+// that need longer output.
+func ContextWithMaxTokens(ctx context.Context, maxTokens int) context.Context {
+	return context.WithValue(ctx, maxTokensContextKey, maxTokens)
+}
+
+// MaxTokensFromContext returns the completion limit stored on ctx, or 0.
+func MaxTokensFromContext(ctx context.Context) int {
+	maxTokens, _ := ctx.Value(maxTokensContextKey).(int)
+	return maxTokens
+}
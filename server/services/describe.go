@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"go-url-shortner/utils"
+)
+
+// Limits of AI link descriptions. Longer output is cut, not rejected.
+const (
+	MaxSummaryLength = 160
+	MaxTags          = 5
+	MaxTagLength     = 24
+	minTagLength     = 2
+
+	// DefaultDescribeMaxTokens leaves room for a full summary and tags in
+	// scripts that take several tokens per character.
+	DefaultDescribeMaxTokens = 400
+)
+
+var (
+	ErrDescriptionsUnsupported = errors.New("the AI service cannot describe links")
+
+	markupPattern = regexp.MustCompile(`<[^>]*>`)
+	tagPattern    = regexp.MustCompile(`[^\p{L}\p{M}\p{N}-]`)
+)
+
+// LinkDescription is a one-line summary of a destination and topical tags
+// that make it searchable.
+type LinkDescription struct {
+	Summary string   `json:"summary"`
+	Tags    []string `json:"tags"`
+}
+
+// WithDescribeMaxTokens sets how many tokens a link description may use,
+// separately from the provider's limit for slugs, so the JSON is not cut off.
+func WithDescribeMaxTokens(maxTokens int) AISlugOption {
+	return func(s *AISlugService) {
+		if maxTokens > 0 {
+			s.describeMaxTokens = maxTokens
+		}
+	}
+}
+
+// DescribeLink asks the model for a summary and tags for originalURL and
+// sanitizes both before they are stored.
+func (s *AISlugService) DescribeLink(ctx context.Context, originalURL string) (*LinkDescription, error) {
+	if s.provider == nil {
+		return nil, fmt.Errorf("LLM provider not initialized")
+	}
+
+	ctx = ContextWithMaxTokens(ctx, s.describeMaxTokens)
+	completion, err := s.provider.Complete(ctx, s.describePrompt(ctx, originalURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe link: %w", err)
+	}
+
+	var raw struct {
+		Summary string   `json:"summary"`
+		Tags    []string `json:"tags"`
+	}
+	if err := decodeJSONObject(completion.Text, &raw); err != nil {
+		return nil, fmt.Errorf("unreadable link description: %w", err)
+	}
+
+	description := &LinkDescription{
+		Summary: cleanSummary(raw.Summary),
+		Tags:    cleanTags(raw.Tags),
+	}
+	if description.Summary == "" && len(description.Tags) == 0 {
+		return nil, fmt.Errorf("link description is empty after cleaning")
+	}
+
+	log.Printf("AI described URL %s with tags %v", utils.RedactURL(originalURL), description.Tags)
+	return description, nil
+}
+
+func (s *AISlugService) describePrompt(ctx context.Context, originalURL string) string {
+	_, locale := s.prompts.forTenant(TenantFromContext(ctx))
+
+	var page strings.Builder
+	if metadata := s.pageMetadata(ctx, originalURL); metadata != nil {
+		if title := firstNonEmpty(metadata.OGTitle, metadata.Title); title != "" {
+			fmt.Fprintf(&page, "\nPage title: %s", title)
+		}
+		if metadata.Description != "" {
+			fmt.Fprintf(&page, "\nPage description: %s", metadata.Description)
+		}
+	}
+
+	return fmt.Sprintf(`Describe this website for a searchable link library:
+URL: %s
+Domain: %s%s
+
+Requirements:
+- A one-line summary of at most %d characters
+- Up to %d lowercase topical tags, one or two words each, joined by hyphens
+- Write in the language with locale code "%s"
+
+Output:
+Only return a JSON object such as {"summary": "Reviews and lists of good books", "tags": ["books", "reviews"]}, with no explanation or formatting.`,
+		originalURL, extractDomain(originalURL), page.String(), MaxSummaryLength, MaxTags, locale)
+}
+
+// cleanSummary reduces summary to a single line of plain text within
+// MaxSummaryLength characters, cut at a word boundary where possible.
+func cleanSummary(summary string) string {
+	summary = markupPattern.ReplaceAllString(utils.NormalizeNFC(summary), " ")
+	summary = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return ' '
+		}
+		return r
+	}, summary)
+	summary = strings.Trim(strings.Join(strings.Fields(summary), " "), `"'`)
+
+	runes := []rune(summary)
+	if len(runes) <= MaxSummaryLength {
+		return summary
+	}
+	cut := string(runes[:MaxSummaryLength])
+	if space := strings.LastIndex(cut, " "); space > len(cut)/2 {
+		cut = cut[:space]
+	}
+	return strings.TrimRight(cut, " ,;:-") + "…"
+}
+
+// cleanTags lowercases tags, keeps letters, digits and hyphens, and drops
+// duplicates, lookalikes and tags outside the length limits.
+func cleanTags(tags []string) []string {
+	cleaned := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.ToLower(utils.NormalizeNFC(tag))), "-")
+		tag = strings.Trim(tagPattern.ReplaceAllString(tag, ""), "-")
+
+		length := len([]rune(tag))
+		if length < minTagLength || length > MaxTagLength || seen[tag] || utils.IsMixedScript(tag) {
+			continue
+		}
+		seen[tag] = true
+		cleaned = append(cleaned, tag)
+		if len(cleaned) == MaxTags {
+			break
+		}
+	}
+	return cleaned
+}
+
+// decodeJSONObject reads the JSON object in completion, ignoring any text
+// the model put around it.
+func decodeJSONObject(completion string, out interface{}) error {
+	start, end := strings.Index(completion, "{"), strings.LastIndex(completion, "}")
+	if start < 0 || end < start {
+		return fmt.Errorf("no JSON object in AI response")
+	}
+	return json.Unmarshal([]byte(completion[start:end+1]), out)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
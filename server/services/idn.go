@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+	"go-url-shortner/utils"
+	"net"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// CodeConfusableHost is returned for hosts that mix scripts or imitate Latin
+// domains, such as "аpple.com" with a Cyrillic "а".
+const CodeConfusableHost = "confusable_host"
+
+// Homograph actions for confusable hosts.
+const (
+	HomographActionWarn   = "warn"
+	HomographActionReject = "reject"
+)
+
+// WithHomographCheck rejects hosts that IsConfusableHost reports. Without it
+// such hosts are accepted and the shortener only returns a warning.
+func WithHomographCheck() ValidatorOption {
+	return func(v *URLValidator) {
+		v.rejectConfusable = true
+	}
+}
+
+// toASCIIHost converts a Unicode hostname to its punycode form. ASCII hosts
+// are returned unchanged so that names outside IDNA rules, such as ones with
+// underscores, keep working.
+func toASCIIHost(host string) (string, error) {
+	if isASCII(host) {
+		return host, nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", newValidationError(CodeInvalidHost, fmt.Sprintf("URL host is not a valid internationalized domain name: %v", err))
+	}
+	return ascii, nil
+}
+
+// DisplayHost returns the Unicode form of a punycode hostname for display.
+func DisplayHost(host string) string {
+	if !strings.Contains(strings.ToLower(host), "xn--") {
+		return host
+	}
+
+	display, err := idna.Display.ToUnicode(host)
+	if err != nil {
+		return host
+	}
+	return display
+}
+
+// IsConfusableHost reports whether any label of host, in either Unicode or
+// punycode form, mixes scripts or is written entirely in lookalike letters.
+func IsConfusableHost(host string) bool {
+	for _, label := range strings.Split(DisplayHost(host), ".") {
+		if utils.IsMixedScript(label) || utils.IsWholeScriptConfusable(label) {
+			return true
+		}
+	}
+	return false
+}
+
+// homographWarning returns a warning for destinations whose host is
+// confusable, or "" if it looks fine.
+func homographWarning(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !IsConfusableHost(u.Hostname()) {
+		return ""
+	}
+	return fmt.Sprintf("host %s mixes scripts or imitates another domain", DisplayHost(u.Hostname()))
+}
+
+// asciiURL rewrites the host of rawURL to punycode.
+func asciiURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL format: %w", err)
+	}
+
+	host, err := toASCIIHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	if host == u.Hostname() {
+		return rawURL, nil
+	}
+
+	return replaceHost(rawURL, u, host), nil
+}
+
+// displayURL returns rawURL with a punycode host shown in Unicode, or "" if
+// the host has no Unicode form.
+func displayURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	host := DisplayHost(u.Hostname())
+	if host == u.Hostname() {
+		return ""
+	}
+
+	return replaceHost(rawURL, u, host)
+}
+
+// replaceHost swaps the hostname of u, parsed from rawURL, for host while
+// leaving every other part of rawURL exactly as written.
+func replaceHost(rawURL string, u *url.URL, host string) string {
+	authority := strings.Index(rawURL, "//")
+	if authority == -1 {
+		return rawURL
+	}
+	if u.User != nil {
+		authority = strings.Index(rawURL, "@")
+	}
+
+	newHost := host
+	if port := u.Port(); port != "" {
+		newHost = net.JoinHostPort(host, port)
+	}
+	if !strings.Contains(rawURL[authority:], u.Host) {
+		// The host was percent-encoded; fall back to re-serializing
+		u.Host = newHost
+		return u.String()
+	}
+	return rawURL[:authority] + strings.Replace(rawURL[authority:], u.Host, newHost, 1)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -2,19 +2,115 @@ package services
 
 import (
 	"context"
+	"time"
 )
 
 type URLServiceInterface interface {
 	CreateShortURL(ctx context.Context, req URLRequest) (*URLResponse, error)
 	GetOriginalURL(ctx context.Context, shortCode string) (string, error)
+	DeleteURL(ctx context.Context, shortCode string) error
+	ReleaseCode(ctx context.Context, shortCode string) error
+	GetLinkMetadata(ctx context.Context, shortCode string) (*LinkMetadata, error)
+	SuggestSlugs(ctx context.Context, rawURL string) (*SlugSuggestions, error)
 }
 
 type AISlugServiceInterface interface {
 	GenerateSlug(ctx context.Context, originalURL string) (string, error)
 }
 
+// AISlugCandidateGenerator is implemented by AI slug services that can
+// propose several ranked slugs in one call.
+type AISlugCandidateGenerator interface {
+	GenerateSlugCandidates(ctx context.Context, originalURL string, n int) ([]string, error)
+}
+
+// AILinkDescriber is implemented by AI services that can summarize and tag
+// a destination.
+type AILinkDescriber interface {
+	DescribeLink(ctx context.Context, originalURL string) (*LinkDescription, error)
+}
+
+// AISlugForgetter is implemented by AI slug services that reuse earlier
+// slugs, so slugs that turned out to be taken are not offered again.
+type AISlugForgetter interface {
+	ForgetSlugs(ctx context.Context, originalURL string) error
+}
+
 type StorageInterface interface {
 	StoreURL(ctx context.Context, shortCode, originalURL string) error
+	// StoreURLIfAbsent stores shortCode only if it is not taken yet and
+	// reports whether it did, so concurrent claims cannot overwrite each other.
+	StoreURLIfAbsent(ctx context.Context, shortCode, originalURL string) (bool, error)
 	GetURL(ctx context.Context, shortCode string) (string, error)
+	DeleteURL(ctx context.Context, shortCode string) error
+	// ClaimNamespace records owner as the owner of namespace unless it is
+	// already claimed, and returns the namespace's effective owner.
+	ClaimNamespace(ctx context.Context, namespace, owner string) (string, error)
+	// NamespaceOwner returns the owner of namespace and whether it is claimed.
+	NamespaceOwner(ctx context.Context, namespace string) (string, bool, error)
 	Close() error
 }
+
+// CodePoolStore holds short codes that were generated and checked ahead of
+// time, so link creation can take one without further lookups.
+type CodePoolStore interface {
+	AddPoolCodes(ctx context.Context, codes ...string) (int64, error)
+	PopPoolCode(ctx context.Context) (string, error)
+	PoolSize(ctx context.Context) (int64, error)
+	IsPooled(ctx context.Context, code string) (bool, error)
+	// IncrementPoolCounter advances the shared counter used for sequential
+	// codes by n and returns its new value.
+	IncrementPoolCounter(ctx context.Context, n int64) (int64, error)
+}
+
+// TombstoneStore remembers which destination a code pointed to after the code
+// stops resolving, so it is not handed to someone else straight away.
+type TombstoneStore interface {
+	StoreTombstone(ctx context.Context, shortCode, originalURL string, ttl time.Duration) error
+	// GetTombstone returns the destination recorded for shortCode and whether
+	// a tombstone exists.
+	GetTombstone(ctx context.Context, shortCode string) (string, bool, error)
+	DeleteTombstone(ctx context.Context, shortCode string) error
+}
+
+// ThreatFlagStore records links whose destination was found to be malicious.
+type ThreatFlagStore interface {
+	FlagURL(ctx context.Context, shortCode, reason string) error
+	// GetFlag returns the reason a link was flagged and whether it is flagged.
+	GetFlag(ctx context.Context, shortCode string) (string, bool, error)
+	// ClearFlag removes the flag of a link, if any.
+	ClearFlag(ctx context.Context, shortCode string) error
+}
+
+// LinkIterator walks over all stored links.
+type LinkIterator interface {
+	ForEachURL(ctx context.Context, fn func(shortCode, originalURL string) error) error
+}
+
+// AICacheStore holds AI slug results shared by all instances.
+type AICacheStore interface {
+	StoreAICache(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// GetAICache returns the cached value and whether there is one.
+	GetAICache(ctx context.Context, key string) ([]byte, bool, error)
+	DeleteAICache(ctx context.Context, key string) error
+}
+
+// AIUsageStore adds up AI token counts per day and tenant.
+type AIUsageStore interface {
+	AddAIUsage(ctx context.Context, day, tenant string, promptTokens, completionTokens int64) error
+	// GetAIUsage returns the day's counters keyed by "<tenant>:prompt",
+	// "<tenant>:completion" and "<tenant>:reserved".
+	GetAIUsage(ctx context.Context, day string) (map[string]int64, error)
+	// ReserveAIUsage adds tokens, which may be negative, to the tenant's
+	// reserved counter and returns the day's counters including the change,
+	// in one atomic step.
+	ReserveAIUsage(ctx context.Context, day, tenant string, tokens int64) (map[string]int64, error)
+}
+
+// MetadataStore holds JSON-encoded LinkMetadata per short code.
+type MetadataStore interface {
+	StoreMetadata(ctx context.Context, shortCode string, metadata []byte) error
+	// GetMetadata returns the stored metadata and whether there is any.
+	GetMetadata(ctx context.Context, shortCode string) ([]byte, bool, error)
+	DeleteMetadata(ctx context.Context, shortCode string) error
+}
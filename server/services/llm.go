@@ -0,0 +1,348 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// LLM backends understood by NewLLMProvider.
+const (
+	ProviderOpenAI    = "openai"
+	ProviderOllama    = "ollama"
+	ProviderAnthropic = "anthropic"
+)
+
+// LLMProvider turns a prompt into a completion. AISlugService builds the
+// prompt and cleans the answer, so a provider only talks to its backend.
+type LLMProvider interface {
+	Complete(ctx context.Context, prompt string) (Completion, error)
+}
+
+// Completion is a provider's answer and the tokens it was billed for.
+type Completion struct {
+	Text  string
+	Usage TokenUsage
+}
+
+// TokenUsage counts the tokens of a prompt and its completion.
+type TokenUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+}
+
+// Total returns the prompt and completion tokens together.
+func (u TokenUsage) Total() int64 {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// LLMConfig selects and configures an LLM backend.
+type LLMConfig struct {
+	// Provider is ProviderOpenAI, ProviderOllama or ProviderAnthropic.
+	// OpenAI-compatible model servers use ProviderOpenAI with a BaseURL.
+	Provider    string
+	APIKey      string
+	BaseURL     string
+	Model       string
+	Temperature float32
+	// MaxTokens limits each completion unless the call's context sets its
+	// own limit with ContextWithMaxTokens.
+	MaxTokens int
+	// Client sends the provider's requests and defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+var defaultModels = map[string]string{
+	ProviderOpenAI:    openai.GPT3Dot5Turbo,
+	ProviderOllama:    "llama3.2",
+	ProviderAnthropic: "claude-3-5-haiku-latest",
+}
+
+var defaultBaseURLs = map[string]string{
+	ProviderOllama:    "http://localhost:11434",
+	ProviderAnthropic: "https://api.anthropic.com",
+}
+
+// NewLLMProvider returns the backend named by cfg.Provider, filling in the
+// provider's default model and base URL where cfg leaves them empty.
+func NewLLMProvider(cfg LLMConfig) (LLMProvider, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderOpenAI
+	}
+	cfg.Provider = strings.ToLower(cfg.Provider)
+
+	model, known := defaultModels[cfg.Provider]
+	if !known {
+		return nil, fmt.Errorf("unknown LLM provider %q", cfg.Provider)
+	}
+	if cfg.Model == "" {
+		cfg.Model = model
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURLs[cfg.Provider]
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+
+	switch cfg.Provider {
+	case ProviderOllama:
+		return &ollamaProvider{cfg: cfg}, nil
+	case ProviderAnthropic:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("the anthropic provider needs an API key")
+		}
+		return &anthropicProvider{cfg: cfg}, nil
+	default:
+		if cfg.APIKey == "" && cfg.BaseURL == "" {
+			return nil, fmt.Errorf("the openai provider needs an API key or a base URL")
+		}
+		return newOpenAIProvider(cfg), nil
+	}
+}
+
+func (c LLMConfig) maxTokens(ctx context.Context) int {
+	if maxTokens := MaxTokensFromContext(ctx); maxTokens > 0 {
+		return maxTokens
+	}
+	return c.MaxTokens
+}
+
+// openAIProvider talks to the OpenAI chat completions API or any server
+// implementing it.
+type openAIProvider struct {
+	client *openai.Client
+	cfg    LLMConfig
+}
+
+func newOpenAIProvider(cfg LLMConfig) *openAIProvider {
+	clientConfig := openai.DefaultConfig(cfg.APIKey)
+	if cfg.BaseURL != "" {
+		clientConfig.BaseURL = cfg.BaseURL
+	}
+	clientConfig.HTTPClient = cfg.Client
+	return &openAIProvider{client: openai.NewClientWithConfig(clientConfig), cfg: cfg}
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, prompt string) (Completion, error) {
+	resp, err := p.client.CreateChatCompletion(
+		ctx,
+		openai.ChatCompletionRequest{
+			Model: p.cfg.Model,
+			Messages: []openai.ChatCompletionMessage{
+				{
+					Role:    openai.ChatMessageRoleUser,
+					Content: prompt,
+				},
+			},
+			MaxTokens:   p.cfg.maxTokens(ctx),
+			Temperature: p.cfg.Temperature,
+		},
+	)
+	if err != nil {
+		return Completion{}, err
+	}
+
+	usage := TokenUsage{
+		PromptTokens:     int64(resp.Usage.PromptTokens),
+		CompletionTokens: int64(resp.Usage.CompletionTokens),
+	}
+	if len(resp.Choices) == 0 {
+		return Completion{Usage: usage}, fmt.Errorf("no response from OpenAI")
+	}
+	return Completion{Text: resp.Choices[0].Message.Content, Usage: usage}, nil
+}
+
+// ollamaProvider talks to the Ollama chat API.
+type ollamaProvider struct {
+	cfg LLMConfig
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  ollamaOptions `json:"options"`
+}
+
+type ollamaOptions struct {
+	Temperature float32 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+type ollamaResponse struct {
+	Message         chatMessage `json:"message"`
+	PromptEvalCount int64       `json:"prompt_eval_count"`
+	EvalCount       int64       `json:"eval_count"`
+}
+
+func (p *ollamaProvider) Complete(ctx context.Context, prompt string) (Completion, error) {
+	body := ollamaRequest{
+		Model:    p.cfg.Model,
+		Messages: []chatMessage{{Role: "user", Content: prompt}},
+		Options:  ollamaOptions{Temperature: p.cfg.Temperature, NumPredict: p.cfg.maxTokens(ctx)},
+	}
+
+	var resp ollamaResponse
+	if err := postJSON(ctx, p.cfg.Client, p.cfg.BaseURL+"/api/chat", nil, body, &resp); err != nil {
+		return Completion{}, err
+	}
+	return Completion{
+		Text:  resp.Message.Content,
+		Usage: TokenUsage{PromptTokens: resp.PromptEvalCount, CompletionTokens: resp.EvalCount},
+	}, nil
+}
+
+// anthropicProvider talks to the Anthropic messages API.
+type anthropicProvider struct {
+	cfg LLMConfig
+}
+
+type anthropicRequest struct {
+	Model       string        `json:"model"`
+	MaxTokens   int           `json:"max_tokens"`
+	Temperature float32       `json:"temperature"`
+	Messages    []chatMessage `json:"messages"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int64 `json:"input_tokens"`
+		OutputTokens int64 `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (p *anthropicProvider) Complete(ctx context.Context, prompt string) (Completion, error) {
+	body := anthropicRequest{
+		Model:       p.cfg.Model,
+		MaxTokens:   p.cfg.maxTokens(ctx),
+		Temperature: p.cfg.Temperature,
+		Messages:    []chatMessage{{Role: "user", Content: prompt}},
+	}
+	headers := map[string]string{
+		"x-api-key":         p.cfg.APIKey,
+		"anthropic-version": "2023-06-01",
+	}
+
+	var resp anthropicResponse
+	if err := postJSON(ctx, p.cfg.Client, p.cfg.BaseURL+"/v1/messages", headers, body, &resp); err != nil {
+		return Completion{}, err
+	}
+
+	usage := TokenUsage{PromptTokens: resp.Usage.InputTokens, CompletionTokens: resp.Usage.OutputTokens}
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			return Completion{Text: block.Text, Usage: usage}, nil
+		}
+	}
+	return Completion{Usage: usage}, fmt.Errorf("no text in Anthropic response")
+}
+
+// postJSON sends body as JSON to endpoint and decodes the JSON answer into out.
+func postJSON(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &ProviderStatusError{
+			Endpoint:   endpoint,
+			StatusCode: resp.StatusCode,
+			Detail:     strings.TrimSpace(string(detail)),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ProviderStatusError is returned when an LLM backend answers with a status
+// other than 200.
+type ProviderStatusError struct {
+	Endpoint   string
+	StatusCode int
+	Detail     string
+	// RetryAfter is how long the backend asked to wait before retrying, or 0.
+	RetryAfter time.Duration
+}
+
+func (e *ProviderStatusError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.Endpoint, e.StatusCode, e.Detail)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date, returning 0 if it is missing or unreadable.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && time.Until(at) > 0 {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// providerRetryAfter returns how long the backend asked to wait before a
+// retry, or 0.
+func providerRetryAfter(err error) time.Duration {
+	var statusErr *ProviderStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.RetryAfter
+	}
+	return 0
+}
+
+// providerStatus returns the HTTP status an LLM backend failed with, or 0 if
+// err carries none.
+func providerStatus(err error) int {
+	var statusErr *ProviderStatusError
+	var apiErr *openai.APIError
+	var requestErr *openai.RequestError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.StatusCode
+	case errors.As(err, &apiErr):
+		return apiErr.HTTPStatusCode
+	case errors.As(err, &requestErr):
+		return requestErr.HTTPStatusCode
+	}
+	return 0
+}
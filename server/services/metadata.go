@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-url-shortner/utils"
+	"log"
+	"time"
+)
+
+// LinkMetadata describes a short link beyond its destination.
+type LinkMetadata struct {
+	ShortCode    string              `json:"short_code"`
+	OriginalURL  string              `json:"original_url"`
+	SlugType     string              `json:"slug_type,omitempty"`
+	CreatedAt    time.Time           `json:"created_at,omitempty"`
+	Reachability *ReachabilityResult `json:"reachability,omitempty"`
+	// AISlugStatus tracks an asynchronous AI slug upgrade, whose result is
+	// served as an alias at VanityURL.
+	AISlugStatus string `json:"ai_slug_status,omitempty"`
+	AISlug       string `json:"ai_slug,omitempty"`
+	VanityURL    string `json:"vanity_url,omitempty"`
+	// AliasOf names the link an AI slug alias was created for.
+	AliasOf string `json:"alias_of,omitempty"`
+	// Summary and Tags are generated by the AI when the link is created
+	// with Describe set.
+	Summary string   `json:"summary,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// WithMetadataStore records metadata for every link created.
+func WithMetadataStore(store MetadataStore) URLServiceOption {
+	return func(s *URLService) {
+		s.metadata = store
+	}
+}
+
+// GetLinkMetadata returns the metadata of a link. Links created before
+// metadata was recorded get a record with just their destination.
+func (s *URLService) GetLinkMetadata(ctx context.Context, shortCode string) (*LinkMetadata, error) {
+	code := s.normalizeCode(shortCode)
+
+	originalURL, err := s.storage.GetURL(ctx, code)
+	if err != nil && s.foldCase {
+		if folded := utils.FoldCase(code); folded != code {
+			code = folded
+			originalURL, err = s.storage.GetURL(ctx, code)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrLinkNotFound, code)
+	}
+
+	metadata := &LinkMetadata{ShortCode: code, OriginalURL: originalURL}
+	if s.metadata == nil {
+		return metadata, nil
+	}
+
+	stored, err := s.loadMetadata(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return metadata, nil
+	}
+
+	// The link may have been re-pointed since the metadata was written
+	stored.OriginalURL = originalURL
+	return stored, nil
+}
+
+// loadMetadata returns the stored metadata of code, or nil if there is none.
+func (s *URLService) loadMetadata(ctx context.Context, code string) (*LinkMetadata, error) {
+	if s.metadata == nil {
+		return nil, nil
+	}
+
+	raw, found, err := s.metadata.GetMetadata(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get link metadata: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+
+	metadata := &LinkMetadata{}
+	if err := json.Unmarshal(raw, metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode link metadata: %w", err)
+	}
+	return metadata, nil
+}
+
+// updateMetadata applies update to the stored metadata of code. Links
+// without metadata are left alone.
+func (s *URLService) updateMetadata(ctx context.Context, code string, update func(*LinkMetadata)) {
+	metadata, err := s.loadMetadata(ctx, code)
+	if err != nil {
+		log.Printf("Failed to update metadata for '%s': %v", code, err)
+		return
+	}
+	if metadata == nil {
+		return
+	}
+
+	update(metadata)
+	s.saveMetadata(ctx, metadata)
+}
+
+// saveMetadata stores the metadata of a link. Failures are logged rather
+// than failing link creation, since the link itself is already stored.
+func (s *URLService) saveMetadata(ctx context.Context, metadata *LinkMetadata) {
+	if s.metadata == nil {
+		return
+	}
+
+	raw, err := json.Marshal(metadata)
+	if err == nil {
+		err = s.metadata.StoreMetadata(ctx, metadata.ShortCode, raw)
+	}
+	if err != nil {
+		log.Printf("Failed to store metadata for '%s': %v", metadata.ShortCode, err)
+	}
+}
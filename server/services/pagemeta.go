@@ -0,0 +1,334 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	DefaultPageUserAgent = "go-url-shortner-preview/1.0"
+	maxPageFieldLength   = 200
+	robotsCacheTTL       = time.Hour
+	// maxRobotsEntries bounds the robots.txt cache, whose keys come from
+	// user-supplied URLs.
+	maxRobotsEntries = 1024
+)
+
+// PageMetadata is what a destination page says about itself.
+type PageMetadata struct {
+	Title       string `json:"title,omitempty"`
+	OGTitle     string `json:"og_title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// PageMetadataFetcher looks up the title and description of a page.
+type PageMetadataFetcher interface {
+	Fetch(ctx context.Context, rawURL string) (*PageMetadata, error)
+}
+
+type PageFetcherConfig struct {
+	// Client performs the requests. It should refuse internal addresses,
+	// e.g. one from NewSafeHTTPClient, and carry the fetch timeout.
+	Client *http.Client
+	// MaxBytes bounds how much of a page is read.
+	MaxBytes int64
+	// UserAgent identifies the fetcher to sites and selects its robots.txt
+	// group.
+	UserAgent string
+}
+
+// PageFetcher reads page metadata from the head of HTML pages, honouring
+// robots.txt rules for its user agent.
+type PageFetcher struct {
+	client    *http.Client
+	maxBytes  int64
+	userAgent string
+
+	mu     sync.Mutex
+	robots map[string]robotsEntry
+}
+
+type robotsEntry struct {
+	rules     robotsRules
+	fetchedAt time.Time
+}
+
+func NewPageFetcher(config PageFetcherConfig) *PageFetcher {
+	f := &PageFetcher{
+		client:    config.Client,
+		maxBytes:  config.MaxBytes,
+		userAgent: config.UserAgent,
+		robots:    make(map[string]robotsEntry),
+	}
+	if f.client == nil {
+		f.client = NewSafeHTTPClient(3 * time.Second)
+	}
+	if f.maxBytes <= 0 {
+		f.maxBytes = 512 << 10
+	}
+	if f.userAgent == "" {
+		f.userAgent = DefaultPageUserAgent
+	}
+	return f
+}
+
+// Fetch returns the metadata of the HTML page at rawURL. Pages disallowed by
+// robots.txt are not requested.
+func (f *PageFetcher) Fetch(ctx context.Context, rawURL string) (*PageMetadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !isWebScheme(u.Scheme) {
+		return nil, fmt.Errorf("cannot fetch metadata for %q", rawURL)
+	}
+	u.User = nil
+
+	if !f.robotsAllowed(ctx, u) {
+		return nil, fmt.Errorf("robots.txt disallows %s", u.Path)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("page returned %d", resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("page is %q, not HTML", mediaType)
+	}
+
+	return parsePageMetadata(io.LimitReader(resp.Body, f.maxBytes)), nil
+}
+
+// parsePageMetadata reads <title>, og:title and the description from the
+// document head, stopping at <body>.
+func parsePageMetadata(r io.Reader) *PageMetadata {
+	metadata := &PageMetadata{}
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return metadata
+		case html.TextToken:
+			if inTitle && metadata.Title == "" {
+				metadata.Title = cleanPageText(string(tokenizer.Text()))
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return metadata
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = true
+			case "body":
+				return metadata
+			case "meta":
+				if hasAttr {
+					readMetaTag(tokenizer, metadata)
+				}
+			}
+		}
+	}
+}
+
+func readMetaTag(tokenizer *html.Tokenizer, metadata *PageMetadata) {
+	var key, content string
+	for {
+		name, value, more := tokenizer.TagAttr()
+		switch string(name) {
+		case "name", "property":
+			key = strings.ToLower(string(value))
+		case "content":
+			content = cleanPageText(string(value))
+		}
+		if !more {
+			break
+		}
+	}
+
+	switch key {
+	case "og:title":
+		metadata.OGTitle = content
+	case "description":
+		metadata.Description = content
+	case "og:description":
+		if metadata.Description == "" {
+			metadata.Description = content
+		}
+	}
+}
+
+// cleanPageText collapses whitespace and truncates page text before it is
+// put into a prompt.
+func cleanPageText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxPageFieldLength {
+		text = string(runes[:maxPageFieldLength])
+	}
+	return text
+}
+
+func (f *PageFetcher) robotsAllowed(ctx context.Context, u *url.URL) bool {
+	origin := u.Scheme + "://" + u.Host
+
+	f.mu.Lock()
+	entry, cached := f.robots[origin]
+	f.mu.Unlock()
+
+	if !cached || time.Since(entry.fetchedAt) > robotsCacheTTL {
+		entry = robotsEntry{rules: f.fetchRobots(ctx, origin), fetchedAt: time.Now()}
+		f.mu.Lock()
+		f.cacheRobots(origin, entry)
+		f.mu.Unlock()
+	}
+
+	return entry.rules.allows(u.EscapedPath())
+}
+
+// cacheRobots stores entry for origin. Expired entries are evicted first,
+// then the oldest ones while the cache is full. f.mu must be held.
+func (f *PageFetcher) cacheRobots(origin string, entry robotsEntry) {
+	if _, cached := f.robots[origin]; !cached && len(f.robots) >= maxRobotsEntries {
+		for key, cached := range f.robots {
+			if time.Since(cached.fetchedAt) > robotsCacheTTL {
+				delete(f.robots, key)
+			}
+		}
+		for len(f.robots) >= maxRobotsEntries {
+			oldest := ""
+			for key, cached := range f.robots {
+				if oldest == "" || cached.fetchedAt.Before(f.robots[oldest].fetchedAt) {
+					oldest = key
+				}
+			}
+			delete(f.robots, oldest)
+		}
+	}
+	f.robots[origin] = entry
+}
+
+// fetchRobots loads the robots.txt rules for origin. A missing or unreadable
+// robots.txt allows everything.
+func (f *PageFetcher) fetchRobots(ctx context.Context, origin string) robotsRules {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	return parseRobots(io.LimitReader(resp.Body, 64<<10), f.userAgent)
+}
+
+// robotsRules are the Allow and Disallow lines that apply to one user agent.
+type robotsRules []robotsRule
+
+type robotsRule struct {
+	allow bool
+	path  string
+}
+
+// allows applies the longest matching rule, with Allow winning ties.
+func (r robotsRules) allows(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+
+	allowed, longest := true, -1
+	for _, rule := range r {
+		if !strings.HasPrefix(path, rule.path) {
+			continue
+		}
+		if len(rule.path) > longest || (len(rule.path) == longest && rule.allow) {
+			allowed, longest = rule.allow, len(rule.path)
+		}
+	}
+	return allowed
+}
+
+// parseRobots returns the rules of the group naming userAgent's product
+// token, or of the "*" group when no group names it.
+func parseRobots(r io.Reader, userAgent string) robotsRules {
+	product := strings.ToLower(strings.SplitN(userAgent, "/", 2)[0])
+
+	var specific, wildcard robotsRules
+	var agents []string
+	inRules, named := false, false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		field, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agent := strings.ToLower(value)
+			agents = append(agents, agent)
+			named = named || agent == product
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			rule := robotsRule{allow: field == "allow", path: value}
+			for _, agent := range agents {
+				switch agent {
+				case product:
+					specific = append(specific, rule)
+				case "*":
+					wildcard = append(wildcard, rule)
+				}
+			}
+		}
+	}
+
+	if named {
+		return specific
+	}
+	return wildcard
+}
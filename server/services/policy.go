@@ -0,0 +1,249 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-url-shortner/utils"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const CodePolicyDenied = "policy_denied"
+
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+
+	// Rule types
+	PolicyDomain    = "domain"
+	PolicyURL       = "url"
+	PolicyPathRegex = "path_regex"
+)
+
+// PolicyRule matches destinations by domain, exact URL or path pattern.
+// Domain patterns are exact ("example.com"), wildcards ("*.example.com") or
+// suffixes (".example.com", which also matches example.com itself).
+type PolicyRule struct {
+	ID      string   `json:"id"`
+	Action  string   `json:"action"`
+	Type    string   `json:"type"`
+	Pattern string   `json:"pattern"`
+	Tenants []string `json:"tenants,omitempty"`
+}
+
+// PolicyRuleSet is evaluated top to bottom and the first matching rule
+// decides. Destinations no rule matches are allowed, except for tenants in
+// AllowlistTenants, who may only link to destinations an allow rule matches.
+type PolicyRuleSet struct {
+	Rules            []PolicyRule `json:"rules"`
+	AllowlistTenants []string     `json:"allowlist_tenants,omitempty"`
+}
+
+// RuleSource supplies the raw JSON rule set.
+type RuleSource interface {
+	LoadRules(ctx context.Context) ([]byte, error)
+}
+
+// FileRuleSource reads the rule set from a JSON file.
+type FileRuleSource struct {
+	Path string
+}
+
+func (f FileRuleSource) LoadRules(ctx context.Context) ([]byte, error) {
+	return os.ReadFile(f.Path)
+}
+
+type compiledRule struct {
+	PolicyRule
+	pathRegexp *regexp.Regexp
+	// canonicalURL is the pattern of a URL rule in policyURLRules form.
+	canonicalURL string
+	tenants      map[string]bool
+}
+
+// policyURLRules canonicalize both URL rule patterns and destinations before
+// they are compared, so a rule matches however either side is spelled,
+// including with tracking parameters or a different query order.
+var policyURLRules = CanonicalRules{
+	Lowercase:       true,
+	DefaultPort:     true,
+	DotSegments:     true,
+	PercentEncoding: true,
+	SortQuery:       true,
+	StripTracking:   true,
+	TrackingParams:  DefaultTrackingParams,
+}
+
+type compiledRuleSet struct {
+	raw              []byte
+	rules            []compiledRule
+	allowlistTenants map[string]bool
+}
+
+// PolicyEngine decides whether a tenant may shorten a destination. Rules are
+// swapped atomically on reload, so checks never see a half-loaded set.
+type PolicyEngine struct {
+	source RuleSource
+	rules  atomic.Pointer[compiledRuleSet]
+}
+
+func NewPolicyEngine(source RuleSource) *PolicyEngine {
+	e := &PolicyEngine{source: source}
+	e.rules.Store(&compiledRuleSet{})
+	return e
+}
+
+// Reload fetches and compiles the rule set. On error the previous rules stay
+// in effect.
+func (e *PolicyEngine) Reload(ctx context.Context) error {
+	raw, err := e.source.LoadRules(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load policy rules: %w", err)
+	}
+	if bytes.Equal(raw, e.rules.Load().raw) {
+		return nil
+	}
+
+	compiled, err := compileRuleSet(raw)
+	if err != nil {
+		return err
+	}
+
+	e.rules.Store(compiled)
+	log.Printf("Loaded %d policy rules", len(compiled.rules))
+	return nil
+}
+
+// Watch reloads the rules every interval until ctx is cancelled.
+func (e *PolicyEngine) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Reload(ctx); err != nil {
+				log.Printf("Policy reload failed, keeping previous rules: %v", err)
+			}
+		}
+	}
+}
+
+// Check returns a ValidationError if tenant may not shorten u.
+func (e *PolicyEngine) Check(tenant string, u *url.URL) error {
+	set := e.rules.Load()
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	for _, rule := range set.rules {
+		if len(rule.tenants) > 0 && !rule.tenants[tenant] {
+			continue
+		}
+		if !rule.matches(host, u) {
+			continue
+		}
+
+		if rule.Action == PolicyDeny {
+			log.Printf("Policy rule '%s' (%s %s) rejected %s for tenant '%s'", rule.ID, rule.Type, rule.Pattern, utils.RedactURL(u.String()), tenant)
+			return newValidationError(CodePolicyDenied, "URL is not allowed by policy")
+		}
+		return nil
+	}
+
+	if set.allowlistTenants[tenant] {
+		log.Printf("Policy allowlist for tenant '%s' rejected %s: no allow rule matched", tenant, utils.RedactURL(u.String()))
+		return newValidationError(CodePolicyDenied, "URL is not on the allowlist")
+	}
+	return nil
+}
+
+func (r *compiledRule) matches(host string, u *url.URL) bool {
+	switch r.Type {
+	case PolicyDomain:
+		return matchDomain(strings.ToLower(r.Pattern), host)
+	case PolicyURL:
+		destination, err := Canonicalize(u.String(), policyURLRules)
+		if err != nil {
+			return false
+		}
+		return strings.TrimSuffix(destination, "/") == r.canonicalURL
+	case PolicyPathRegex:
+		return r.pathRegexp.MatchString(u.EscapedPath())
+	}
+	return false
+}
+
+func matchDomain(pattern, host string) bool {
+	switch {
+	case strings.HasPrefix(pattern, "."):
+		return host == pattern[1:] || strings.HasSuffix(host, pattern)
+	case strings.ContainsAny(pattern, "*?["):
+		matched, _ := path.Match(pattern, host)
+		return matched
+	default:
+		return host == pattern
+	}
+}
+
+func compileRuleSet(raw []byte) (*compiledRuleSet, error) {
+	var set PolicyRuleSet
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse policy rules: %w", err)
+	}
+
+	compiled := &compiledRuleSet{
+		raw:              raw,
+		allowlistTenants: toSet(set.AllowlistTenants),
+	}
+
+	for i, rule := range set.Rules {
+		if rule.ID == "" {
+			rule.ID = fmt.Sprintf("rule-%d", i+1)
+		}
+		if rule.Action != PolicyAllow && rule.Action != PolicyDeny {
+			return nil, fmt.Errorf("policy rule '%s' has invalid action %q", rule.ID, rule.Action)
+		}
+
+		c := compiledRule{PolicyRule: rule, tenants: toSet(rule.Tenants)}
+		switch rule.Type {
+		case PolicyDomain:
+			if _, err := path.Match(rule.Pattern, ""); err != nil {
+				return nil, fmt.Errorf("policy rule '%s' has invalid domain pattern: %w", rule.ID, err)
+			}
+		case PolicyURL:
+			canonical, err := Canonicalize(rule.Pattern, policyURLRules)
+			if err != nil {
+				return nil, fmt.Errorf("policy rule '%s' has invalid URL: %w", rule.ID, err)
+			}
+			c.canonicalURL = strings.TrimSuffix(canonical, "/")
+		case PolicyPathRegex:
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("policy rule '%s' has invalid path regex: %w", rule.ID, err)
+			}
+			c.pathRegexp = re
+		default:
+			return nil, fmt.Errorf("policy rule '%s' has invalid type %q", rule.ID, rule.Type)
+		}
+		compiled.rules = append(compiled.rules, c)
+	}
+
+	return compiled, nil
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"go-url-shortner/utils"
+	"log"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	PoolModeRandom  = "random"
+	PoolModeCounter = "counter"
+)
+
+// maxPoolCodeLength bounds pooled codes; longer ones defeat short links.
+const maxPoolCodeLength = 32
+
+var poolMetrics = expvar.NewMap("code_pool")
+
+type CodePoolConfig struct {
+	// Refill starts once the pool holds fewer than LowWatermark codes and
+	// tops it up to TargetSize.
+	LowWatermark   int
+	TargetSize     int
+	CodeLength     int
+	Mode           string
+	Lowercase      bool
+	RefillInterval time.Duration
+}
+
+// CodePool keeps a stock of reserved short codes in storage and refills it
+// in the background.
+type CodePool struct {
+	store   CodePoolStore
+	storage StorageInterface
+	config  CodePoolConfig
+	size    atomic.Int64
+	refill  chan struct{}
+}
+
+func NewCodePool(store CodePoolStore, storage StorageInterface, config CodePoolConfig) *CodePool {
+	if config.CodeLength <= 0 {
+		config.CodeLength = 7
+	}
+	if config.TargetSize < config.LowWatermark {
+		config.TargetSize = config.LowWatermark
+	}
+	if config.RefillInterval <= 0 {
+		config.RefillInterval = 30 * time.Second
+	}
+
+	return &CodePool{
+		store:   store,
+		storage: storage,
+		config:  config,
+		refill:  make(chan struct{}, 1),
+	}
+}
+
+// Take pops a reserved code. It reports false when the pool is empty or
+// unreachable, in which case the caller should fall back to another scheme.
+func (p *CodePool) Take(ctx context.Context) (string, bool) {
+	code, err := p.store.PopPoolCode(ctx)
+	if err != nil || code == "" {
+		poolMetrics.Add("empty", 1)
+		p.triggerRefill()
+		return "", false
+	}
+
+	poolMetrics.Add("taken", 1)
+	if p.size.Add(-1) < int64(p.config.LowWatermark) {
+		p.triggerRefill()
+	}
+	p.publishSize()
+
+	return code, true
+}
+
+// Reserved reports whether code is sitting in the pool and must not be
+// handed out by another generator.
+func (p *CodePool) Reserved(ctx context.Context, code string) bool {
+	pooled, err := p.store.IsPooled(ctx, code)
+	return err == nil && pooled
+}
+
+// Run refills the pool periodically and whenever Take drops it below the low
+// watermark, until ctx is cancelled.
+func (p *CodePool) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.config.RefillInterval)
+	defer ticker.Stop()
+
+	for {
+		if added, err := p.Refill(ctx); err != nil {
+			log.Printf("Code pool refill failed: %v", err)
+		} else if added > 0 {
+			log.Printf("Code pool refilled with %d codes", added)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.refill:
+		}
+	}
+}
+
+// Refill tops the pool up to its target size if it has fallen below the low
+// watermark, and returns the number of codes added.
+func (p *CodePool) Refill(ctx context.Context) (int, error) {
+	size, err := p.store.PoolSize(ctx)
+	if err != nil {
+		poolMetrics.Add("refill_errors", 1)
+		return 0, fmt.Errorf("failed to read pool size: %w", err)
+	}
+	p.size.Store(size)
+	p.publishSize()
+
+	if size >= int64(p.config.LowWatermark) {
+		return 0, nil
+	}
+
+	missing := p.config.TargetSize - int(size)
+	candidates, err := p.generate(ctx, missing)
+	if err != nil {
+		poolMetrics.Add("refill_errors", 1)
+		return 0, err
+	}
+
+	codes := make([]string, 0, len(candidates))
+	for _, code := range candidates {
+		if isReservedCode(code) {
+			continue
+		}
+		if _, err := p.storage.GetURL(ctx, code); err != nil {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return 0, nil
+	}
+
+	added, err := p.store.AddPoolCodes(ctx, codes...)
+	if err != nil {
+		poolMetrics.Add("refill_errors", 1)
+		return 0, fmt.Errorf("failed to add pool codes: %w", err)
+	}
+
+	p.size.Add(added)
+	p.publishSize()
+	poolMetrics.Add("refilled", added)
+
+	return int(added), nil
+}
+
+func (p *CodePool) generate(ctx context.Context, n int) ([]string, error) {
+	codes := make([]string, 0, n)
+
+	if p.config.Mode == PoolModeCounter {
+		end, err := p.store.IncrementPoolCounter(ctx, int64(n))
+		if err != nil {
+			return nil, fmt.Errorf("failed to advance pool counter: %w", err)
+		}
+
+		// Offset the counter so sequential codes start at the configured length
+		offset, err := counterOffset(p.config.CodeLength, p.config.Lowercase)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := end - int64(n) + 1; i <= end; i++ {
+			if p.config.Lowercase {
+				codes = append(codes, utils.Base36Encode(offset+uint64(i)))
+			} else {
+				codes = append(codes, utils.Base62Encode(offset+uint64(i)))
+			}
+		}
+		return codes, nil
+	}
+
+	for i := 0; i < n; i++ {
+		code, err := utils.RandomCode(p.config.CodeLength, p.config.Lowercase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate pool code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// Validate reports settings the pool cannot work with.
+func (c CodePoolConfig) Validate() error {
+	switch c.Mode {
+	case "", PoolModeRandom, PoolModeCounter:
+	default:
+		return fmt.Errorf("unknown code pool mode %q", c.Mode)
+	}
+	if c.CodeLength < 0 || c.CodeLength > maxPoolCodeLength {
+		return fmt.Errorf("code pool codes must be 1 to %d characters long", maxPoolCodeLength)
+	}
+	if c.Mode == PoolModeCounter {
+		if _, err := counterOffset(c.CodeLength, c.Lowercase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// counterOffset returns the smallest number with length digits in base 62,
+// or base 36 for lowercase codes. Counter codes are this offset plus the
+// counter, so they must fit in 64 bits.
+func counterOffset(length int, lowercase bool) (uint64, error) {
+	base := uint64(62)
+	if lowercase {
+		base = 36
+	}
+
+	offset := uint64(1)
+	for i := 1; i < length; i++ {
+		if offset > math.MaxUint64/base {
+			return 0, fmt.Errorf("counter codes of %d characters do not fit in 64 bits", length)
+		}
+		offset *= base
+	}
+	return offset, nil
+}
+
+func (p *CodePool) triggerRefill() {
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+}
+
+func (p *CodePool) publishSize() {
+	size := new(expvar.Int)
+	size.Set(p.size.Load())
+	poolMetrics.Set("size", size)
+}
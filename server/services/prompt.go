@@ -0,0 +1,189 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Length limits of AI slugs. cleanSlug enforces them and prompts are given
+// them, so the model is asked for what will be kept.
+const (
+	MinSlugLength = 3
+	MaxSlugLength = 8
+)
+
+// DefaultSlugLocale is the language slugs are written in unless configured.
+const DefaultSlugLocale = "en"
+
+// DefaultSlugPromptTemplate is the prompt used unless another is configured.
+const DefaultSlugPromptTemplate = `{{if gt .Count 1 -}}
+Generate {{.Count}} different short, catchy, and memorable URL slugs for this website, best first:
+{{- else -}}
+Generate exactly one short, catchy, and memorable URL slug for this website:
+{{- end}}
+URL: {{.URL}}
+Domain: {{.Domain}}
+{{- if .Title}}
+Page title: {{.Title}}
+{{- end}}
+{{- if .Description}}
+Page description: {{.Description}}
+{{- end}}
+{{- if .Hints}}
+Slugs already used for this domain (match their style, do not repeat them): {{join .Hints ", "}}
+{{- end}}
+
+Requirements:
+- {{.MinLength}} to {{.MaxLength}} characters
+- Memorable and relevant to the website's name or purpose
+- Use only lowercase letters{{if .Unicode}} (any script){{end}}, numbers, and hyphens
+- No spaces, underscores, or special characters
+- Avoid generic or overused slugs
+{{- if ne .Locale "en"}}
+- Use words in the language with locale code "{{.Locale}}"{{if not .Unicode}}, written in Latin letters{{end}}
+{{- end}}
+
+Examples:
+- For "https://best-books-lover.com/good-books/book" -> "bestbook" or "gbooks"
+- For "https://travel-tips-expert.com/top-destinations/2025" -> "travexp" or "topdest"
+
+Output:
+{{if gt .Count 1 -}}
+Only return a JSON array of strings, such as ["bestbook", "gbooks"], with no explanation or formatting.
+{{- else -}}
+Only return the slug itself with no explanation or formatting.
+{{- end}}`
+
+var defaultSlugPrompt = template.Must(ParseSlugPrompt(DefaultSlugPromptTemplate))
+
+// SlugPromptData is what a slug prompt template is rendered with.
+type SlugPromptData struct {
+	URL    string
+	Domain string
+	// Title and Description describe the page when its metadata is fetched.
+	Title       string
+	Description string
+	// Hints are slugs already generated for the domain.
+	Hints []string
+	// Locale is the language the slugs should be in, such as "en" or "ja".
+	Locale string
+	// Unicode is set when slugs may use letters of any script.
+	Unicode bool
+	// Count is how many slugs are asked for. More than one must be answered
+	// with a JSON array.
+	Count     int
+	MinLength int
+	MaxLength int
+}
+
+// SlugPrompt is the template and slug language used for a tenant.
+type SlugPrompt struct {
+	Template *template.Template
+	Locale   string
+}
+
+// SlugPrompts picks the prompt for each tenant. Tenants without an entry,
+// or whose entry leaves a field empty, use Default.
+type SlugPrompts struct {
+	Default SlugPrompt
+	Tenants map[string]SlugPrompt
+}
+
+// SlugPromptConfig names the prompt template files and slug languages to
+// load. Empty values fall back to the built-in template and English.
+type SlugPromptConfig struct {
+	TemplateFile        string
+	Locale              string
+	TenantTemplateFiles map[string]string
+	TenantLocales       map[string]string
+}
+
+// LoadSlugPrompts reads and checks the templates named by config.
+func LoadSlugPrompts(config SlugPromptConfig) (*SlugPrompts, error) {
+	prompts := &SlugPrompts{Tenants: make(map[string]SlugPrompt)}
+
+	if config.TemplateFile != "" {
+		tmpl, err := ParseSlugPromptFile(config.TemplateFile)
+		if err != nil {
+			return nil, err
+		}
+		prompts.Default.Template = tmpl
+	}
+	prompts.Default.Locale = config.Locale
+
+	for tenant, path := range config.TenantTemplateFiles {
+		tmpl, err := ParseSlugPromptFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant, err)
+		}
+		prompt := prompts.Tenants[tenant]
+		prompt.Template = tmpl
+		prompts.Tenants[tenant] = prompt
+	}
+	for tenant, locale := range config.TenantLocales {
+		prompt := prompts.Tenants[tenant]
+		prompt.Locale = locale
+		prompts.Tenants[tenant] = prompt
+	}
+	return prompts, nil
+}
+
+// ParseSlugPromptFile parses the slug prompt template in the file at path.
+func ParseSlugPromptFile(path string) (*template.Template, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt template: %w", err)
+	}
+	return ParseSlugPrompt(string(text))
+}
+
+// ParseSlugPrompt parses a slug prompt template. Besides the fields of
+// SlugPromptData, templates may use join to list hints. The template is
+// tried out once so a misspelled field fails at startup, not per request.
+func ParseSlugPrompt(text string) (*template.Template, error) {
+	tmpl, err := template.New("slug_prompt").Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	sample := SlugPromptData{
+		URL:       "https://example.com/page",
+		Domain:    "example.com",
+		Locale:    DefaultSlugLocale,
+		Count:     2,
+		MinLength: MinSlugLength,
+		MaxLength: MaxSlugLength,
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// forTenant returns the template and locale to use for tenant. A nil
+// SlugPrompts uses the built-in template in English.
+func (p *SlugPrompts) forTenant(tenant string) (*template.Template, string) {
+	var prompt SlugPrompt
+	if p != nil {
+		prompt = p.Default
+		if override, ok := p.Tenants[tenant]; ok {
+			if override.Template != nil {
+				prompt.Template = override.Template
+			}
+			if override.Locale != "" {
+				prompt.Locale = override.Locale
+			}
+		}
+	}
+
+	if prompt.Template == nil {
+		prompt.Template = defaultSlugPrompt
+	}
+	if prompt.Locale == "" {
+		prompt.Locale = DefaultSlugLocale
+	}
+	return prompt.Template, prompt.Locale
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var ErrDestinationUnreachable = errors.New("destination is not reachable")
+
+// Reachability modes. ReachabilityRequest only checks destinations whose
+// request sets CheckReachability; ReachabilityOn and ReachabilityStrict check
+// every destination, and ReachabilityStrict refuses unreachable ones.
+const (
+	ReachabilityOff     = "off"
+	ReachabilityRequest = "request"
+	ReachabilityOn      = "on"
+	ReachabilityStrict  = "strict"
+)
+
+// ValidateReachabilityMode returns an error unless mode is one of the
+// reachability modes.
+func ValidateReachabilityMode(mode string) error {
+	switch mode {
+	case ReachabilityOff, ReachabilityRequest, ReachabilityOn, ReachabilityStrict:
+		return nil
+	}
+	return fmt.Errorf("unknown reachability mode %q, expected %q, %q, %q or %q",
+		mode, ReachabilityOff, ReachabilityRequest, ReachabilityOn, ReachabilityStrict)
+}
+
+// ReachabilityResult is the outcome of requesting a destination.
+type ReachabilityResult struct {
+	Reachable   bool      `json:"reachable"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	FinalURL    string    `json:"final_url,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+type ReachabilityConfig struct {
+	// Client performs the requests. It should refuse internal addresses,
+	// e.g. one from NewSafeHTTPClient. Its redirect policy is replaced.
+	Client       *http.Client
+	MaxRedirects int
+	// MaxBodyBytes bounds how much of a GET response is read.
+	MaxBodyBytes int64
+}
+
+// ReachabilityChecker requests destinations to catch typos and dead links.
+type ReachabilityChecker struct {
+	client       *http.Client
+	maxBodyBytes int64
+}
+
+func NewReachabilityChecker(config ReachabilityConfig) *ReachabilityChecker {
+	client := NewSafeHTTPClient(5 * time.Second)
+	if config.Client != nil {
+		copied := *config.Client
+		client = &copied
+	}
+
+	// Chains longer than the cap stop at the redirect reached last, which
+	// Check reports as unreachable
+	maxRedirects := config.MaxRedirects
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+
+	maxBodyBytes := config.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = 1 << 20
+	}
+
+	return &ReachabilityChecker{client: client, maxBodyBytes: maxBodyBytes}
+}
+
+// WithReachabilityCheck requests destinations before they are shortened, as
+// mode says, and records the result. In ReachabilityStrict mode, unreachable
+// destinations are refused with ErrDestinationUnreachable.
+func WithReachabilityCheck(checker *ReachabilityChecker, mode string) URLServiceOption {
+	return func(s *URLService) {
+		if mode == ReachabilityOff {
+			return
+		}
+		s.reachability = checker
+		s.reachabilityMode = mode
+	}
+}
+
+// checksReachability reports whether the destination of req is requested.
+func (s *URLService) checksReachability(req URLRequest) bool {
+	if s.reachability == nil {
+		return false
+	}
+	return s.reachabilityMode != ReachabilityRequest || req.CheckReachability
+}
+
+// Check requests rawURL with HEAD, falling back to GET for servers that
+// reject HEAD. It returns nil for destinations that aren't http or https.
+func (c *ReachabilityChecker) Check(ctx context.Context, rawURL string) *ReachabilityResult {
+	u, err := url.Parse(rawURL)
+	if err != nil || !isWebScheme(u.Scheme) {
+		return nil
+	}
+
+	result := &ReachabilityResult{CheckedAt: time.Now().UTC()}
+
+	resp, err := c.request(ctx, http.MethodHead, rawURL)
+	if err == nil && headUnsupported(resp.StatusCode) {
+		resp, err = c.request(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.StatusCode = resp.StatusCode
+	result.FinalURL = resp.Request.URL.String()
+	result.ContentType = resp.Header.Get("Content-Type")
+	result.Reachable = resp.StatusCode < 400
+	// A redirect that was not followed says nothing about its target
+	if isRedirect(resp.StatusCode) {
+		result.Reachable = false
+		result.Error = "too many redirects"
+	}
+	return result
+}
+
+func (c *ReachabilityChecker) request(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "go-url-shortener/1.0 (+link check)")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a bounded part of the body so the server's answer is complete
+	if _, err := io.CopyN(io.Discard, resp.Body, c.maxBodyBytes); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp, nil
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// headUnsupported reports statuses some servers answer HEAD with although
+// GET works.
+func headUnsupported(status int) bool {
+	return status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented ||
+		status == http.StatusForbidden || status == http.StatusNotFound
+}
+
+func reachabilityReason(result *ReachabilityResult) string {
+	if result.Error != "" {
+		return result.Error
+	}
+	return fmt.Sprintf("status %d", result.StatusCode)
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCircuitOpen = errors.New("AI slug generation is paused after repeated failures")
+	aiMetrics      = expvar.NewMap("ai_slugs")
+)
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+type ResilienceConfig struct {
+	// Timeout bounds each attempt.
+	Timeout time.Duration
+	// MaxRetries is how often a failed attempt is repeated.
+	MaxRetries int
+	// RetryBackoff is the base delay before a retry. It doubles per retry
+	// and a random jitter of up to the same amount is added.
+	RetryBackoff time.Duration
+	// FailureThreshold consecutive failed calls open the circuit.
+	FailureThreshold int
+	// OpenDuration is how long an open circuit skips AI before a trial call.
+	OpenDuration time.Duration
+}
+
+// ResilientAISlugService wraps an AI slug service with per-attempt timeouts,
+// bounded retries and a circuit breaker, so a slow or failing provider
+// cannot stall link creation.
+type ResilientAISlugService struct {
+	next   AISlugServiceInterface
+	config ResilienceConfig
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+func NewResilientAISlugService(next AISlugServiceInterface, config ResilienceConfig) *ResilientAISlugService {
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 200 * time.Millisecond
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 5
+	}
+	if config.OpenDuration <= 0 {
+		config.OpenDuration = 30 * time.Second
+	}
+
+	s := &ResilientAISlugService{next: next, config: config, state: CircuitClosed}
+	aiMetrics.Set("circuit", expvar.Func(func() interface{} { return s.State() }))
+	return s
+}
+
+func (s *ResilientAISlugService) GenerateSlug(ctx context.Context, originalURL string) (string, error) {
+	var slug string
+	err := s.call(ctx, func(ctx context.Context) error {
+		var err error
+		slug, err = s.next.GenerateSlug(ctx, originalURL)
+		return err
+	})
+	return slug, err
+}
+
+// GenerateSlugCandidates passes through to the wrapped service, which falls
+// back to a single slug if it can't propose several.
+func (s *ResilientAISlugService) GenerateSlugCandidates(ctx context.Context, originalURL string, n int) ([]string, error) {
+	generator, ok := s.next.(AISlugCandidateGenerator)
+	if !ok {
+		slug, err := s.GenerateSlug(ctx, originalURL)
+		if err != nil || slug == "" {
+			return nil, err
+		}
+		return []string{slug}, nil
+	}
+
+	var candidates []string
+	err := s.call(ctx, func(ctx context.Context) error {
+		var err error
+		candidates, err = generator.GenerateSlugCandidates(ctx, originalURL, n)
+		return err
+	})
+	return candidates, err
+}
+
+// DescribeLink passes through to the wrapped service under the same
+// timeouts, retries and circuit breaker as slug generation.
+func (s *ResilientAISlugService) DescribeLink(ctx context.Context, originalURL string) (*LinkDescription, error) {
+	describer, ok := s.next.(AILinkDescriber)
+	if !ok {
+		return nil, ErrDescriptionsUnsupported
+	}
+
+	var description *LinkDescription
+	err := s.call(ctx, func(ctx context.Context) error {
+		var err error
+		description, err = describer.DescribeLink(ctx, originalURL)
+		return err
+	})
+	return description, err
+}
+
+// State returns the circuit breaker state: CircuitClosed, CircuitOpen or
+// CircuitHalfOpen.
+func (s *ResilientAISlugService) State() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == CircuitOpen && !time.Now().Before(s.openUntil) {
+		return CircuitHalfOpen
+	}
+	return s.state
+}
+
+func (s *ResilientAISlugService) call(ctx context.Context, attempt func(context.Context) error) error {
+	if !s.allow() {
+		aiMetrics.Add("skipped", 1)
+		return ErrCircuitOpen
+	}
+	aiMetrics.Add("calls", 1)
+
+	var err error
+	var retryAfter time.Duration
+	for try := 0; try <= s.config.MaxRetries; try++ {
+		if try > 0 {
+			aiMetrics.Add("retries", 1)
+			if waitErr := s.backoff(ctx, try, retryAfter); waitErr != nil {
+				s.release()
+				return err
+			}
+		}
+
+		attemptCtx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+		err = attempt(attemptCtx)
+		timedOut := errors.Is(attemptCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+		cancel()
+
+		if err == nil {
+			s.record(true)
+			return nil
+		}
+		// A spent budget says nothing about the provider's health
+		if errors.Is(err, ErrAIBudgetExceeded) {
+			s.release()
+			return err
+		}
+		// The caller gave up; that says nothing about the provider either
+		if ctx.Err() != nil {
+			s.release()
+			return err
+		}
+		if timedOut {
+			aiMetrics.Add("timeouts", 1)
+			err = fmt.Errorf("AI slug generation timed out after %s: %w", s.config.Timeout, err)
+			retryAfter = 0
+			continue
+		}
+		// Rejected requests and unusable answers would fail the same way
+		// again, and say nothing about the provider's health either
+		if !retryable(err) {
+			aiMetrics.Add("failures", 1)
+			s.release()
+			return err
+		}
+		// Waits longer than an attempt may take are not worth it
+		if retryAfter = providerRetryAfter(err); retryAfter > s.config.Timeout {
+			break
+		}
+	}
+
+	aiMetrics.Add("failures", 1)
+	s.record(false)
+	return err
+}
+
+// retryable reports whether a failed attempt may succeed when repeated: the
+// provider could not be reached, was rate limited or failed on its side.
+func retryable(err error) bool {
+	if status := providerStatus(err); status != 0 {
+		return status == http.StatusTooManyRequests || status >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// backoff sleeps before retry number try, at least as long as the provider
+// asked with retryAfter, returning early if ctx ends.
+func (s *ResilientAISlugService) backoff(ctx context.Context, try int, retryAfter time.Duration) error {
+	delay := s.config.RetryBackoff << (try - 1)
+	delay += time.Duration(rand.Int63n(int64(s.config.RetryBackoff)))
+	if delay < retryAfter {
+		delay = retryAfter
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// allow reports whether a call may go ahead. Once an open circuit's wait is
+// over, a single trial call is let through.
+func (s *ResilientAISlugService) allow() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.state {
+	case CircuitOpen:
+		if time.Now().Before(s.openUntil) {
+			return false
+		}
+		s.state, s.trial = CircuitHalfOpen, true
+		return true
+	case CircuitHalfOpen:
+		if s.trial {
+			return false
+		}
+		s.trial = true
+		return true
+	}
+	return true
+}
+
+// release ends a call without counting it for or against the provider.
+func (s *ResilientAISlugService) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trial = false
+}
+
+func (s *ResilientAISlugService) record(success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trial = false
+	if success {
+		s.failures = 0
+		if s.state != CircuitClosed {
+			log.Printf("AI slug circuit closed")
+			s.state = CircuitClosed
+		}
+		return
+	}
+
+	s.failures++
+	if s.state == CircuitHalfOpen || s.failures >= s.config.FailureThreshold {
+		log.Printf("AI slug circuit opened after %d failures, skipping AI for %s", s.failures, s.config.OpenDuration)
+		s.state = CircuitOpen
+		s.openUntil = time.Now().Add(s.config.OpenDuration)
+		aiMetrics.Add("circuit_opened", 1)
+	}
+}
@@ -0,0 +1,460 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"fmt"
+	"go-url-shortner/utils"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrThreatDetected = errors.New("destination is listed as malicious")
+	scannerMetrics    = expvar.NewMap("threat_scanner")
+)
+
+const (
+	ThreatActionBlock = "block"
+	ThreatActionWarn  = "warn"
+)
+
+type ScanResult struct {
+	Flagged bool
+	// Source names the feed that listed the destination.
+	Source string
+}
+
+// Scanner checks destinations against threat intelligence.
+type Scanner interface {
+	Scan(ctx context.Context, rawURL string) (*ScanResult, error)
+}
+
+// FlaggedLinkError is returned by GetOriginalURL for links whose destination
+// was flagged; callers should warn before sending users there.
+type FlaggedLinkError struct {
+	OriginalURL string
+	Reason      string
+}
+
+func (e *FlaggedLinkError) Error() string {
+	return fmt.Sprintf("destination flagged: %s", e.Reason)
+}
+
+// ValidateThreatAction returns an error unless action is ThreatActionBlock or
+// ThreatActionWarn.
+func ValidateThreatAction(action string) error {
+	switch action {
+	case ThreatActionBlock, ThreatActionWarn:
+		return nil
+	}
+	return fmt.Errorf("unknown threat action %q, expected %q or %q", action, ThreatActionBlock, ThreatActionWarn)
+}
+
+// WithThreatScanner scans destinations before they are shortened. Flagged
+// destinations are refused with ErrThreatDetected when action is "block";
+// otherwise the link is created and flagged so redirects show a warning.
+func WithThreatScanner(scanner Scanner, flags ThreatFlagStore, action string) URLServiceOption {
+	return func(s *URLService) {
+		s.scanner = scanner
+		s.threatFlags = flags
+		s.threatAction = action
+	}
+}
+
+// scanDestination returns the scan result for a flagged destination that may
+// still be shortened, or ErrThreatDetected if it must be refused.
+func (s *URLService) scanDestination(ctx context.Context, originalURL string) (*ScanResult, error) {
+	if s.scanner == nil {
+		return nil, nil
+	}
+
+	result, err := s.scanner.Scan(ctx, originalURL)
+	if err != nil {
+		log.Printf("Threat scan failed for %s: %v", utils.RedactURL(originalURL), err)
+		return nil, nil
+	}
+	if !result.Flagged {
+		return nil, nil
+	}
+
+	if s.threatAction != ThreatActionWarn || s.threatFlags == nil {
+		scannerMetrics.Add("blocked", 1)
+		log.Printf("Refused destination listed by %s: %s", result.Source, utils.RedactURL(originalURL))
+		return nil, fmt.Errorf("%w: listed by %s", ErrThreatDetected, result.Source)
+	}
+
+	scannerMetrics.Add("flagged_on_create", 1)
+	return result, nil
+}
+
+// checkFlag returns a FlaggedLinkError if code has been flagged.
+func (s *URLService) checkFlag(ctx context.Context, code, originalURL string) error {
+	if s.threatFlags == nil {
+		return nil
+	}
+
+	reason, flagged, err := s.threatFlags.GetFlag(ctx, code)
+	if err != nil {
+		log.Printf("Failed to check threat flag for '%s': %v", code, err)
+		return nil
+	}
+	if !flagged {
+		return nil
+	}
+	return &FlaggedLinkError{OriginalURL: originalURL, Reason: reason}
+}
+
+// copyFlag flags code to as code from is, so an alias of a flagged link
+// shows the same warning.
+func (s *URLService) copyFlag(ctx context.Context, from, to string) {
+	if s.threatFlags == nil {
+		return
+	}
+
+	reason, flagged, err := s.threatFlags.GetFlag(ctx, from)
+	if err != nil {
+		log.Printf("Failed to check threat flag for '%s': %v", from, err)
+		return
+	}
+	if !flagged {
+		return
+	}
+	if err := s.threatFlags.FlagURL(ctx, to, reason); err != nil {
+		log.Printf("Failed to flag '%s': %v", to, err)
+	}
+}
+
+// clearFlag removes the flag of a code that no longer points at the flagged
+// destination, so the next link to use the code is not shown a warning.
+func (s *URLService) clearFlag(ctx context.Context, code string) {
+	if s.threatFlags == nil {
+		return
+	}
+
+	if err := s.threatFlags.ClearFlag(ctx, code); err != nil {
+		log.Printf("Failed to clear threat flag for '%s': %v", code, err)
+	}
+}
+
+// FeedConfig lists local threat feed files. Each list may be empty.
+type FeedConfig struct {
+	// Hosts files contain "0.0.0.0 bad.example" or bare host names.
+	HostsFiles []string
+	// URLhaus CSV exports list one malicious URL per row.
+	URLhausFiles []string
+	// Hash prefix files hold hex SHA-256 prefixes of Safe Browsing style
+	// URL expressions, one per line.
+	HashPrefixFiles []string
+}
+
+type threatFeeds struct {
+	hosts        map[string]string
+	urls         map[string]string
+	hashPrefixes map[string]string
+	prefixLens   []int
+}
+
+// FeedScanner matches destinations against feeds loaded from disk.
+type FeedScanner struct {
+	config FeedConfig
+	feeds  atomic.Pointer[threatFeeds]
+}
+
+func NewFeedScanner(config FeedConfig) (*FeedScanner, error) {
+	s := &FeedScanner{config: config}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads all feed files. On error the previous feeds stay in use.
+func (s *FeedScanner) Reload() error {
+	feeds := &threatFeeds{
+		hosts:        make(map[string]string),
+		urls:         make(map[string]string),
+		hashPrefixes: make(map[string]string),
+	}
+
+	loaders := []struct {
+		files []string
+		load  func(*threatFeeds, io.Reader, string) error
+	}{
+		{s.config.HostsFiles, loadHostsFeed},
+		{s.config.URLhausFiles, loadURLhausFeed},
+		{s.config.HashPrefixFiles, loadHashPrefixFeed},
+	}
+
+	for _, loader := range loaders {
+		for _, path := range loader.files {
+			if err := loadFeedFile(feeds, path, loader.load); err != nil {
+				return err
+			}
+		}
+	}
+
+	lens := make(map[int]bool)
+	for prefix := range feeds.hashPrefixes {
+		lens[len(prefix)] = true
+	}
+	for n := range lens {
+		feeds.prefixLens = append(feeds.prefixLens, n)
+	}
+
+	s.feeds.Store(feeds)
+	log.Printf("Loaded threat feeds: %d hosts, %d URLs, %d hash prefixes", len(feeds.hosts), len(feeds.urls), len(feeds.hashPrefixes))
+	return nil
+}
+
+func (s *FeedScanner) Scan(ctx context.Context, rawURL string) (*ScanResult, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL for scanning: %w", err)
+	}
+
+	feeds := s.feeds.Load()
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	if source, ok := feeds.hosts[host]; ok {
+		return &ScanResult{Flagged: true, Source: source}, nil
+	}
+	if source, ok := feeds.urls[feedURLKey(u)]; ok {
+		return &ScanResult{Flagged: true, Source: source}, nil
+	}
+
+	for _, expression := range urlExpressions(host, u) {
+		sum := sha256.Sum256([]byte(expression))
+		digest := hex.EncodeToString(sum[:])
+		for _, n := range feeds.prefixLens {
+			if source, ok := feeds.hashPrefixes[digest[:n]]; ok {
+				return &ScanResult{Flagged: true, Source: source}, nil
+			}
+		}
+	}
+
+	return &ScanResult{}, nil
+}
+
+func loadFeedFile(feeds *threatFeeds, path string, load func(*threatFeeds, io.Reader, string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open threat feed: %w", err)
+	}
+	defer file.Close()
+
+	if err := load(feeds, file, path); err != nil {
+		return fmt.Errorf("failed to load threat feed %s: %w", path, err)
+	}
+	return nil
+}
+
+func loadHostsFeed(feeds *threatFeeds, r io.Reader, source string) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Skip the sinkhole address in "0.0.0.0 bad.example" entries
+		hosts := fields
+		if net.ParseIP(fields[0]) != nil {
+			hosts = fields[1:]
+		}
+		for _, host := range hosts {
+			host = strings.TrimSuffix(strings.ToLower(host), ".")
+			if host != "localhost" && host != "" {
+				feeds.hosts[host] = source
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func loadURLhausFeed(feeds *threatFeeds, r io.Reader, source string) error {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// id, dateadded, url, url_status, ...
+		if len(record) < 3 {
+			continue
+		}
+		if u, err := url.Parse(strings.TrimSpace(record[2])); err == nil && u.Host != "" {
+			feeds.urls[feedURLKey(u)] = source
+		}
+	}
+}
+
+func loadHashPrefixFeed(feeds *threatFeeds, r io.Reader, source string) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		prefix := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if prefix == "" || strings.HasPrefix(prefix, "#") {
+			continue
+		}
+		if _, err := hex.DecodeString(prefix); err != nil || len(prefix) < 8 || len(prefix) > 64 {
+			return fmt.Errorf("invalid hash prefix %q", prefix)
+		}
+		feeds.hashPrefixes[prefix] = source
+	}
+	return scanner.Err()
+}
+
+// feedURLKey reduces a URL to the form feed entries are compared in.
+func feedURLKey(u *url.URL) string {
+	key := strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + u.EscapedPath()
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return strings.TrimSuffix(key, "/")
+}
+
+// urlExpressions returns the host suffix / path prefix combinations used by
+// Safe Browsing style lists: up to five host suffixes and six path prefixes.
+func urlExpressions(host string, u *url.URL) []string {
+	hosts := []string{host}
+	if net.ParseIP(host) == nil {
+		labels := strings.Split(host, ".")
+		start := len(labels) - 5
+		if start < 1 {
+			start = 1
+		}
+		for i := start; i < len(labels)-1; i++ {
+			hosts = append(hosts, strings.Join(labels[i:], "."))
+		}
+	}
+
+	urlPath := u.EscapedPath()
+	if urlPath == "" {
+		urlPath = "/"
+	}
+	paths := []string{}
+	if u.RawQuery != "" {
+		paths = append(paths, urlPath+"?"+u.RawQuery)
+	}
+	paths = append(paths, urlPath)
+
+	segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+	prefix := "/"
+	paths = append(paths, prefix)
+	for i := 0; i < len(segments)-1 && len(paths) < 6; i++ {
+		prefix += segments[i] + "/"
+		paths = append(paths, prefix)
+	}
+
+	seen := make(map[string]bool)
+	var expressions []string
+	for _, h := range hosts {
+		for _, p := range paths {
+			expression := h + p
+			if !seen[expression] {
+				seen[expression] = true
+				expressions = append(expressions, expression)
+			}
+		}
+	}
+	return expressions
+}
+
+// ThreatRescanner periodically re-checks existing links against the feeds
+// and flags those whose destination became known as malicious.
+type ThreatRescanner struct {
+	scanner Scanner
+	links   LinkIterator
+	flags   ThreatFlagStore
+}
+
+func NewThreatRescanner(scanner Scanner, links LinkIterator, flags ThreatFlagStore) *ThreatRescanner {
+	return &ThreatRescanner{scanner: scanner, links: links, flags: flags}
+}
+
+// Rescan checks every stored link once and returns how many were flagged.
+// Links whose destination is no longer listed lose their flag.
+func (r *ThreatRescanner) Rescan(ctx context.Context) (int, error) {
+	flagged := 0
+	err := r.links.ForEachURL(ctx, func(code, originalURL string) error {
+		scannerMetrics.Add("rescanned", 1)
+
+		result, err := r.scanner.Scan(ctx, originalURL)
+		if err != nil {
+			return nil
+		}
+		if !result.Flagged {
+			return r.unflag(ctx, code)
+		}
+
+		if err := r.flags.FlagURL(ctx, code, result.Source); err != nil {
+			return err
+		}
+		flagged++
+		scannerMetrics.Add("flagged_on_rescan", 1)
+		log.Printf("Flagged existing link '%s' listed by %s", code, result.Source)
+		return nil
+	})
+	return flagged, err
+}
+
+func (r *ThreatRescanner) unflag(ctx context.Context, code string) error {
+	_, wasFlagged, err := r.flags.GetFlag(ctx, code)
+	if err != nil || !wasFlagged {
+		return err
+	}
+
+	if err := r.flags.ClearFlag(ctx, code); err != nil {
+		return err
+	}
+	scannerMetrics.Add("unflagged_on_rescan", 1)
+	log.Printf("Cleared flag of link '%s' no longer listed", code)
+	return nil
+}
+
+// Run rescans every interval until ctx is cancelled, reloading feeds first
+// when the scanner supports it.
+func (r *ThreatRescanner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if reloader, ok := r.scanner.(interface{ Reload() error }); ok {
+			if err := reloader.Reload(); err != nil {
+				log.Printf("Threat feed reload failed, keeping previous feeds: %v", err)
+			}
+		}
+		if _, err := r.Rescan(ctx); err != nil {
+			log.Printf("Threat rescan failed: %v", err)
+		}
+	}
+}
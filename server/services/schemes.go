@@ -0,0 +1,220 @@
+package services
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Machine-readable codes for rejected schemes.
+const (
+	CodeUnsupportedScheme = "unsupported_scheme"
+	CodeInvalidSchemeURL  = "invalid_scheme_url"
+)
+
+var (
+	schemePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+	phonePattern  = regexp.MustCompile(`^\+?[0-9]{3,15}$`)
+)
+
+// Schemes that can run code or read local data in the browser and may never
+// be shortened.
+var forbiddenSchemes = map[string]bool{
+	"javascript": true,
+	"vbscript":   true,
+	"data":       true,
+	"file":       true,
+	"blob":       true,
+	"about":      true,
+}
+
+// SchemePolicy allows destinations outside http and https, such as mailto:,
+// tel:, sms:, geo: or app deep links like myapp://. Well-known schemes get
+// their own validation; other schemes only need a non-empty target.
+type SchemePolicy struct {
+	allowed map[string]bool
+	handoff map[string]bool
+}
+
+// NewSchemePolicy allows the given schemes in addition to http and https.
+// Destinations using a scheme in handoff are opened from an HTML page instead
+// of a redirect, for clients that don't follow redirects to custom schemes.
+func NewSchemePolicy(allowed, handoff []string) (*SchemePolicy, error) {
+	p := &SchemePolicy{
+		allowed: make(map[string]bool),
+		handoff: make(map[string]bool),
+	}
+
+	for _, scheme := range allowed {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		if !schemePattern.MatchString(scheme) {
+			return nil, fmt.Errorf("invalid scheme %q", scheme)
+		}
+		if forbiddenSchemes[scheme] {
+			return nil, fmt.Errorf("scheme %q may not be allowed", scheme)
+		}
+		p.allowed[scheme] = true
+	}
+
+	for _, scheme := range handoff {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		if !p.allowed[scheme] && !isWebScheme(scheme) {
+			return nil, fmt.Errorf("handoff scheme %q is not allowed", scheme)
+		}
+		p.handoff[scheme] = true
+	}
+
+	return p, nil
+}
+
+// Allows reports whether scheme is allowed besides http and https.
+func (p *SchemePolicy) Allows(scheme string) bool {
+	return p != nil && p.allowed[strings.ToLower(scheme)]
+}
+
+// Handoff reports whether rawURL should be opened from an HTML handoff page.
+func (p *SchemePolicy) Handoff(rawURL string) bool {
+	if p == nil {
+		return false
+	}
+	scheme, _, ok := strings.Cut(rawURL, ":")
+	return ok && p.handoff[strings.ToLower(scheme)]
+}
+
+// validate checks a destination using an allowed non-web scheme.
+func (p *SchemePolicy) validate(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return newValidationError(CodeInvalidSchemeURL, fmt.Sprintf("invalid URL format: %v", err))
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	target := strings.TrimPrefix(rawURL[len(u.Scheme)+1:], "//")
+	if strings.TrimSpace(target) == "" {
+		return newValidationError(CodeInvalidSchemeURL, fmt.Sprintf("%s: URL has no target", scheme))
+	}
+	for _, r := range rawURL {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return newValidationError(CodeInvalidSchemeURL, fmt.Sprintf("%s: URL contains whitespace or control characters", scheme))
+		}
+	}
+
+	switch scheme {
+	case "mailto":
+		err = validateMailto(u)
+	case "tel":
+		err = validatePhoneNumbers(u.Opaque, false)
+	case "sms":
+		err = validatePhoneNumbers(u.Opaque, true)
+	case "geo":
+		err = validateGeo(u.Opaque)
+	}
+	if err != nil {
+		return newValidationError(CodeInvalidSchemeURL, fmt.Sprintf("%s: %v", scheme, err))
+	}
+	return nil
+}
+
+// validateMailto requires at least one valid recipient (RFC 6068).
+func validateMailto(u *url.URL) error {
+	to, err := url.PathUnescape(u.Opaque)
+	if err != nil {
+		return fmt.Errorf("invalid escape in address")
+	}
+	if to == "" {
+		return fmt.Errorf("no recipient")
+	}
+
+	for _, address := range strings.Split(to, ",") {
+		if _, err := mail.ParseAddress(address); err != nil {
+			return fmt.Errorf("invalid address %q", address)
+		}
+	}
+	return nil
+}
+
+// validatePhoneNumbers checks tel: and sms: numbers (RFC 3966, RFC 5724),
+// ignoring visual separators and ";" parameters. sms: allows several
+// comma-separated recipients.
+func validatePhoneNumbers(opaque string, multiple bool) error {
+	numbers, err := url.PathUnescape(opaque)
+	if err != nil {
+		return fmt.Errorf("invalid escape in number")
+	}
+
+	recipients := []string{numbers}
+	if multiple {
+		recipients = strings.Split(numbers, ",")
+	}
+
+	for _, number := range recipients {
+		number, _, _ = strings.Cut(number, ";")
+		digits := strings.NewReplacer("-", "", ".", "", "(", "", ")", "", " ", "").Replace(number)
+		if !phonePattern.MatchString(digits) {
+			return fmt.Errorf("invalid phone number %q", number)
+		}
+	}
+	return nil
+}
+
+// validateGeo checks a geo: URI of the form lat,lon[,alt][;params] (RFC 5870).
+func validateGeo(opaque string) error {
+	coordinates, _, _ := strings.Cut(opaque, ";")
+	parts := strings.Split(coordinates, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return fmt.Errorf("expected latitude,longitude")
+	}
+
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return fmt.Errorf("invalid coordinate %q", part)
+		}
+		values[i] = value
+	}
+
+	if values[0] < -90 || values[0] > 90 {
+		return fmt.Errorf("latitude out of range")
+	}
+	if values[1] < -180 || values[1] > 180 {
+		return fmt.Errorf("longitude out of range")
+	}
+	return nil
+}
+
+// Schemes recognised without "//" even when not allowed, so that e.g.
+// "mailto:me@example.com" isn't read as userinfo and host.
+var knownSchemes = map[string]bool{
+	"mailto": true,
+	"tel":    true,
+	"sms":    true,
+	"geo":    true,
+}
+
+// explicitScheme returns the lowercased scheme of urlStr if it starts with
+// one. Only "scheme://" forms, known and allowed schemes count, so that
+// inputs such as "example.com:8080" keep being read as host and port.
+func (p *SchemePolicy) explicitScheme(urlStr string) (string, bool) {
+	scheme, rest, ok := strings.Cut(urlStr, ":")
+	if !ok {
+		return "", false
+	}
+
+	scheme = strings.ToLower(scheme)
+	if !schemePattern.MatchString(scheme) {
+		return "", false
+	}
+	if strings.HasPrefix(rest, "//") || knownSchemes[scheme] || forbiddenSchemes[scheme] || p.Allows(scheme) {
+		return scheme, true
+	}
+	return "", false
+}
+
+func isWebScheme(scheme string) bool {
+	return scheme == "http" || scheme == "https"
+}
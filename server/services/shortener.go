@@ -5,38 +5,111 @@ import (
 	"fmt"
 	"go-url-shortner/utils"
 	"log"
+	"net/url"
+	"strings"
+	"time"
 )
 
 type URLRequest struct {
-	URL string `json:"url"`
+	URL   string `json:"url"`
+	Alias string `json:"alias,omitempty"`
+	// Describe asks the AI service for a summary and tags of the destination.
+	Describe bool `json:"describe,omitempty"`
+	// CheckReachability asks for the destination to be requested before it
+	// is shortened when the reachability check runs on request.
+	CheckReachability bool `json:"check_reachability,omitempty"`
 }
 
 type URLResponse struct {
-	OriginalURL string `json:"original_url"`
-	ShortCode   string `json:"short_code"`
-	ShortURL    string `json:"short_url"`
-	SlugType    string `json:"slug_type"`
+	OriginalURL  string              `json:"original_url"`
+	ShortCode    string              `json:"short_code"`
+	ShortURL     string              `json:"short_url"`
+	CanonicalURL string              `json:"canonical_url"`
+	DisplayURL   string              `json:"display_url,omitempty"`
+	SlugType     string              `json:"slug_type"`
+	Reachability *ReachabilityResult `json:"reachability,omitempty"`
+	AISlugStatus string              `json:"ai_slug_status,omitempty"`
+	Summary      string              `json:"summary,omitempty"`
+	Tags         []string            `json:"tags,omitempty"`
+	Warnings     []string            `json:"warnings,omitempty"`
 }
 
 type URLService struct {
-	storage    StorageInterface
-	aiService  AISlugServiceInterface
-	serverHost string
-	serverPort string
+	storage          StorageInterface
+	aiService        AISlugServiceInterface
+	serverHost       string
+	serverPort       string
+	unicodeSlugs     bool
+	foldCase         bool
+	codePool         *CodePool
+	tombstones       TombstoneStore
+	quarantine       time.Duration
+	linkTTL          time.Duration
+	scanner          Scanner
+	threatFlags      ThreatFlagStore
+	threatAction     string
+	canonicalRules   *CanonicalRules
+	chain            *ChainResolver
+	metadata         MetadataStore
+	reachability     *ReachabilityChecker
+	reachabilityMode string
+	aiCandidates     int
+	upgrader         *SlugUpgrader
+}
+
+// URLServiceOption configures optional URLService behaviour.
+type URLServiceOption func(*URLService)
+
+// WithUnicodeSlugs allows custom aliases outside ASCII, such as /café or /🎉.
+func WithUnicodeSlugs(enabled bool) URLServiceOption {
+	return func(s *URLService) {
+		s.unicodeSlugs = enabled
+	}
+}
+
+// WithCaseInsensitiveCodes generates new codes in case-folded form and lets
+// lookups match them regardless of case. Codes created before the switch keep
+// resolving because lookups try the exact code before the folded one.
+func WithCaseInsensitiveCodes(enabled bool) URLServiceOption {
+	return func(s *URLService) {
+		s.foldCase = enabled
+	}
+}
+
+// WithCodePool takes codes from a pre-generated pool when neither a custom
+// alias nor an AI slug is used, instead of hashing the URL.
+func WithCodePool(pool *CodePool) URLServiceOption {
+	return func(s *URLService) {
+		s.codePool = pool
+	}
+}
+
+// WithAISlugCandidates asks the AI service for n ranked slugs and uses the
+// first available one, if the service supports AISlugCandidateGenerator.
+func WithAISlugCandidates(n int) URLServiceOption {
+	return func(s *URLService) {
+		s.aiCandidates = n
+	}
 }
 
 const (
 	aiGenerated = "ai_generated"
 	hashBased   = "hash_based"
+	customAlias = "custom"
+	pooled      = "pooled"
 )
 
-func NewURLService(storage StorageInterface, aiService AISlugServiceInterface, serverHost, serverPort string) *URLService {
-	return &URLService{
+func NewURLService(storage StorageInterface, aiService AISlugServiceInterface, serverHost, serverPort string, opts ...URLServiceOption) *URLService {
+	s := &URLService{
 		storage:    storage,
 		aiService:  aiService,
 		serverHost: serverHost,
 		serverPort: serverPort,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *URLService) CreateShortURL(ctx context.Context, req URLRequest) (*URLResponse, error) {
@@ -48,49 +121,383 @@ func (s *URLService) CreateShortURL(ctx context.Context, req URLRequest) (*URLRe
 		return nil, fmt.Errorf("URL is required")
 	}
 
-	if s.aiService != nil {
-		aiSlug, aiErr := s.aiService.GenerateSlug(ctx, req.URL)
+	// Equivalent spellings of a destination share one code
+	destination, err := s.canonicalize(req.URL)
+	if err != nil {
+		return nil, err
+	}
 
-		if aiErr == nil && aiSlug != "" {
-			if s.isSlugAvailable(ctx, aiSlug) {
-				shortCode = aiSlug
-				slugType = aiGenerated
-				log.Printf("Using AI-generated slug: %s", shortCode)
-			} else {
-				log.Printf("AI-generated slug '%s' already exists, falling back to hash", aiSlug)
-			}
-		} else {
+	// Point straight at the final destination instead of another short link
+	if s.chain != nil {
+		resolved, err := s.chain.Resolve(ctx, destination, s.GetOriginalURL)
+		if err != nil {
+			return nil, err
+		}
+		if destination, err = s.canonicalize(resolved); err != nil {
+			return nil, err
+		}
+	}
+
+	threat, err := s.scanDestination(ctx, destination)
+	if err != nil {
+		return nil, err
+	}
+
+	var reachability *ReachabilityResult
+	if s.checksReachability(req) {
+		reachability = s.reachability.Check(ctx, destination)
+		if s.reachabilityMode == ReachabilityStrict && reachability != nil && !reachability.Reachable {
+			return nil, fmt.Errorf("%w: %s", ErrDestinationUnreachable, reachabilityReason(reachability))
+		}
+	}
+
+	if req.Alias != "" {
+		shortCode, err = s.claimAlias(ctx, req.Alias, destination)
+		if err != nil {
+			return nil, err
+		}
+		slugType = customAlias
+		log.Printf("Using custom alias: %s", shortCode)
+	} else if s.aiService != nil && s.upgrader == nil {
+		candidates, aiErr := s.aiSlugCandidates(ctx, destination)
+		if aiErr != nil {
 			log.Printf("AI slug generation failed: %v, falling back to hash", aiErr)
 		}
+
+		for _, aiSlug := range candidates {
+			if s.foldCase {
+				aiSlug = utils.FoldCase(aiSlug)
+			}
+			if !s.isSlugAvailable(ctx, aiSlug, destination) {
+				log.Printf("AI-generated slug '%s' already exists", aiSlug)
+				continue
+			}
+			// Another request may have claimed the slug since it was checked
+			stored, err := s.storage.StoreURLIfAbsent(ctx, aiSlug, destination)
+			if err != nil {
+				return nil, fmt.Errorf("failed to store URL: %w", err)
+			}
+			if !stored {
+				log.Printf("AI-generated slug '%s' was claimed concurrently", aiSlug)
+				continue
+			}
+			shortCode = aiSlug
+			slugType = aiGenerated
+			log.Printf("Using AI-generated slug: %s", shortCode)
+			break
+		}
+		if aiErr == nil && shortCode == "" {
+			log.Printf("No AI-generated slug is available, falling back to hash")
+			s.forgetAISlugs(ctx, destination)
+		}
+	}
+
+	if shortCode == "" && s.codePool != nil {
+		shortCode = s.takePooledCode(ctx, destination)
+		if shortCode != "" {
+			slugType = pooled
+			log.Printf("Using pooled slug: %s", shortCode)
+		}
 	}
 
 	// Fallback to hash-based slug if AI failed or slug is unavailable
 	if shortCode == "" {
-		shortCode = utils.ShortHash(req.URL)
+		shortCode, err = s.hashCode(ctx, destination)
+		if err != nil {
+			return nil, err
+		}
 		slugType = hashBased
 		log.Printf("Using hash-based slug: %s", shortCode)
 	}
 
-	err = s.storage.StoreURL(ctx, shortCode, req.URL)
+	switch slugType {
+	case aiGenerated:
+		// Claimed when it was chosen
+	case customAlias:
+		// Another request may have claimed the alias since it was checked
+		stored, err := s.storage.StoreURLIfAbsent(ctx, shortCode, destination)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store URL: %w", err)
+		}
+		if !stored {
+			return nil, fmt.Errorf("%w: %s", ErrAliasTaken, shortCode)
+		}
+	default:
+		if err = s.storage.StoreURL(ctx, shortCode, destination); err != nil {
+			return nil, fmt.Errorf("failed to store URL: %w", err)
+		}
+	}
+
+	log.Printf("Stored URL mapping - Short: %s, Original: %s", shortCode, utils.RedactURL(destination))
+
+	s.recordTombstone(ctx, shortCode, destination)
+
+	response := &URLResponse{
+		OriginalURL:  req.URL,
+		CanonicalURL: destination,
+		ShortCode:    shortCode,
+		ShortURL:     s.shortURL(shortCode),
+		SlugType:     slugType,
+		DisplayURL:   displayURL(destination),
+		Reachability: reachability,
+	}
+
+	// Re-shortening a destination keeps the metadata of its existing link,
+	// including the AI slug it already has or is waiting for
+	upgrade := false
+	metadata := s.existingMetadata(ctx, shortCode, destination)
+	if metadata == nil {
+		// Hash and pooled codes get their AI slug in the background
+		upgrade = s.upgrader != nil && s.aiService != nil && slugType != customAlias
+		metadata = &LinkMetadata{
+			ShortCode:   shortCode,
+			OriginalURL: destination,
+			SlugType:    slugType,
+			CreatedAt:   time.Now().UTC(),
+		}
+		if upgrade {
+			metadata.AISlugStatus = AISlugPending
+		}
+	}
+	if reachability != nil {
+		metadata.Reachability = reachability
+	}
+	response.AISlugStatus = metadata.AISlugStatus
+	// A new description replaces the stored one; otherwise the link keeps
+	// the summary and tags it was described with before
+	if req.Describe {
+		if description := s.describeLink(ctx, destination); description != nil {
+			metadata.Summary, metadata.Tags = description.Summary, description.Tags
+		} else {
+			response.Warnings = append(response.Warnings, "link description is unavailable")
+		}
+	}
+	response.Summary, response.Tags = metadata.Summary, metadata.Tags
+	s.saveMetadata(ctx, metadata)
+
+	if warning := homographWarning(destination); warning != "" {
+		response.Warnings = append(response.Warnings, warning)
+	}
+
+	if threat != nil {
+		if err := s.threatFlags.FlagURL(ctx, shortCode, threat.Source); err != nil {
+			return nil, fmt.Errorf("failed to flag URL: %w", err)
+		}
+		response.Warnings = append(response.Warnings, fmt.Sprintf("destination is listed by threat feed %s", threat.Source))
+	}
+
+	// Queued after flagging, so the upgrade can carry the flag over
+	if upgrade {
+		response.AISlugStatus = s.queueSlugUpgrade(ctx, shortCode, destination)
+	}
+
+	return response, nil
+}
+
+// existingMetadata returns the metadata of code if it already is a link to
+// destination, or nil.
+func (s *URLService) existingMetadata(ctx context.Context, code, destination string) *LinkMetadata {
+	metadata, err := s.loadMetadata(ctx, code)
 	if err != nil {
-		return nil, fmt.Errorf("failed to store URL: %w", err)
+		log.Printf("Failed to load metadata for '%s': %v", code, err)
+		return nil
 	}
+	if metadata == nil || metadata.OriginalURL != destination {
+		return nil
+	}
+	return metadata
+}
 
-	log.Printf("Stored URL mapping - Short: %s, Original: %s", shortCode, req.URL)
+// describeLink returns the AI's summary and tags for destination, or nil
+// when AI is disabled or fails. Links are created either way.
+func (s *URLService) describeLink(ctx context.Context, destination string) *LinkDescription {
+	describer, ok := s.aiService.(AILinkDescriber)
+	if !ok {
+		return nil
+	}
 
-	return &URLResponse{
-		OriginalURL: req.URL,
-		ShortCode:   shortCode,
-		ShortURL:    fmt.Sprintf("http://%s:%s/%s", s.serverHost, s.serverPort, shortCode),
-		SlugType:    slugType,
-	}, nil
+	// Credentials are never sent to the AI provider
+	description, err := describer.DescribeLink(ctx, utils.RedactURL(destination))
+	if err != nil {
+		log.Printf("AI link description failed: %v", err)
+		return nil
+	}
+	return description
+}
+
+// takePooledCode takes a code from the pool, discarding codes retired since
+// they were pooled. It returns "" when the pool has none to give.
+func (s *URLService) takePooledCode(ctx context.Context, destination string) string {
+	for attempt := 0; attempt < maxPoolTakes; attempt++ {
+		code, ok := s.codePool.Take(ctx)
+		if !ok {
+			return ""
+		}
+		if !isReservedCode(code) && !s.isRetiredFor(ctx, code, destination) {
+			return code
+		}
+		log.Printf("Discarding retired or reserved pooled code: %s", code)
+	}
+	return ""
+}
+
+// aiSlugCandidates returns the AI service's slugs for originalURL, best first.
+func (s *URLService) aiSlugCandidates(ctx context.Context, originalURL string) ([]string, error) {
+	// Credentials are never sent to the AI provider
+	originalURL = utils.RedactURL(originalURL)
+	if generator, ok := s.aiService.(AISlugCandidateGenerator); ok && s.aiCandidates > 1 {
+		return generator.GenerateSlugCandidates(ctx, originalURL, s.aiCandidates)
+	}
+
+	slug, err := s.aiService.GenerateSlug(ctx, originalURL)
+	if err != nil {
+		return nil, err
+	}
+	if slug == "" {
+		return nil, nil
+	}
+	return []string{slug}, nil
+}
+
+// forgetAISlugs tells an AI service that reuses slugs that none of those it
+// offered for originalURL could be used.
+func (s *URLService) forgetAISlugs(ctx context.Context, originalURL string) {
+	forgetter, ok := s.aiService.(AISlugForgetter)
+	if !ok {
+		return
+	}
+	if err := forgetter.ForgetSlugs(ctx, utils.RedactURL(originalURL)); err != nil {
+		log.Printf("Failed to forget AI slugs: %v", err)
+	}
 }
 
 func (s *URLService) GetOriginalURL(ctx context.Context, shortCode string) (string, error) {
-	return s.storage.GetURL(ctx, shortCode)
+	code := s.normalizeCode(shortCode)
+
+	originalURL, err := s.storage.GetURL(ctx, code)
+	if err != nil && s.foldCase {
+		if folded := utils.FoldCase(code); folded != code {
+			code = folded
+			originalURL, err = s.storage.GetURL(ctx, code)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return originalURL, s.checkFlag(ctx, code, originalURL)
 }
 
-func (s *URLService) isSlugAvailable(ctx context.Context, slug string) bool {
-	_, err := s.storage.GetURL(ctx, slug)
-	return err != nil
+const (
+	// maxHashAttempts bounds how often a hash code is salted and recomputed
+	// when it is retired for a different destination.
+	maxHashAttempts = 5
+	// maxPoolTakes bounds how many retired pooled codes are skipped before a
+	// hash code is used instead.
+	maxPoolTakes = 5
+)
+
+func (s *URLService) hashCode(ctx context.Context, originalURL string) (string, error) {
+	input := originalURL
+	for attempt := 1; attempt <= maxHashAttempts; attempt++ {
+		code := utils.ShortHash(input)
+		if s.foldCase {
+			code = utils.ShortHashFolded(input)
+		}
+
+		if !isReservedCode(code) && !s.isRetiredFor(ctx, code, originalURL) {
+			return code, nil
+		}
+		log.Printf("Hash-based slug '%s' is retired or reserved, rehashing", code)
+		input = fmt.Sprintf("%s#%d", originalURL, attempt)
+	}
+	return "", fmt.Errorf("failed to find a free hash-based slug")
+}
+
+// normalizeCode brings a code taken from a request path into the form it was
+// stored in. The router already decodes the path once; codes that still hold
+// escapes come from clients that double-encode non-ASCII characters.
+func (s *URLService) normalizeCode(shortCode string) string {
+	code := strings.Trim(shortCode, "/")
+	if !s.unicodeSlugs {
+		return code
+	}
+	if strings.Contains(code, "%") {
+		if decoded, err := url.PathUnescape(code); err == nil {
+			code = decoded
+		}
+	}
+	return utils.NormalizeNFC(code)
+}
+
+// shortURL returns the public URL of code.
+func (s *URLService) shortURL(code string) string {
+	return fmt.Sprintf("http://%s:%s/%s", s.serverHost, s.serverPort, escapeCode(code))
+}
+
+// escapeCode percent-encodes each segment of a code for use in a URL.
+func escapeCode(code string) string {
+	segments := strings.Split(code, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// claimAlias validates a custom alias, enforces namespace ownership for
+// multi-segment aliases and makes sure the code is still free.
+func (s *URLService) claimAlias(ctx context.Context, alias, originalURL string) (string, error) {
+	code, namespace, err := ParseAlias(alias, s.unicodeSlugs)
+	if err != nil {
+		return "", err
+	}
+	if s.foldCase {
+		code = utils.FoldCase(code)
+	}
+
+	if namespace != "" {
+		tenant := TenantFromContext(ctx)
+		if tenant == "" {
+			return "", ErrOwnerRequired
+		}
+
+		owner, err := s.storage.ClaimNamespace(ctx, strings.ToLower(namespace), tenant)
+		if err != nil {
+			return "", fmt.Errorf("failed to claim namespace: %w", err)
+		}
+		if owner != tenant {
+			return "", fmt.Errorf("%w: %s", ErrNamespaceOwned, namespace)
+		}
+	} else {
+		// A plain alias must not shadow another tenant's namespace
+		owner, claimed, err := s.storage.NamespaceOwner(ctx, strings.ToLower(code))
+		if err != nil {
+			return "", fmt.Errorf("failed to get namespace owner: %w", err)
+		}
+		if claimed && owner != TenantFromContext(ctx) {
+			return "", fmt.Errorf("%w: %s", ErrNamespaceOwned, code)
+		}
+	}
+
+	if !s.isSlugAvailable(ctx, code, originalURL) {
+		return "", fmt.Errorf("%w: %s", ErrAliasTaken, code)
+	}
+
+	return code, nil
+}
+
+// isSlugAvailable reports whether slug may be assigned to originalURL: it must
+// not be a static route, live, reserved by the code pool, or retired for
+// another destination.
+func (s *URLService) isSlugAvailable(ctx context.Context, slug, originalURL string) bool {
+	if isReservedCode(slug) {
+		return false
+	}
+	if s.codePool != nil && s.codePool.Reserved(ctx, slug) {
+		return false
+	}
+	if _, err := s.storage.GetURL(ctx, slug); err == nil {
+		return false
+	}
+	return !s.isRetiredFor(ctx, slug, originalURL)
 }
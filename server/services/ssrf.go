@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Resolver looks up the addresses of a host. *net.Resolver satisfies it;
+// tests can substitute a stub.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DestinationPolicy rejects destinations that would let a short link point
+// at our own infrastructure: private, loopback, link-local and metadata
+// addresses, whether written literally or reached through DNS, and hosts
+// under internal domain suffixes.
+type DestinationPolicy struct {
+	Resolver         Resolver
+	InternalSuffixes []string
+	ResolveTimeout   time.Duration
+}
+
+// NAT64 addresses are judged by the IPv4 address they embed. The blocked
+// prefixes cover ranges the netip.Addr classification helpers do not.
+var (
+	nat64Prefix     = netip.MustParsePrefix("64:ff9b::/96")
+	blockedPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),      // "this" network
+		netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT, Alibaba metadata
+		netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+		netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+		netip.MustParsePrefix("240.0.0.0/4"),    // reserved, broadcast
+		netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+		netip.MustParsePrefix("2001:db8::/32"),  // documentation
+		netip.MustParsePrefix("fd00:ec2::/32"),  // AWS IPv6 metadata
+	}
+)
+
+// IsBlockedIP reports whether addr belongs to a range that short links may
+// not point at.
+func IsBlockedIP(addr netip.Addr) bool {
+	addr = embeddedIPv4(addr.Unmap())
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// check applies the policy to a host name taken from a parsed URL.
+func (p *DestinationPolicy) check(ctx context.Context, host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if addr, ok := parseHostIP(host); ok {
+		if IsBlockedIP(addr) {
+			return newValidationError(CodePrivateAddress, "URL points to a private or reserved address")
+		}
+		return nil
+	}
+
+	for _, suffix := range p.InternalSuffixes {
+		suffix = strings.Trim(strings.ToLower(suffix), ".")
+		if suffix != "" && (host == suffix || strings.HasSuffix(host, "."+suffix)) {
+			return newValidationError(CodeInternalDomain, "URL points to an internal domain")
+		}
+	}
+
+	if p.Resolver == nil {
+		return nil
+	}
+
+	timeout := p.ResolveTimeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addrs, err := p.Resolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return newValidationError(CodeUnresolvableHost, "URL host could not be resolved")
+	}
+
+	for _, ipAddr := range addrs {
+		addr, ok := netip.AddrFromSlice(ipAddr.IP)
+		if ok && IsBlockedIP(addr) {
+			return newValidationError(CodePrivateAddress, "URL host resolves to a private or reserved address")
+		}
+	}
+
+	return nil
+}
+
+// parseHostIP recognises IP literals, including the shorthand IPv4 forms
+// browsers accept such as "0x7f.1" or "2130706433".
+func parseHostIP(host string) (netip.Addr, bool) {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr, true
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+
+	values := make([]uint64, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseUint(part, 0, 32)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		values[i] = value
+	}
+
+	// The last part fills all remaining bytes: a.b.c.d, a.b.cd, a.bcd, abcd
+	var ip uint64
+	for i, value := range values[:len(values)-1] {
+		if value > 0xff {
+			return netip.Addr{}, false
+		}
+		ip |= value << (24 - 8*i)
+	}
+	last := values[len(values)-1]
+	if last >= 1<<(8*(5-len(values))) {
+		return netip.Addr{}, false
+	}
+	ip |= last
+
+	return netip.AddrFrom4([4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}), true
+}
+
+// embeddedIPv4 returns the IPv4 address carried by a NAT64 address, so that
+// 64:ff9b::7f00:1 is judged as 127.0.0.1.
+func embeddedIPv4(addr netip.Addr) netip.Addr {
+	if addr.Is6() && nat64Prefix.Contains(addr) {
+		b := addr.As16()
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]})
+	}
+	return addr
+}
+
+// NewSafeHTTPClient returns a client for requests to user-supplied URLs that
+// refuses to connect to blocked addresses. The check runs on the address
+// actually dialled, so redirects and DNS rebinding cannot get around it.
+func NewSafeHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if IsBlockedIP(addrPort.Addr()) {
+				return fmt.Errorf("connection to %s is not allowed", addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
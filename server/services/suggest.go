@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"go-url-shortner/utils"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Generators a slug suggestion can come from.
+const (
+	SuggestionAI      = "ai"
+	SuggestionKeyword = "keyword"
+	SuggestionHash    = "hash"
+)
+
+const keywordSuggestions = 5
+
+// SlugSuggestion is a slug that could be requested as a custom alias.
+type SlugSuggestion struct {
+	Slug      string `json:"slug"`
+	Source    string `json:"source"`
+	Available bool   `json:"available"`
+}
+
+type SlugSuggestions struct {
+	URL         string           `json:"url"`
+	Suggestions []SlugSuggestion `json:"suggestions"`
+}
+
+// SuggestSlugs collects slugs for rawURL from the AI service, the URL's
+// keywords and its hash code, marking which are still free. Nothing is
+// stored; a chosen slug is claimed by passing it as the alias on creation.
+func (s *URLService) SuggestSlugs(ctx context.Context, rawURL string) (*SlugSuggestions, error) {
+	destination, err := s.canonicalize(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SlugSuggestions{URL: destination, Suggestions: []SlugSuggestion{}}
+	seen := make(map[string]bool)
+	add := func(slug, source string) {
+		if s.foldCase {
+			slug = utils.FoldCase(slug)
+		}
+		code, _, err := ParseAlias(slug, s.unicodeSlugs)
+		if err != nil || seen[code] {
+			return
+		}
+		seen[code] = true
+		result.Suggestions = append(result.Suggestions, SlugSuggestion{
+			Slug:      code,
+			Source:    source,
+			Available: s.isSlugAvailable(ctx, code, destination),
+		})
+	}
+
+	if s.aiService != nil {
+		candidates, err := s.aiSlugCandidates(ctx, destination)
+		if err != nil {
+			log.Printf("AI slug suggestions failed: %v", err)
+		}
+		for _, slug := range candidates {
+			add(slug, SuggestionAI)
+		}
+	}
+
+	for _, slug := range KeywordSlugs(destination, s.unicodeSlugs, keywordSuggestions) {
+		add(slug, SuggestionKeyword)
+	}
+
+	if code, err := s.hashCode(ctx, destination); err == nil {
+		add(code, SuggestionHash)
+	}
+
+	return result, nil
+}
+
+// Words that say nothing about a page.
+var keywordStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "the": true, "of": true, "to": true,
+	"in": true, "on": true, "for": true, "with": true, "by": true, "at": true,
+	"www": true, "com": true, "org": true, "net": true, "co": true, "info": true,
+	"html": true, "htm": true, "php": true, "aspx": true, "index": true,
+	"p": true, "id": true, "page": true, "amp": true,
+}
+
+// KeywordSlugs derives up to n slugs from the words in rawURL's host and
+// path, such as "bgood", "good" and "books" for
+// https://best-books.com/good-books/book. Combinations longer than
+// MaxSlugLength are dropped rather than cut to a fragment.
+func KeywordSlugs(rawURL string, allowUnicode bool, n int) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	hostWords := urlWords(hostLabel(u.Hostname()))
+	pathWords := urlWords(u.Path)
+	words := append(append([]string{}, pathWords...), hostWords...)
+
+	var raw []string
+	if len(pathWords) > 1 {
+		raw = append(raw, pathWords[0]+pathWords[1])
+	}
+	if len(hostWords) > 0 && len(pathWords) > 0 {
+		raw = append(raw, string([]rune(hostWords[0])[0])+pathWords[0])
+	}
+	raw = append(raw, words...)
+	if len(hostWords) > 1 {
+		raw = append(raw, strings.Join(hostWords, ""))
+	}
+	if len(words) >= 3 {
+		var initials strings.Builder
+		for _, word := range words {
+			initials.WriteRune([]rune(word)[0])
+		}
+		raw = append(raw, initials.String())
+	}
+
+	var slugs []string
+	seen := make(map[string]bool)
+	for _, candidate := range raw {
+		if len([]rune(candidate)) > MaxSlugLength {
+			continue
+		}
+		slug := cleanSlug(candidate, allowUnicode)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		slugs = append(slugs, slug)
+		if len(slugs) == n {
+			break
+		}
+	}
+	return slugs
+}
+
+// hostLabel returns the registrable name of host without subdomains and its
+// public suffix, e.g. "best-books" for www.best-books.co.uk and "bbc" for
+// news.bbc.com. Hosts that are a public suffix themselves have none.
+func hostLabel(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	suffix, _ := publicsuffix.PublicSuffix(host)
+	name := strings.TrimSuffix(strings.TrimSuffix(host, suffix), ".")
+	if name == "" {
+		return ""
+	}
+	labels := strings.Split(name, ".")
+	return labels[len(labels)-1]
+}
+
+// urlWords splits text into lowercase words, dropping numbers and stop words.
+func urlWords(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if keywordStopWords[word] || strings.IndexFunc(word, unicode.IsLetter) < 0 {
+			continue
+		}
+		words = append(words, word)
+	}
+	return words
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrLinkNotFound = errors.New("short URL not found")
+	ErrNoTombstone  = errors.New("code is not retired")
+)
+
+// WithTombstones keeps codes unavailable for quarantine after they stop
+// resolving, so printed links never start pointing at someone else's content.
+// A tombstone is written with every link and outlives it by the quarantine
+// period; deleting a link starts the quarantine immediately.
+func WithTombstones(store TombstoneStore, linkTTL, quarantine time.Duration) URLServiceOption {
+	return func(s *URLService) {
+		s.tombstones = store
+		s.linkTTL = linkTTL
+		s.quarantine = quarantine
+	}
+}
+
+// DeleteURL removes a link and retires its code for the quarantine period.
+func (s *URLService) DeleteURL(ctx context.Context, shortCode string) error {
+	code := s.normalizeCode(shortCode)
+
+	originalURL, err := s.storage.GetURL(ctx, code)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrLinkNotFound, code)
+	}
+
+	// An AI slug added by an upgrade goes with the link
+	if metadata, err := s.loadMetadata(ctx, code); err == nil && metadata != nil && metadata.AISlug != "" {
+		if err := s.DeleteURL(ctx, metadata.AISlug); err != nil {
+			log.Printf("Failed to delete AI slug '%s' of '%s': %v", metadata.AISlug, code, err)
+		}
+	}
+
+	if err := s.storage.DeleteURL(ctx, code); err != nil {
+		return fmt.Errorf("failed to delete URL: %w", err)
+	}
+
+	if s.metadata != nil {
+		if err := s.metadata.DeleteMetadata(ctx, code); err != nil {
+			log.Printf("Failed to delete metadata for '%s': %v", code, err)
+		}
+	}
+	s.clearFlag(ctx, code)
+
+	if s.tombstones != nil {
+		if err := s.tombstones.StoreTombstone(ctx, code, originalURL, s.quarantine); err != nil {
+			return fmt.Errorf("failed to retire code: %w", err)
+		}
+	}
+
+	log.Printf("Deleted URL mapping - Short: %s", code)
+	return nil
+}
+
+// ReleaseCode lifts the quarantine on a retired code so it can be reused.
+func (s *URLService) ReleaseCode(ctx context.Context, shortCode string) error {
+	if s.tombstones == nil {
+		return fmt.Errorf("%w: code quarantine is disabled", ErrNoTombstone)
+	}
+
+	code := s.normalizeCode(shortCode)
+	if _, found, err := s.tombstones.GetTombstone(ctx, code); err != nil {
+		return fmt.Errorf("failed to release code: %w", err)
+	} else if !found {
+		return fmt.Errorf("%w: %s", ErrNoTombstone, code)
+	}
+	if err := s.tombstones.DeleteTombstone(ctx, code); err != nil {
+		return fmt.Errorf("failed to release code: %w", err)
+	}
+	s.clearFlag(ctx, code)
+
+	log.Printf("Released retired code: %s", code)
+	return nil
+}
+
+// isRetiredFor reports whether code is quarantined for a destination other
+// than originalURL. Re-shortening the same destination may reuse its code.
+func (s *URLService) isRetiredFor(ctx context.Context, code, originalURL string) bool {
+	if s.tombstones == nil {
+		return false
+	}
+
+	retiredURL, found, err := s.tombstones.GetTombstone(ctx, code)
+	if err != nil {
+		// Treat lookup failures as retired rather than risk hijacking a code
+		log.Printf("Failed to check tombstone for '%s': %v", code, err)
+		return true
+	}
+	return found && retiredURL != originalURL
+}
+
+func (s *URLService) recordTombstone(ctx context.Context, code, originalURL string) {
+	if s.tombstones == nil {
+		return
+	}
+
+	if err := s.tombstones.StoreTombstone(ctx, code, originalURL, s.linkTTL+s.quarantine); err != nil {
+		log.Printf("Failed to record tombstone for '%s': %v", code, err)
+	}
+}
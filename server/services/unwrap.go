@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-url-shortner/utils"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrRedirectLoop     = errors.New("destination redirects in a loop")
+	ErrChainTooLong     = errors.New("destination redirects through too many short links")
+	ErrUnknownShortLink = errors.New("destination is a short link that does not exist")
+)
+
+// URLChecker validates a destination, e.g. *URLValidator.
+type URLChecker interface {
+	ValidateURLContext(ctx context.Context, urlStr string) error
+}
+
+type ChainResolverConfig struct {
+	// OwnHosts are the hosts our short links are served from, with port if
+	// not the default one.
+	OwnHosts []string
+	// Shorteners are hosts of other URL shorteners.
+	Shorteners []string
+	// Follow enables HTTP requests to resolve links of other shorteners.
+	// Links on OwnHosts are always resolved, from storage.
+	Follow bool
+	// MaxHops bounds how many short links are unwrapped.
+	MaxHops int
+	// Client performs requests to other shorteners. Its redirect policy is
+	// replaced so that every hop is inspected.
+	Client *http.Client
+	// Checker validates the final destination if it differs from the
+	// submitted one, so redirects cannot bypass destination policies.
+	Checker URLChecker
+}
+
+// ChainResolver replaces destinations that are themselves short links with
+// the URL they lead to, so that short links never form chains or loops.
+type ChainResolver struct {
+	ownHosts   map[string]bool
+	shorteners map[string]bool
+	follow     bool
+	maxHops    int
+	client     *http.Client
+	checker    URLChecker
+}
+
+func NewChainResolver(config ChainResolverConfig) *ChainResolver {
+	r := &ChainResolver{
+		ownHosts:   hostSet(config.OwnHosts),
+		shorteners: hostSet(config.Shorteners),
+		follow:     config.Follow,
+		maxHops:    config.MaxHops,
+		checker:    config.Checker,
+	}
+	if r.maxHops <= 0 {
+		r.maxHops = 5
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	if config.Client != nil {
+		copied := *config.Client
+		client = &copied
+	}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	r.client = client
+
+	return r
+}
+
+// WithChainResolver unwraps destinations that point at short links before
+// they are stored.
+func WithChainResolver(resolver *ChainResolver) URLServiceOption {
+	return func(s *URLService) {
+		s.chain = resolver
+	}
+}
+
+// Resolve follows rawURL through short links and returns the final
+// destination. lookup resolves codes of our own short links.
+func (r *ChainResolver) Resolve(ctx context.Context, rawURL string, lookup func(ctx context.Context, shortCode string) (string, error)) (string, error) {
+	current := rawURL
+	visited := map[string]bool{}
+
+	for hops := 0; ; hops++ {
+		if visited[current] {
+			return "", fmt.Errorf("%w: %s", ErrRedirectLoop, utils.RedactURL(current))
+		}
+		visited[current] = true
+
+		next, err := r.next(ctx, current, lookup)
+		if err != nil {
+			return "", err
+		}
+		if next == "" {
+			break
+		}
+		if hops >= r.maxHops {
+			return "", fmt.Errorf("%w: more than %d hops", ErrChainTooLong, r.maxHops)
+		}
+
+		log.Printf("Unwrapped short link %s to %s", utils.RedactURL(current), utils.RedactURL(next))
+		current = next
+	}
+
+	if current != rawURL && r.checker != nil {
+		if err := r.checker.ValidateURLContext(ctx, current); err != nil {
+			return "", err
+		}
+	}
+	return current, nil
+}
+
+// next returns where a short link points to, or "" if rawURL is not a short
+// link that can be unwrapped.
+func (r *ChainResolver) next(ctx context.Context, rawURL string, lookup func(ctx context.Context, shortCode string) (string, error)) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", nil
+	}
+
+	if r.ownHosts[strings.ToLower(u.Host)] {
+		code := strings.Trim(u.Path, "/")
+		if code == "" {
+			return "", nil
+		}
+
+		destination, err := lookup(ctx, code)
+		// Flagged links still resolve; the destination is scanned again
+		var flagged *FlaggedLinkError
+		if errors.As(err, &flagged) {
+			return flagged.OriginalURL, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrUnknownShortLink, code)
+		}
+		return destination, nil
+	}
+
+	if r.follow && r.isShortener(u) {
+		location, err := r.fetchLocation(ctx, u)
+		if err != nil {
+			// Keep the link as submitted rather than fail on a flaky shortener
+			log.Printf("Failed to unwrap %s: %v", utils.RedactURL(rawURL), err)
+			return "", nil
+		}
+		return location, nil
+	}
+
+	return "", nil
+}
+
+func (r *ChainResolver) isShortener(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	return r.shorteners[strings.ToLower(u.Host)] || r.shorteners[host] || r.shorteners[strings.TrimPrefix(host, "www.")]
+}
+
+// fetchLocation asks a shortener where u redirects to, trying HEAD first and
+// GET for servers that don't support it. It returns "" for non-redirects.
+func (r *ChainResolver) fetchLocation(ctx context.Context, u *url.URL) (string, error) {
+	resp, err := r.request(ctx, http.MethodHead, u)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = r.request(ctx, http.MethodGet, u)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode < 300 || resp.StatusCode > 399 {
+		return "", nil
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return "", fmt.Errorf("redirect without location: %w", err)
+	}
+	return location.String(), nil
+}
+
+func (r *ChainResolver) request(ctx context.Context, method string, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "go-url-shortener/1.0 (+link unwrapping)")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func hostSet(hosts []string) map[string]bool {
+	set := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			set[host] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"go-url-shortner/utils"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var upgradeMetrics = expvar.NewMap("slug_upgrades")
+
+// States of an asynchronous AI slug upgrade, recorded in link metadata.
+const (
+	AISlugPending = "pending"
+	AISlugReady   = "ready"
+	AISlugFailed  = "failed"
+)
+
+type SlugUpgradeConfig struct {
+	Workers   int
+	QueueSize int
+	// WebhookURL, if set, receives a SlugUpgradeEvent for every finished
+	// upgrade.
+	WebhookURL string
+	// Client delivers webhooks. It should refuse internal addresses unless
+	// the webhook is internal on purpose.
+	Client *http.Client
+}
+
+// SlugUpgrader queues links created with a hash code so background workers
+// can give them an AI slug without holding up the request.
+type SlugUpgrader struct {
+	jobs       chan slugUpgradeJob
+	workers    int
+	webhookURL string
+	client     *http.Client
+	// stopped is set once the workers have stopped taking jobs.
+	stopped atomic.Bool
+}
+
+type slugUpgradeJob struct {
+	shortCode   string
+	destination string
+	tenant      string
+}
+
+// SlugUpgradeEvent is posted to the webhook when an upgrade finishes.
+type SlugUpgradeEvent struct {
+	ShortCode   string `json:"short_code"`
+	OriginalURL string `json:"original_url"`
+	Status      string `json:"ai_slug_status"`
+	AISlug      string `json:"ai_slug,omitempty"`
+	VanityURL   string `json:"vanity_url,omitempty"`
+}
+
+func NewSlugUpgrader(config SlugUpgradeConfig) *SlugUpgrader {
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1000
+	}
+	if config.Client == nil {
+		config.Client = NewSafeHTTPClient(5 * time.Second)
+	}
+
+	return &SlugUpgrader{
+		jobs:       make(chan slugUpgradeJob, config.QueueSize),
+		workers:    config.Workers,
+		webhookURL: config.WebhookURL,
+		client:     config.Client,
+	}
+}
+
+// WithAsyncAISlugs returns links with a hash or pooled code at once and asks
+// the AI service for a slug in the background. The slug becomes an alias of
+// the same link once RunSlugUpgrades finds a free one.
+func WithAsyncAISlugs(upgrader *SlugUpgrader) URLServiceOption {
+	return func(s *URLService) {
+		s.upgrader = upgrader
+	}
+}
+
+// RunSlugUpgrades works through queued upgrades until ctx is cancelled, then
+// marks the upgrades still queued as failed before it returns. Callers should
+// stop creating links first and wait for it to return.
+func (s *URLService) RunSlugUpgrades(ctx context.Context) {
+	if s.upgrader == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.upgrader.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.upgrader.jobs:
+					s.upgradeSlug(ContextWithTenant(ctx, job.tenant), job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	s.upgrader.stopped.Store(true)
+
+	// Queued upgrades will not run; don't leave them pending forever
+	for {
+		select {
+		case job := <-s.upgrader.jobs:
+			upgradeMetrics.Add("failed", 1)
+			s.updateMetadata(context.WithoutCancel(ctx), job.shortCode, func(metadata *LinkMetadata) {
+				metadata.AISlugStatus = AISlugFailed
+			})
+		default:
+			return
+		}
+	}
+}
+
+// queueSlugUpgrade schedules an AI slug for a new link and returns the
+// upgrade's status. It never blocks: when the queue is full or the workers
+// have stopped, the link keeps its code and the upgrade is marked failed.
+func (s *URLService) queueSlugUpgrade(ctx context.Context, shortCode, destination string) string {
+	job := slugUpgradeJob{
+		shortCode:   shortCode,
+		destination: destination,
+		tenant:      TenantFromContext(ctx),
+	}
+
+	if s.upgrader.stopped.Load() {
+		log.Printf("Slug upgrades have stopped, keeping '%s'", shortCode)
+	} else {
+		select {
+		case s.upgrader.jobs <- job:
+			upgradeMetrics.Add("queued", 1)
+			return AISlugPending
+		default:
+			log.Printf("Slug upgrade queue is full, keeping '%s'", shortCode)
+		}
+	}
+
+	upgradeMetrics.Add("dropped", 1)
+	s.updateMetadata(ctx, shortCode, func(metadata *LinkMetadata) {
+		metadata.AISlugStatus = AISlugFailed
+	})
+	return AISlugFailed
+}
+
+func (s *URLService) upgradeSlug(ctx context.Context, job slugUpgradeJob) {
+	event := SlugUpgradeEvent{ShortCode: job.shortCode, OriginalURL: job.destination, Status: AISlugFailed}
+
+	// The link may have been deleted while the upgrade was queued
+	if !s.linkExists(ctx, job.shortCode, job.destination) {
+		log.Printf("Skipping AI slug upgrade of deleted link '%s'", job.shortCode)
+		upgradeMetrics.Add("failed", 1)
+		return
+	}
+
+	candidates, err := s.aiSlugCandidates(ctx, job.destination)
+	if err != nil {
+		log.Printf("AI slug upgrade failed for '%s': %v", job.shortCode, err)
+	}
+
+	taken := 0
+	for _, aiSlug := range candidates {
+		if s.foldCase {
+			aiSlug = utils.FoldCase(aiSlug)
+		}
+		if !s.isSlugAvailable(ctx, aiSlug, job.destination) {
+			taken++
+			continue
+		}
+		// Another link may take the slug between the check and the store
+		stored, err := s.storage.StoreURLIfAbsent(ctx, aiSlug, job.destination)
+		if err != nil {
+			log.Printf("Failed to store AI slug '%s': %v", aiSlug, err)
+			break
+		}
+		if !stored {
+			taken++
+			continue
+		}
+		// Deleting the link during the AI call would leave the slug orphaned
+		if !s.linkExists(ctx, job.shortCode, job.destination) {
+			log.Printf("Link '%s' was deleted during its upgrade, releasing '%s'", job.shortCode, aiSlug)
+			if err := s.storage.DeleteURL(context.WithoutCancel(ctx), aiSlug); err != nil {
+				log.Printf("Failed to release AI slug '%s': %v", aiSlug, err)
+			}
+			break
+		}
+		s.copyFlag(ctx, job.shortCode, aiSlug)
+		s.recordTombstone(ctx, aiSlug, job.destination)
+		s.saveMetadata(ctx, &LinkMetadata{
+			ShortCode:   aiSlug,
+			OriginalURL: job.destination,
+			SlugType:    aiGenerated,
+			CreatedAt:   time.Now().UTC(),
+			AliasOf:     job.shortCode,
+		})
+
+		event.Status, event.AISlug, event.VanityURL = AISlugReady, aiSlug, s.shortURL(aiSlug)
+		log.Printf("Upgraded '%s' with AI slug '%s'", job.shortCode, aiSlug)
+		break
+	}
+
+	if taken > 0 && taken == len(candidates) {
+		s.forgetAISlugs(ctx, job.destination)
+	}
+
+	if event.Status == AISlugReady {
+		upgradeMetrics.Add("upgraded", 1)
+	} else {
+		upgradeMetrics.Add("failed", 1)
+	}
+
+	// Upgrades cut short by shutdown are still recorded as failed
+	s.updateMetadata(context.WithoutCancel(ctx), job.shortCode, func(metadata *LinkMetadata) {
+		metadata.AISlugStatus = event.Status
+		metadata.AISlug = event.AISlug
+		metadata.VanityURL = event.VanityURL
+	})
+	s.upgrader.notify(ctx, event)
+}
+
+// linkExists reports whether code still points at destination.
+func (s *URLService) linkExists(ctx context.Context, code, destination string) bool {
+	current, err := s.storage.GetURL(ctx, code)
+	return err == nil && current == destination
+}
+
+// notify posts event to the webhook. Delivery is attempted once; clients
+// that miss it can poll the link metadata.
+func (u *SlugUpgrader) notify(ctx context.Context, event SlugUpgradeEvent) {
+	if u.webhookURL == "" {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Invalid slug upgrade webhook: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := u.client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("webhook returned %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		upgradeMetrics.Add("webhook_failures", 1)
+		log.Printf("Slug upgrade webhook failed for '%s': %v", event.ShortCode, err)
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAIBudgetExceeded = errors.New("daily AI token budget exceeded")
+	usageMetrics        = expvar.NewMap("ai_usage")
+)
+
+// AnonymousTenant is the tenant AI usage is attributed to for callers
+// without an API key.
+const AnonymousTenant = "anonymous"
+
+type UsageConfig struct {
+	// DailyBudget caps the tokens used by all tenants per UTC day. Zero
+	// means no cap.
+	DailyBudget int64
+	// TenantDailyBudget caps the tokens each tenant uses per UTC day. Zero
+	// means no cap. Tenants are derived from any API key a caller sends, so
+	// a caller can spread usage over new keys; only DailyBudget bounds the
+	// total, and Validate requires it alongside TenantDailyBudget.
+	TenantDailyBudget int64
+	// CompletionReserve is how many completion tokens are held against the
+	// budgets while a call is in flight, usually the provider's max tokens.
+	// Calls with their own limit from ContextWithMaxTokens hold that instead.
+	CompletionReserve int64
+	// PromptPrice and CompletionPrice are the cost of a million prompt and
+	// completion tokens, used to estimate spend in usage reports.
+	PromptPrice     float64
+	CompletionPrice float64
+}
+
+// UsageMeter records the tokens every AI completion uses per day and tenant,
+// and refuses further completions once a daily budget is spent. Counters
+// live in shared storage so budgets hold across instances.
+type UsageMeter struct {
+	store  AIUsageStore
+	config UsageConfig
+}
+
+// AIUsage is the token usage of a tenant or of everyone over a day.
+type AIUsage struct {
+	TokenUsage
+	TotalTokens   int64   `json:"total_tokens"`
+	EstimatedCost float64 `json:"estimated_cost"`
+}
+
+type AIUsageReport struct {
+	Date              string             `json:"date"`
+	Total             AIUsage            `json:"total"`
+	Tenants           map[string]AIUsage `json:"tenants"`
+	DailyBudget       int64              `json:"daily_budget,omitempty"`
+	TenantDailyBudget int64              `json:"tenant_daily_budget,omitempty"`
+}
+
+// Validate returns an error for budgets that cannot be enforced.
+func (c UsageConfig) Validate() error {
+	if c.DailyBudget < 0 || c.TenantDailyBudget < 0 || c.CompletionReserve < 0 {
+		return errors.New("token budgets must not be negative")
+	}
+	if c.TenantDailyBudget > 0 && c.DailyBudget <= 0 {
+		return errors.New("a per-tenant budget needs a daily budget for all tenants, since new API keys start new tenants")
+	}
+	return nil
+}
+
+func NewUsageMeter(store AIUsageStore, config UsageConfig) *UsageMeter {
+	return &UsageMeter{store: store, config: config}
+}
+
+// Wrap returns a provider that meters the completions of provider.
+func (m *UsageMeter) Wrap(provider LLMProvider) LLMProvider {
+	if provider == nil {
+		return nil
+	}
+	return &meteredProvider{next: provider, meter: m}
+}
+
+// Usage returns the tokens used on the UTC day containing day.
+func (m *UsageMeter) Usage(ctx context.Context, day time.Time) (*AIUsageReport, error) {
+	date := usageDay(day)
+	counters, err := m.store.GetAIUsage(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AIUsageReport{
+		Date:              date,
+		Tenants:           make(map[string]AIUsage),
+		DailyBudget:       m.config.DailyBudget,
+		TenantDailyBudget: m.config.TenantDailyBudget,
+	}
+	total := TokenUsage{}
+	for tenant, usage := range tenantUsage(counters) {
+		report.Tenants[tenant] = m.priced(usage)
+		total.PromptTokens += usage.PromptTokens
+		total.CompletionTokens += usage.CompletionTokens
+	}
+	report.Total = m.priced(total)
+	return report, nil
+}
+
+func (m *UsageMeter) priced(usage TokenUsage) AIUsage {
+	cost := float64(usage.PromptTokens)*m.config.PromptPrice + float64(usage.CompletionTokens)*m.config.CompletionPrice
+	return AIUsage{TokenUsage: usage, TotalTokens: usage.Total(), EstimatedCost: cost / 1e6}
+}
+
+// reserve holds tokens against the day's budgets for a call about to be
+// made, or returns ErrAIBudgetExceeded if the tokens used and held by other
+// calls have already reached a budget. Holding and checking is one atomic
+// step, so concurrent calls cannot all slip under a budget; a call may still
+// overshoot by the tokens it uses beyond its reservation. The returned
+// function gives the reservation back. Usage that cannot be read does not
+// block AI.
+func (m *UsageMeter) reserve(ctx context.Context, day, tenant string, tokens int64) (func(), error) {
+	noop := func() {}
+	if m.config.DailyBudget <= 0 && m.config.TenantDailyBudget <= 0 {
+		return noop, nil
+	}
+
+	counters, err := m.store.ReserveAIUsage(ctx, day, tenant, tokens)
+	if err != nil {
+		log.Printf("Failed to reserve AI usage: %v", err)
+		return noop, nil
+	}
+	release := func() {
+		if _, err := m.store.ReserveAIUsage(context.WithoutCancel(ctx), day, tenant, -tokens); err != nil {
+			log.Printf("Failed to release AI usage reservation: %v", err)
+		}
+	}
+
+	var total, tenantTotal int64
+	for field, count := range counters {
+		sep := strings.LastIndex(field, ":")
+		if sep < 0 {
+			continue
+		}
+		total += count
+		if field[:sep] == tenant {
+			tenantTotal += count
+		}
+	}
+	// Only what was used or held before this call counts against it
+	total -= tokens
+	tenantTotal -= tokens
+
+	if (m.config.DailyBudget > 0 && total >= m.config.DailyBudget) ||
+		(m.config.TenantDailyBudget > 0 && tenantTotal >= m.config.TenantDailyBudget) {
+		release()
+		return nil, ErrAIBudgetExceeded
+	}
+	return release, nil
+}
+
+func (m *UsageMeter) record(ctx context.Context, day, tenant string, usage TokenUsage) {
+	if usage.Total() == 0 {
+		return
+	}
+	usageMetrics.Add("prompt_tokens", usage.PromptTokens)
+	usageMetrics.Add("completion_tokens", usage.CompletionTokens)
+
+	if err := m.store.AddAIUsage(ctx, day, tenant, usage.PromptTokens, usage.CompletionTokens); err != nil {
+		log.Printf("Failed to record AI usage: %v", err)
+	}
+}
+
+// estimateTokens roughly counts the tokens a call with prompt may use, at
+// about four bytes of prompt per token.
+func (m *UsageMeter) estimateTokens(ctx context.Context, prompt string) int64 {
+	completion := m.config.CompletionReserve
+	if maxTokens := MaxTokensFromContext(ctx); maxTokens > 0 {
+		completion = int64(maxTokens)
+	}
+	return int64(len(prompt)/4) + completion
+}
+
+type meteredProvider struct {
+	next  LLMProvider
+	meter *UsageMeter
+}
+
+func (p *meteredProvider) Complete(ctx context.Context, prompt string) (Completion, error) {
+	tenant := TenantFromContext(ctx)
+	if tenant == "" {
+		tenant = AnonymousTenant
+	}
+
+	// The day is fixed up front so a call across midnight releases its own
+	// reservation
+	day := usageDay(time.Now())
+	release, err := p.meter.reserve(ctx, day, tenant, p.meter.estimateTokens(ctx, prompt))
+	if err != nil {
+		usageMetrics.Add("over_budget", 1)
+		return Completion{}, err
+	}
+	defer release()
+
+	completion, err := p.next.Complete(ctx, prompt)
+	// Failed completions may still have been billed
+	p.meter.record(context.WithoutCancel(ctx), day, tenant, completion.Usage)
+	return completion, err
+}
+
+// tenantUsage groups "<tenant>:prompt" and "<tenant>:completion" counters
+// by tenant.
+func tenantUsage(counters map[string]int64) map[string]TokenUsage {
+	usage := make(map[string]TokenUsage)
+	for field, count := range counters {
+		sep := strings.LastIndex(field, ":")
+		if sep < 0 {
+			continue
+		}
+		tenant := field[:sep]
+		tenantTotal := usage[tenant]
+		switch field[sep+1:] {
+		case "prompt":
+			tenantTotal.PromptTokens += count
+		case "completion":
+			tenantTotal.CompletionTokens += count
+		}
+		usage[tenant] = tenantTotal
+	}
+	return usage
+}
+
+func usageDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
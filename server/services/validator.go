@@ -1,85 +1,265 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"go-url-shortner/utils"
 	"net/url"
 	"strings"
 )
 
-type URLValidator struct{}
+// Machine-readable codes carried by ValidationError.
+const (
+	CodeURLRequired      = "url_required"
+	CodeInvalidFormat    = "invalid_format"
+	CodeMissingHost      = "missing_host"
+	CodeInvalidDomain    = "invalid_domain"
+	CodeInvalidHost      = "invalid_host"
+	CodeCredentialsInURL = "credentials_in_url"
+	CodePrivateAddress   = "private_address"
+	CodeInternalDomain   = "internal_domain"
+	CodeUnresolvableHost = "unresolvable_host"
+)
+
+// ValidationError is returned for URLs rejected by a validation policy.
+type ValidationError struct {
+	Code    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func newValidationError(code, message string) *ValidationError {
+	return &ValidationError{Code: code, Message: message}
+}
+
+type URLValidator struct {
+	destinations     *DestinationPolicy
+	policy           *PolicyEngine
+	rejectConfusable bool
+	schemes          *SchemePolicy
+	credentials      string
+}
 
-func NewURLValidator() *URLValidator {
-	return &URLValidator{}
+// Credentials policies for URLs with userinfo such as "user:pass@host".
+const (
+	CredentialsReject = "reject"
+	CredentialsStrip  = "strip"
+	CredentialsAllow  = "allow"
+)
+
+// ValidatorOption configures optional URLValidator checks.
+type ValidatorOption func(*URLValidator)
+
+// WithDestinationPolicy rejects URLs pointing at private or internal hosts.
+func WithDestinationPolicy(policy DestinationPolicy) ValidatorOption {
+	return func(v *URLValidator) {
+		v.destinations = &policy
+	}
+}
+
+// WithPolicyEngine consults engine's allow and deny rules for every URL,
+// using the tenant stored on the validation context.
+func WithPolicyEngine(engine *PolicyEngine) ValidatorOption {
+	return func(v *URLValidator) {
+		v.policy = engine
+	}
+}
+
+// WithSchemePolicy accepts the non-web schemes allowed by policy.
+func WithSchemePolicy(policy *SchemePolicy) ValidatorOption {
+	return func(v *URLValidator) {
+		v.schemes = policy
+	}
+}
+
+// WithCredentialsPolicy decides what happens to URLs with credentials:
+// CredentialsReject refuses them, CredentialsStrip removes the userinfo and
+// CredentialsAllow keeps it. Short links are public, so the default rejects,
+// and so do unknown policies.
+func WithCredentialsPolicy(policy string) ValidatorOption {
+	return func(v *URLValidator) {
+		if ValidateCredentialsPolicy(policy) != nil {
+			policy = CredentialsReject
+		}
+		v.credentials = policy
+	}
+}
+
+// ValidateCredentialsPolicy returns an error unless policy is
+// CredentialsReject, CredentialsStrip or CredentialsAllow.
+func ValidateCredentialsPolicy(policy string) error {
+	switch policy {
+	case CredentialsReject, CredentialsStrip, CredentialsAllow:
+		return nil
+	}
+	return fmt.Errorf("unknown credentials policy %q, expected %q, %q or %q", policy, CredentialsReject, CredentialsStrip, CredentialsAllow)
+}
+
+func NewURLValidator(opts ...ValidatorOption) *URLValidator {
+	v := &URLValidator{credentials: CredentialsReject}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
 }
 
 func (v *URLValidator) ValidateURL(urlStr string) error {
+	return v.ValidateURLContext(context.Background(), urlStr)
+}
+
+// ValidateURLContext validates urlStr, using ctx for any DNS lookups.
+func (v *URLValidator) ValidateURLContext(ctx context.Context, urlStr string) error {
 	if urlStr == "" {
-		return fmt.Errorf("URL is required")
+		return newValidationError(CodeURLRequired, "URL is required")
+	}
+
+	if scheme, ok := v.schemes.explicitScheme(urlStr); ok && !isWebScheme(scheme) {
+		return v.validateOtherScheme(ctx, urlStr, scheme)
 	}
 
 	// Check for invalid patterns
 	if strings.HasSuffix(urlStr, ".") || strings.HasPrefix(urlStr, ".") {
-		return fmt.Errorf("URL cannot start or end with a dot")
+		return newValidationError(CodeInvalidHost, "URL cannot start or end with a dot")
 	}
 
 	// Add scheme if missing
-	if !strings.HasPrefix(urlStr, "http://") && !strings.HasPrefix(urlStr, "https://") {
+	if !hasWebScheme(urlStr) {
 		urlStr = "https://" + urlStr
 	}
 
 	// Parse the URL
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
-		return fmt.Errorf("invalid URL format: %w", err)
+		return newValidationError(CodeInvalidFormat, fmt.Sprintf("invalid URL format: %v", err))
 	}
 
 	// Check if host is present
 	if parsedURL.Host == "" {
-		return fmt.Errorf("URL must have a valid host")
+		return newValidationError(CodeMissingHost, "URL must have a valid host")
 	}
 
 	// Check if host has at least one dot (basic domain validation)
 	if !strings.Contains(parsedURL.Host, ".") {
-		return fmt.Errorf("URL must have a valid domain")
+		return newValidationError(CodeInvalidDomain, "URL must have a valid domain")
 	}
 
 	// Additional validation for edge cases
 	if strings.HasSuffix(parsedURL.Host, ".") || strings.HasPrefix(parsedURL.Host, ".") {
-		return fmt.Errorf("URL host cannot start or end with a dot")
+		return newValidationError(CodeInvalidHost, "URL host cannot start or end with a dot")
 	}
 
-	// Check for @ character in invalid position (not in userinfo format)
-	if strings.Contains(urlStr, "@") {
-		// Check if @ is in the correct userinfo format (username:password@host)
-		schemeEnd := strings.Index(urlStr, "://")
-		if schemeEnd == -1 {
-			return fmt.Errorf("URL contains @ character in invalid position")
-		}
+	// Check Unicode hosts in their punycode form
+	asciiHost, err := toASCIIHost(parsedURL.Hostname())
+	if err != nil {
+		return err
+	}
+	if asciiHost != parsedURL.Hostname() {
+		parsedURL.Host = strings.Replace(parsedURL.Host, parsedURL.Hostname(), asciiHost, 1)
+	}
 
-		atIndex := strings.Index(urlStr, "@")
-		if atIndex < schemeEnd+3 {
-			return fmt.Errorf("URL contains @ character in invalid position")
+	if v.rejectConfusable && IsConfusableHost(asciiHost) {
+		return newValidationError(CodeConfusableHost, "URL host imitates another domain or mixes scripts")
+	}
+
+	if parsedURL.User != nil && v.credentials == CredentialsReject {
+		return newValidationError(CodeCredentialsInURL, "URL must not contain credentials")
+	}
+
+	// Policies see the destination as it will be stored and requested, so
+	// spellings such as "/a/../admin" cannot slip past a rule
+	canonical, err := Canonicalize(parsedURL.String(), equivalenceRules)
+	if err != nil {
+		return newValidationError(CodeInvalidFormat, err.Error())
+	}
+	if parsedURL, err = url.Parse(canonical); err != nil {
+		return newValidationError(CodeInvalidFormat, fmt.Sprintf("invalid URL format: %v", err))
+	}
+
+	if v.destinations != nil {
+		if err := v.destinations.check(ctx, parsedURL.Hostname()); err != nil {
+			return err
 		}
+	}
 
-		// Check if there's a colon before @ (username:password@host)
-		userInfo := urlStr[schemeEnd+3 : atIndex]
-		if !strings.Contains(userInfo, ":") {
-			return fmt.Errorf("URL contains @ character in invalid position")
+	if v.policy != nil {
+		if err := v.policy.Check(TenantFromContext(ctx), parsedURL); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// equivalenceRules are the canonicalization rules that never change which
+// resource a URL names. Validation always applies them, whatever the
+// configured rules.
+var equivalenceRules = CanonicalRules{
+	Lowercase:       true,
+	DefaultPort:     true,
+	DotSegments:     true,
+	PercentEncoding: true,
+}
+
+// hasWebScheme reports whether urlStr starts with http:// or https://, in any
+// letter case.
+func hasWebScheme(urlStr string) bool {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(parsedURL.Scheme, "http") || strings.EqualFold(parsedURL.Scheme, "https")
+}
+
 func (v *URLValidator) NormalizeURL(urlStr string) (string, error) {
-	if err := v.ValidateURL(urlStr); err != nil {
+	return v.NormalizeURLContext(context.Background(), urlStr)
+}
+
+// NormalizeURLContext validates urlStr, adds the https scheme if missing and
+// converts a Unicode host to punycode.
+func (v *URLValidator) NormalizeURLContext(ctx context.Context, urlStr string) (string, error) {
+	if err := v.ValidateURLContext(ctx, urlStr); err != nil {
 		return "", err
 	}
 
+	// Non-web schemes are stored as submitted
+	if scheme, ok := v.schemes.explicitScheme(urlStr); ok && !isWebScheme(scheme) {
+		return urlStr, nil
+	}
+
 	// Add scheme if missing
-	if !strings.HasPrefix(urlStr, "http://") && !strings.HasPrefix(urlStr, "https://") {
+	if !hasWebScheme(urlStr) {
 		urlStr = "https://" + urlStr
 	}
 
-	return urlStr, nil
+	if v.credentials == CredentialsStrip {
+		urlStr = utils.StripUserinfo(urlStr)
+	}
+
+	return asciiURL(urlStr)
+}
+
+// validateOtherScheme validates a destination such as mailto: or myapp://
+// that has no web host to check.
+func (v *URLValidator) validateOtherScheme(ctx context.Context, urlStr, scheme string) error {
+	if !v.schemes.Allows(scheme) {
+		return newValidationError(CodeUnsupportedScheme, fmt.Sprintf("URL scheme %q is not supported", scheme))
+	}
+	if err := v.schemes.validate(urlStr); err != nil {
+		return err
+	}
+
+	if v.policy != nil {
+		parsedURL, err := url.Parse(urlStr)
+		if err != nil {
+			return newValidationError(CodeInvalidFormat, fmt.Sprintf("invalid URL format: %v", err))
+		}
+		if err := v.policy.Check(TenantFromContext(ctx), parsedURL); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -7,6 +7,8 @@ import (
 type URLStorage interface {
 	StoreURL(ctx context.Context, shortCode, originalURL string) error
 	GetURL(ctx context.Context, shortCode string) (string, error)
+	DeleteURL(ctx context.Context, shortCode string) error
+	ClaimNamespace(ctx context.Context, namespace, owner string) (string, error)
 	Close() error
 }
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 	"github.com/redis/go-redis/v9"
 )
@@ -12,6 +14,53 @@ type RedisStorage struct {
 	client *redis.Client
 }
 
+// Key prefixes. Short codes may contain slashes ("eng/oncall"), so every
+// record lives under a prefix to keep it apart from other key types.
+const (
+	urlKeyPrefix       = "url:"
+	namespaceKeyPrefix = "ns:"
+	tombstoneKeyPrefix = "tomb:"
+	poolKey            = "pool:codes"
+	poolCounterKey     = "pool:counter"
+	policyRulesKey     = "policy:rules"
+	flagKeyPrefix      = "flag:"
+	metaKeyPrefix      = "meta:"
+	aiCacheKeyPrefix   = "aicache:"
+	aiUsageKeyPrefix   = "aiusage:"
+)
+
+// LinkTTL is how long a short link resolves after it was last stored.
+const LinkTTL = 365 * 24 * time.Hour
+
+// AIUsageRetention is how long daily AI token counters are kept.
+const AIUsageRetention = 90 * 24 * time.Hour
+
+// URLKey returns the Redis key holding the destination of shortCode.
+func URLKey(shortCode string) string {
+	return urlKeyPrefix + shortCode
+}
+
+// LegacyKey returns the unprefixed key shortCode was stored under before
+// keys were prefixed, and false if it cannot have one. Only single-segment
+// codes were stored that way, and none contained a colon, so codes such as
+// "tomb:abc" or "policy:rules" never reach the other key types.
+func LegacyKey(shortCode string) (string, bool) {
+	if shortCode == "" || strings.ContainsAny(shortCode, "/:") {
+		return "", false
+	}
+	return shortCode, true
+}
+
+// TombstoneKey returns the Redis key holding the retired destination of shortCode.
+func TombstoneKey(shortCode string) string {
+	return tombstoneKeyPrefix + shortCode
+}
+
+// NamespaceKey returns the Redis key holding the owner of namespace.
+func NamespaceKey(namespace string) string {
+	return namespaceKeyPrefix + namespace
+}
+
 func NewRedisStorage(addr, password string, db int) (*RedisStorage, error) {
 	client := redis.NewClient(&redis.Options{
 		Addr:     addr,
@@ -32,16 +81,28 @@ func NewRedisStorage(addr, password string, db int) (*RedisStorage, error) {
 
 func (r *RedisStorage) StoreURL(ctx context.Context, shortCode, originalURL string) error {
 	// Set with expiration (URLs expire after 1 year)
-	expiration := 365 * 24 * time.Hour
-	err := r.client.Set(ctx, shortCode, originalURL, expiration).Err()
+	err := r.client.Set(ctx, URLKey(shortCode), originalURL, LinkTTL).Err()
 	if err != nil {
 		return fmt.Errorf("failed to store URL in Redis: %w", err)
 	}
 	return nil
 }
 
+func (r *RedisStorage) StoreURLIfAbsent(ctx context.Context, shortCode, originalURL string) (bool, error) {
+	stored, err := r.client.SetNX(ctx, URLKey(shortCode), originalURL, LinkTTL).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to store URL in Redis: %w", err)
+	}
+	return stored, nil
+}
+
+// GetURL looks the code up under the prefixed layout first and falls back to
+// the legacy unprefixed key that single-segment codes were stored under.
 func (r *RedisStorage) GetURL(ctx context.Context, shortCode string) (string, error) {
-	originalURL, err := r.client.Get(ctx, shortCode).Result()
+	originalURL, err := r.client.Get(ctx, URLKey(shortCode)).Result()
+	if legacyKey, ok := LegacyKey(shortCode); ok && err == redis.Nil {
+		originalURL, err = r.client.Get(ctx, legacyKey).Result()
+	}
 	if err != nil {
 		if err == redis.Nil {
 			return "", fmt.Errorf("URL not found")
@@ -51,6 +112,281 @@ func (r *RedisStorage) GetURL(ctx context.Context, shortCode string) (string, er
 	return originalURL, nil
 }
 
+// DeleteURL removes the code under both the prefixed and the legacy layout.
+func (r *RedisStorage) DeleteURL(ctx context.Context, shortCode string) error {
+	keys := []string{URLKey(shortCode)}
+	if legacyKey, ok := LegacyKey(shortCode); ok {
+		keys = append(keys, legacyKey)
+	}
+
+	if err := r.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to delete URL from Redis: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) StoreTombstone(ctx context.Context, shortCode, originalURL string, ttl time.Duration) error {
+	if err := r.client.Set(ctx, TombstoneKey(shortCode), originalURL, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store tombstone in Redis: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) GetTombstone(ctx context.Context, shortCode string) (string, bool, error) {
+	originalURL, err := r.client.Get(ctx, TombstoneKey(shortCode)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to get tombstone from Redis: %w", err)
+	}
+	return originalURL, true, nil
+}
+
+func (r *RedisStorage) DeleteTombstone(ctx context.Context, shortCode string) error {
+	if err := r.client.Del(ctx, TombstoneKey(shortCode)).Err(); err != nil {
+		return fmt.Errorf("failed to delete tombstone from Redis: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) ClaimNamespace(ctx context.Context, namespace, owner string) (string, error) {
+	key := NamespaceKey(namespace)
+
+	claimed, err := r.client.SetNX(ctx, key, owner, 0).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to claim namespace in Redis: %w", err)
+	}
+	if claimed {
+		return owner, nil
+	}
+
+	current, err := r.client.Get(ctx, key).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to get namespace owner from Redis: %w", err)
+	}
+	return current, nil
+}
+
+func (r *RedisStorage) NamespaceOwner(ctx context.Context, namespace string) (string, bool, error) {
+	owner, err := r.client.Get(ctx, NamespaceKey(namespace)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to get namespace owner from Redis: %w", err)
+	}
+	return owner, true, nil
+}
+
+func (r *RedisStorage) AddPoolCodes(ctx context.Context, codes ...string) (int64, error) {
+	members := make([]interface{}, len(codes))
+	for i, code := range codes {
+		members[i] = code
+	}
+
+	added, err := r.client.SAdd(ctx, poolKey, members...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to add pool codes in Redis: %w", err)
+	}
+	return added, nil
+}
+
+func (r *RedisStorage) PopPoolCode(ctx context.Context) (string, error) {
+	code, err := r.client.SPop(ctx, poolKey).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", fmt.Errorf("code pool is empty")
+		}
+		return "", fmt.Errorf("failed to pop pool code from Redis: %w", err)
+	}
+	return code, nil
+}
+
+func (r *RedisStorage) PoolSize(ctx context.Context) (int64, error) {
+	size, err := r.client.SCard(ctx, poolKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pool size from Redis: %w", err)
+	}
+	return size, nil
+}
+
+func (r *RedisStorage) IsPooled(ctx context.Context, code string) (bool, error) {
+	pooled, err := r.client.SIsMember(ctx, poolKey, code).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check pool membership in Redis: %w", err)
+	}
+	return pooled, nil
+}
+
+func (r *RedisStorage) IncrementPoolCounter(ctx context.Context, n int64) (int64, error) {
+	value, err := r.client.IncrBy(ctx, poolCounterKey, n).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment pool counter in Redis: %w", err)
+	}
+	return value, nil
+}
+
+// LoadRules returns the JSON policy rule set stored under policy:rules, or an
+// empty rule set if none has been stored.
+func (r *RedisStorage) LoadRules(ctx context.Context) ([]byte, error) {
+	raw, err := r.client.Get(ctx, policyRulesKey).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return []byte("{}"), nil
+		}
+		return nil, fmt.Errorf("failed to get policy rules from Redis: %w", err)
+	}
+	return raw, nil
+}
+
+func (r *RedisStorage) FlagURL(ctx context.Context, shortCode, reason string) error {
+	if err := r.client.Set(ctx, flagKeyPrefix+shortCode, reason, LinkTTL).Err(); err != nil {
+		return fmt.Errorf("failed to flag URL in Redis: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) GetFlag(ctx context.Context, shortCode string) (string, bool, error) {
+	reason, err := r.client.Get(ctx, flagKeyPrefix+shortCode).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to get URL flag from Redis: %w", err)
+	}
+	return reason, true, nil
+}
+
+func (r *RedisStorage) ClearFlag(ctx context.Context, shortCode string) error {
+	if err := r.client.Del(ctx, flagKeyPrefix+shortCode).Err(); err != nil {
+		return fmt.Errorf("failed to clear URL flag in Redis: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) StoreMetadata(ctx context.Context, shortCode string, metadata []byte) error {
+	if err := r.client.Set(ctx, metaKeyPrefix+shortCode, metadata, LinkTTL).Err(); err != nil {
+		return fmt.Errorf("failed to store metadata in Redis: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) GetMetadata(ctx context.Context, shortCode string) ([]byte, bool, error) {
+	metadata, err := r.client.Get(ctx, metaKeyPrefix+shortCode).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get metadata from Redis: %w", err)
+	}
+	return metadata, true, nil
+}
+
+func (r *RedisStorage) DeleteMetadata(ctx context.Context, shortCode string) error {
+	if err := r.client.Del(ctx, metaKeyPrefix+shortCode).Err(); err != nil {
+		return fmt.Errorf("failed to delete metadata from Redis: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) StoreAICache(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := r.client.Set(ctx, aiCacheKeyPrefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to store AI cache entry in Redis: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) GetAICache(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, aiCacheKeyPrefix+key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get AI cache entry from Redis: %w", err)
+	}
+	return value, true, nil
+}
+
+func (r *RedisStorage) DeleteAICache(ctx context.Context, key string) error {
+	if err := r.client.Del(ctx, aiCacheKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("failed to delete AI cache entry from Redis: %w", err)
+	}
+	return nil
+}
+
+// AddAIUsage adds token counts to the day's hash, one field per tenant and
+// kind of token.
+func (r *RedisStorage) AddAIUsage(ctx context.Context, day, tenant string, promptTokens, completionTokens int64) error {
+	key := aiUsageKeyPrefix + day
+	pipe := r.client.TxPipeline()
+	pipe.HIncrBy(ctx, key, tenant+":prompt", promptTokens)
+	pipe.HIncrBy(ctx, key, tenant+":completion", completionTokens)
+	pipe.Expire(ctx, key, AIUsageRetention)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record AI usage in Redis: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStorage) GetAIUsage(ctx context.Context, day string) (map[string]int64, error) {
+	fields, err := r.client.HGetAll(ctx, aiUsageKeyPrefix+day).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AI usage from Redis: %w", err)
+	}
+	return parseAIUsage(fields), nil
+}
+
+// ReserveAIUsage changes the tenant's reserved tokens and reads the day's
+// counters in one transaction, so concurrent calls see each other's
+// reservations.
+func (r *RedisStorage) ReserveAIUsage(ctx context.Context, day, tenant string, tokens int64) (map[string]int64, error) {
+	key := aiUsageKeyPrefix + day
+	pipe := r.client.TxPipeline()
+	pipe.HIncrBy(ctx, key, tenant+":reserved", tokens)
+	pipe.Expire(ctx, key, AIUsageRetention)
+	fields := pipe.HGetAll(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to reserve AI usage in Redis: %w", err)
+	}
+	return parseAIUsage(fields.Val()), nil
+}
+
+func parseAIUsage(fields map[string]string) map[string]int64 {
+	counters := make(map[string]int64, len(fields))
+	for field, value := range fields {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		counters[field] = count
+	}
+	return counters
+}
+
+// ForEachURL calls fn for every link stored under the prefixed layout. Legacy
+// unprefixed keys cannot be told apart from other data and are skipped.
+func (r *RedisStorage) ForEachURL(ctx context.Context, fn func(shortCode, originalURL string) error) error {
+	iter := r.client.Scan(ctx, 0, urlKeyPrefix+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		originalURL, err := r.client.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get URL from Redis: %w", err)
+		}
+		if err := fn(strings.TrimPrefix(key, urlKeyPrefix), originalURL); err != nil {
+			return err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to scan URLs in Redis: %w", err)
+	}
+	return nil
+}
+
 func (r *RedisStorage) Close() error {
 	return r.client.Close()
 }
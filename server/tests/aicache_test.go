@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedAISlugService_ReusesSlugsForSameURL(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	store := NewMockAICacheStore()
+	service := services.NewCachedAISlugService(mockAI, store, services.AICacheConfig{TTL: time.Hour})
+	mockAI.On("GenerateSlugCandidates", mock.Anything, "https://go.dev", 3).Return([]string{"godev", "golang", "gosite"}, nil).Once()
+
+	first, err := service.GenerateSlugCandidates(context.Background(), "https://go.dev", 3)
+	require.NoError(t, err)
+	second, err := service.GenerateSlugCandidates(context.Background(), "https://go.dev", 3)
+	require.NoError(t, err)
+	slug, err := service.GenerateSlug(context.Background(), "https://go.dev")
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, "godev", slug, "Fewer candidates are served from a larger cached set")
+	mockAI.AssertNumberOfCalls(t, "GenerateSlugCandidates", 1)
+	for _, ttl := range store.ttls {
+		assert.Equal(t, time.Hour, ttl)
+	}
+}
+
+func TestCachedAISlugService_MoreCandidatesIsAMiss(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	service := services.NewCachedAISlugService(mockAI, NewMockAICacheStore(), services.AICacheConfig{})
+	mockAI.On("GenerateSlug", mock.Anything, "https://go.dev").Return("godev", nil).Once()
+	mockAI.On("GenerateSlugCandidates", mock.Anything, "https://go.dev", 3).Return([]string{"godev", "golang"}, nil).Once()
+
+	_, err := service.GenerateSlug(context.Background(), "https://go.dev")
+	require.NoError(t, err)
+	candidates, err := service.GenerateSlugCandidates(context.Background(), "https://go.dev", 3)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"godev", "golang"}, candidates)
+	mockAI.AssertExpectations(t)
+}
+
+func TestCachedAISlugService_ErrorsAreNotCached(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	store := NewMockAICacheStore()
+	service := services.NewCachedAISlugService(mockAI, store, services.AICacheConfig{})
+	mockAI.On("GenerateSlug", mock.Anything, "https://go.dev").Return("", assert.AnError).Once()
+	mockAI.On("GenerateSlug", mock.Anything, "https://go.dev").Return("godev", nil).Once()
+
+	_, err := service.GenerateSlug(context.Background(), "https://go.dev")
+	assert.Error(t, err)
+	assert.Empty(t, store.entries)
+
+	slug, err := service.GenerateSlug(context.Background(), "https://go.dev")
+	require.NoError(t, err)
+	assert.Equal(t, "godev", slug)
+}
+
+func TestCachedAISlugService_PassesDomainHints(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	service := services.NewCachedAISlugService(mockAI, NewMockAICacheStore(), services.AICacheConfig{MaxDomainHints: 2})
+
+	var hints [][]string
+	record := func(args mock.Arguments) {
+		hints = append(hints, services.SlugHintsFromContext(args.Get(0).(context.Context)))
+	}
+	mockAI.On("GenerateSlugCandidates", mock.Anything, "https://github.com/a", 2).Run(record).Return([]string{"gha", "ghuba"}, nil)
+	mockAI.On("GenerateSlugCandidates", mock.Anything, "https://github.com/b", 2).Run(record).Return([]string{"ghb"}, nil)
+	mockAI.On("GenerateSlugCandidates", mock.Anything, "https://github.com/c", 2).Run(record).Return([]string{"ghc"}, nil)
+	mockAI.On("GenerateSlugCandidates", mock.Anything, "https://go.dev/d", 2).Run(record).Return([]string{"god"}, nil)
+
+	for _, destination := range []string{"https://github.com/a", "https://github.com/b", "https://github.com/c", "https://go.dev/d"} {
+		_, err := service.GenerateSlugCandidates(context.Background(), destination, 2)
+		require.NoError(t, err)
+	}
+
+	assert.Empty(t, hints[0])
+	assert.Equal(t, []string{"gha", "ghuba"}, hints[1])
+	assert.Equal(t, []string{"ghb", "gha"}, hints[2], "The newest slugs are kept")
+	assert.Empty(t, hints[3], "Hints are kept per domain")
+}
+
+func TestCachedAISlugService_KeepsTenantsApart(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	service := services.NewCachedAISlugService(mockAI, NewMockAICacheStore(), services.AICacheConfig{})
+	teamA := services.ContextWithTenant(context.Background(), "team-a")
+	teamB := services.ContextWithTenant(context.Background(), "team-b")
+
+	forTenant := func(tenant string) interface{} {
+		return mock.MatchedBy(func(ctx context.Context) bool { return services.TenantFromContext(ctx) == tenant })
+	}
+	var hints []string
+	mockAI.On("GenerateSlug", forTenant("team-a"), mock.Anything).Return("godev", nil).Once()
+	mockAI.On("GenerateSlug", forTenant("team-b"), "https://go.dev").Run(func(args mock.Arguments) {
+		hints = services.SlugHintsFromContext(args.Get(0).(context.Context))
+	}).Return("gositio", nil).Once()
+
+	slugA, err := service.GenerateSlug(teamA, "https://go.dev")
+	require.NoError(t, err)
+	slugB, err := service.GenerateSlug(teamB, "https://go.dev")
+	require.NoError(t, err)
+	again, err := service.GenerateSlug(teamA, "https://go.dev")
+	require.NoError(t, err)
+
+	assert.Equal(t, "godev", slugA)
+	assert.Equal(t, "gositio", slugB, "Another tenant's prompt and locale are not served from the cache")
+	assert.Equal(t, "godev", again)
+	assert.Empty(t, hints, "Domain hints do not leak between tenants")
+	mockAI.AssertExpectations(t)
+}
+
+func TestURLService_CreateShortURL_ForgetsTakenCachedSlugs(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	mockStorage := NewMockRedisStorage()
+	cache := services.NewCachedAISlugService(mockAI, NewMockAICacheStore(), services.AICacheConfig{})
+	service := services.NewURLService(mockStorage, cache, "localhost", "8080")
+
+	destination := "https://go.dev"
+	mockAI.On("GenerateSlug", mock.Anything, destination).Return("godev", nil)
+	// Another link took the cached slug
+	mockStorage.On("GetURL", mock.Anything, "godev").Return("https://golang.org", nil)
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", assert.AnError)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), destination).Return(nil)
+
+	_, err := cache.GenerateSlug(context.Background(), destination)
+	require.NoError(t, err)
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: destination})
+	require.NoError(t, err)
+	assert.Equal(t, "hash_based", response.SlugType)
+	mockAI.AssertNumberOfCalls(t, "GenerateSlug", 1)
+
+	_, err = cache.GenerateSlug(context.Background(), destination)
+	require.NoError(t, err)
+	mockAI.AssertNumberOfCalls(t, "GenerateSlug", 2)
+}
+
+func TestAISlugService_PromptIncludesHints(t *testing.T) {
+	fake := NewFakeLLMServer(t, "ghc")
+	provider, err := services.NewLLMProvider(services.LLMConfig{BaseURL: fake.URL})
+	require.NoError(t, err)
+	ctx := services.ContextWithSlugHints(context.Background(), []string{"gha", "ghb"})
+
+	_, err = services.NewAISlugServiceWithProvider(provider).GenerateSlug(ctx, "https://github.com/c")
+
+	require.NoError(t, err)
+	prompt := fake.LastRequest()["messages"].([]interface{})[0].(map[string]interface{})["content"].(string)
+	assert.Contains(t, prompt, "gha, ghb")
+}
@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"go-url-shortner/services"
+	"go-url-shortner/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseAlias_Valid(t *testing.T) {
+	testCases := []struct {
+		input     string
+		code      string
+		namespace string
+	}{
+		{"oncall", "oncall", ""},
+		{"eng/oncall", "eng/oncall", "eng"},
+		{"/sales/oncall/", "sales/oncall", "sales"},
+		{"eng/team_a/on-call", "eng/team_a/on-call", "eng"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			code, namespace, err := services.ParseAlias(tc.input, false)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.code, code)
+			assert.Equal(t, tc.namespace, namespace)
+		})
+	}
+}
+
+func TestParseAlias_Invalid(t *testing.T) {
+	testCases := []string{
+		"",
+		"/",
+		"eng//oncall",
+		"a/b/c/d",
+		"has space",
+		"api/urls",
+		"health",
+		"HEALTH/check",
+	}
+
+	for _, input := range testCases {
+		t.Run(input, func(t *testing.T) {
+			_, _, err := services.ParseAlias(input, false)
+			assert.ErrorIs(t, err, services.ErrInvalidAlias)
+		})
+	}
+}
+
+func TestURLService_CreateShortURL_CustomAlias(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080")
+
+	req := services.URLRequest{URL: "https://example.com", Alias: "oncall"}
+
+	mockStorage.On("GetURL", mock.Anything, "oncall").Return("", assert.AnError)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "oncall", req.URL).Return(true, nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "oncall", response.ShortCode)
+	assert.Equal(t, "custom", response.SlugType)
+	assert.Equal(t, "http://localhost:8080/oncall", response.ShortURL)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestURLService_CreateShortURL_CustomAliasTaken(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080")
+
+	mockStorage.On("GetURL", mock.Anything, "oncall").Return("https://other.com", nil)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://example.com", Alias: "oncall"})
+
+	assert.ErrorIs(t, err, services.ErrAliasTaken)
+	assert.Nil(t, response)
+	mockStorage.AssertNotCalled(t, "StoreURLIfAbsent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestURLService_CreateShortURL_CustomAliasClaimedConcurrently(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080")
+
+	// The alias was free when checked but another request stored it first
+	mockStorage.On("GetURL", mock.Anything, "oncall").Return("", assert.AnError)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "oncall", "https://example.com").Return(false, nil)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://example.com", Alias: "oncall"})
+
+	assert.ErrorIs(t, err, services.ErrAliasTaken)
+	assert.Nil(t, response)
+	mockStorage.AssertNotCalled(t, "StoreURL", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestURLService_CreateShortURL_AliasShadowingNamespace(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080")
+	mockStorage.SetNamespaceOwner("eng", "team-eng")
+
+	ctx := services.ContextWithTenant(context.Background(), "team-sales")
+	response, err := service.CreateShortURL(ctx, services.URLRequest{URL: "https://example.com", Alias: "Eng"})
+
+	assert.ErrorIs(t, err, services.ErrNamespaceOwned)
+	assert.Nil(t, response)
+
+	mockStorage.On("GetURL", mock.Anything, "eng").Return("", assert.AnError)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "eng", "https://example.com").Return(true, nil)
+	ctx = services.ContextWithTenant(context.Background(), "team-eng")
+	response, err = service.CreateShortURL(ctx, services.URLRequest{URL: "https://example.com", Alias: "eng"})
+
+	assert.NoError(t, err, "The namespace owner may use its name as an alias")
+	assert.Equal(t, "eng", response.ShortCode)
+}
+
+func TestURLService_CreateShortURL_NamespacedAlias(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080")
+
+	ctx := services.ContextWithTenant(context.Background(), "team-eng")
+	req := services.URLRequest{URL: "https://example.com/pager", Alias: "Eng/oncall"}
+
+	mockStorage.On("ClaimNamespace", mock.Anything, "eng", "team-eng").Return("team-eng", nil)
+	mockStorage.On("GetURL", mock.Anything, "Eng/oncall").Return("", assert.AnError)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "Eng/oncall", req.URL).Return(true, nil)
+
+	response, err := service.CreateShortURL(ctx, req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Eng/oncall", response.ShortCode)
+	assert.Equal(t, "http://localhost:8080/Eng/oncall", response.ShortURL)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestURLService_CreateShortURL_NamespaceOwnedByOtherTenant(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080")
+
+	ctx := services.ContextWithTenant(context.Background(), "team-sales")
+	mockStorage.On("ClaimNamespace", mock.Anything, "eng", "team-sales").Return("team-eng", nil)
+
+	response, err := service.CreateShortURL(ctx, services.URLRequest{URL: "https://example.com", Alias: "eng/oncall"})
+
+	assert.ErrorIs(t, err, services.ErrNamespaceOwned)
+	assert.Nil(t, response)
+	mockStorage.AssertNotCalled(t, "StoreURLIfAbsent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestURLService_CreateShortURL_NamespacedAliasRequiresTenant(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080")
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://example.com", Alias: "eng/oncall"})
+
+	assert.ErrorIs(t, err, services.ErrOwnerRequired)
+	assert.Nil(t, response)
+}
+
+func TestURLService_GetOriginalURL_TrimsSlashes(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080")
+
+	mockStorage.On("GetURL", mock.Anything, "eng/oncall").Return("https://example.com", nil)
+
+	result, err := service.GetOriginalURL(context.Background(), "/eng/oncall/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", result)
+}
+
+func TestStorageKeys(t *testing.T) {
+	assert.Equal(t, "url:eng/oncall", storage.URLKey("eng/oncall"))
+	assert.Equal(t, "ns:eng", storage.NamespaceKey("eng"))
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAISlugService_GenerateSlugCandidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    string
+		n        int
+		expected []string
+	}{
+		{"JSON array", `["gobook", "gobooks", "learngo"]`, 3, []string{"gobook", "gobooks", "learngo"}},
+		{"Fenced JSON", "```json\n[\"GoBook\", \"gobook\", \"go_guide\"]\n```", 3, []string{"gobook", "goguide"}},
+		{"Capped at n", `["gobook", "gobooks", "learngo"]`, 2, []string{"gobook", "gobooks"}},
+		{"One per line", "gobook\n- gobooks\nx", 3, []string{"gobook", "gobooks"}},
+		{"Long slugs cut", `["learn-go-programming"]`, 2, []string{"learn-go"}},
+		{"Static routes dropped", `["health", "Metrics", "api", "gohealth"]`, 4, []string{"gohealth"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := NewFakeLLMServer(t, tt.reply)
+			provider, err := services.NewLLMProvider(services.LLMConfig{BaseURL: fake.URL})
+			require.NoError(t, err)
+
+			candidates, err := services.NewAISlugServiceWithProvider(provider).
+				GenerateSlugCandidates(context.Background(), "https://go.dev/learn", tt.n)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, candidates)
+		})
+	}
+}
+
+func TestAISlugService_GenerateSlugCandidates_AsksForJSON(t *testing.T) {
+	fake := NewFakeLLMServer(t, `["gobook"]`)
+	provider, err := services.NewLLMProvider(services.LLMConfig{BaseURL: fake.URL})
+	require.NoError(t, err)
+
+	_, err = services.NewAISlugServiceWithProvider(provider).GenerateSlugCandidates(context.Background(), "https://go.dev", 4)
+
+	require.NoError(t, err)
+	prompt := fake.LastRequest()["messages"].([]interface{})[0].(map[string]interface{})["content"].(string)
+	assert.Contains(t, prompt, "Generate 4 different")
+	assert.Contains(t, prompt, "JSON array")
+}
+
+func TestAISlugService_GenerateSlugCandidates_NoUsableSlugs(t *testing.T) {
+	fake := NewFakeLLMServer(t, `["!", "ab"]`)
+	provider, err := services.NewLLMProvider(services.LLMConfig{BaseURL: fake.URL})
+	require.NoError(t, err)
+
+	candidates, err := services.NewAISlugServiceWithProvider(provider).GenerateSlugCandidates(context.Background(), "https://go.dev", 3)
+
+	assert.Error(t, err)
+	assert.Empty(t, candidates)
+}
+
+func TestURLService_CreateShortURL_TriesEachAICandidate(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080", services.WithAISlugCandidates(3))
+
+	req := services.URLRequest{URL: "https://go.dev"}
+	mockAI.On("GenerateSlugCandidates", mock.Anything, req.URL, 3).Return([]string{"golang", "godev", "gosite"}, nil)
+	mockStorage.On("GetURL", mock.Anything, "golang").Return("https://golang.org", nil)
+	mockStorage.On("GetURL", mock.Anything, "godev").Return("", assert.AnError)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "godev", req.URL).Return(true, nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.Equal(t, "godev", response.ShortCode)
+	assert.Equal(t, "ai_generated", response.SlugType)
+	mockAI.AssertNotCalled(t, "GenerateSlug", mock.Anything, mock.Anything)
+	mockStorage.AssertNotCalled(t, "GetURL", mock.Anything, "gosite")
+}
+
+func TestURLService_CreateShortURL_AICandidateClaimedConcurrently(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080", services.WithAISlugCandidates(2))
+
+	req := services.URLRequest{URL: "https://go.dev"}
+	mockAI.On("GenerateSlugCandidates", mock.Anything, req.URL, 2).Return([]string{"golang", "godev"}, nil)
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", assert.AnError)
+	// Another request stores "golang" between the check and the claim
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "golang", req.URL).Return(false, nil)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "godev", req.URL).Return(true, nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.Equal(t, "godev", response.ShortCode)
+	assert.Equal(t, "ai_generated", response.SlugType)
+	mockStorage.AssertNotCalled(t, "StoreURL", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestURLService_CreateShortURL_AllAICandidatesTaken(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080", services.WithAISlugCandidates(2))
+
+	req := services.URLRequest{URL: "https://go.dev"}
+	mockAI.On("GenerateSlugCandidates", mock.Anything, req.URL, 2).Return([]string{"golang", "godev"}, nil)
+	mockStorage.On("GetURL", mock.Anything, "golang").Return("https://golang.org", nil)
+	mockStorage.On("GetURL", mock.Anything, "godev").Return("https://other.example.com", nil)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.Equal(t, "hash_based", response.SlugType)
+}
+
+func TestURLService_CreateShortURL_SingleAISlugByDefault(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080")
+
+	req := services.URLRequest{URL: "https://go.dev"}
+	mockAI.On("GenerateSlug", mock.Anything, req.URL).Return("godev", nil)
+	mockStorage.On("GetURL", mock.Anything, "godev").Return("", assert.AnError)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "godev", req.URL).Return(true, nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.Equal(t, "godev", response.ShortCode)
+	mockAI.AssertNotCalled(t, "GenerateSlugCandidates", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestURLService_CreateShortURL_ReservedAISlugFallsBack(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080")
+
+	req := services.URLRequest{URL: "https://status.example.com"}
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("health", nil)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.NotEqual(t, "health", response.ShortCode, "The health route would shadow the link")
+	assert.Equal(t, "hash_based", response.SlugType)
+}
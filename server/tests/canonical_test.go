@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var rfcRules = services.CanonicalRules{
+	Lowercase:       true,
+	DefaultPort:     true,
+	DotSegments:     true,
+	PercentEncoding: true,
+}
+
+func TestCanonicalize(t *testing.T) {
+	allRules := rfcRules
+	allRules.SortQuery = true
+	allRules.StripTracking = true
+	allRules.TrackingParams = services.DefaultTrackingParams
+
+	tests := []struct {
+		name     string
+		url      string
+		rules    services.CanonicalRules
+		expected string
+	}{
+		{"Lowercase scheme and host", "HTTPS://Example.COM/Path", rfcRules, "https://example.com/Path"},
+		{"Default HTTPS port", "https://example.com:443/a", rfcRules, "https://example.com/a"},
+		{"Default HTTP port", "http://example.com:80/a", rfcRules, "http://example.com/a"},
+		{"Non-default port kept", "https://example.com:8443/a", rfcRules, "https://example.com:8443/a"},
+		{"Dot segments", "https://example.com/a/./b/../c", rfcRules, "https://example.com/a/c"},
+		{"Dot segments above root", "https://example.com/../a", rfcRules, "https://example.com/a"},
+		{"Trailing slash kept", "https://example.com/a/", rfcRules, "https://example.com/a/"},
+		{"No trailing slash added", "https://example.com", rfcRules, "https://example.com"},
+		{"Unreserved escapes decoded", "https://example.com/%7Euser/%61bc", rfcRules, "https://example.com/~user/abc"},
+		{"Reserved escapes uppercased", "https://example.com/a%2fb?q=%3d", rfcRules, "https://example.com/a%2Fb?q=%3D"},
+		{"Query order kept by default", "https://example.com/?b=2&a=1", rfcRules, "https://example.com/?b=2&a=1"},
+		{"Query sorted", "https://example.com/?b=2&a=1&b=1", allRules, "https://example.com/?a=1&b=2&b=1"},
+		{"Tracking stripped", "https://example.com/p?utm_source=x&id=7&fbclid=abc&UTM_Medium=y", allRules, "https://example.com/p?id=7"},
+		{"Only tracking params", "https://example.com/p?utm_source=x", allRules, "https://example.com/p"},
+		{"Fragment kept", "https://example.com/p#Section", allRules, "https://example.com/p#Section"},
+		{"No rules", "HTTPS://Example.com:443/./a", services.CanonicalRules{}, "https://Example.com:443/./a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canonical, err := services.Canonicalize(tt.url, tt.rules)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, canonical)
+		})
+	}
+}
+
+func TestParseCanonicalRules(t *testing.T) {
+	rules, err := services.ParseCanonicalRules([]string{"lowercase", "sort_query"}, nil)
+
+	require.NoError(t, err)
+	assert.True(t, rules.Lowercase)
+	assert.True(t, rules.SortQuery)
+	assert.False(t, rules.StripTracking)
+	assert.Equal(t, services.DefaultTrackingParams, rules.TrackingParams)
+
+	_, err = services.ParseCanonicalRules([]string{"lowercase", "bogus"}, nil)
+	assert.Error(t, err)
+}
+
+func TestURLService_CreateShortURL_CanonicalURLsShareCode(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithCanonicalRules(rfcRules))
+
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), "https://example.com/a").Return(nil)
+
+	first, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "HTTPS://Example.com:443/a"})
+	require.NoError(t, err)
+	second, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://example.com/a"})
+	require.NoError(t, err)
+
+	assert.Equal(t, first.ShortCode, second.ShortCode)
+	assert.Equal(t, "HTTPS://Example.com:443/a", first.OriginalURL)
+	assert.Equal(t, "https://example.com/a", first.CanonicalURL)
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAISlugService_DescribeLink(t *testing.T) {
+	fake := NewFakeLLMServer(t, `Sure! {"summary": "  <b>Guidelines</b> for\nwriting   clear Go​ code ", "tags": ["Go", "go", "Style Guide", "x", "<script>", "pаypal", "a-very-long-tag-that-goes-on-and-on", "tools", "docs", "more"]}`)
+
+	description, err := newPromptAI(t, fake).DescribeLink(context.Background(), "https://go.dev/doc/effective_go")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Guidelines for writing clear Go code", description.Summary)
+	assert.Equal(t, []string{"go", "style-guide", "script", "tools", "docs"}, description.Tags)
+	assert.Contains(t, lastPrompt(fake), "JSON object")
+}
+
+func TestAISlugService_DescribeLink_TruncatesSummary(t *testing.T) {
+	fake := NewFakeLLMServer(t, `{"summary": "`+strings.Repeat("gopher ", 40)+`", "tags": []}`)
+
+	description, err := newPromptAI(t, fake).DescribeLink(context.Background(), "https://go.dev")
+
+	require.NoError(t, err)
+	assert.LessOrEqual(t, utf8.RuneCountInString(description.Summary), services.MaxSummaryLength+1)
+	assert.True(t, strings.HasSuffix(description.Summary, "gopher…"))
+}
+
+func TestAISlugService_DescribeLink_UnusableReply(t *testing.T) {
+	for _, reply := range []string{"A site about Go", `{"summary": "<br>", "tags": ["!"]}`} {
+		fake := NewFakeLLMServer(t, reply)
+
+		_, err := newPromptAI(t, fake).DescribeLink(context.Background(), "https://go.dev")
+
+		assert.Error(t, err, reply)
+	}
+}
+
+func TestAISlugService_DescribeLink_OwnTokenLimit(t *testing.T) {
+	fake := NewFakeLLMServer(t, `{"summary": "Go documentation", "tags": ["go"]}`)
+	provider, err := services.NewLLMProvider(services.LLMConfig{BaseURL: fake.URL, MaxTokens: 128})
+	require.NoError(t, err)
+
+	_, err = services.NewAISlugServiceWithProvider(provider).DescribeLink(context.Background(), "https://go.dev")
+	require.NoError(t, err)
+	assert.Equal(t, float64(services.DefaultDescribeMaxTokens), fake.LastRequest()["max_tokens"], "The slug limit would cut off the JSON")
+
+	_, err = services.NewAISlugServiceWithProvider(provider, services.WithDescribeMaxTokens(600)).DescribeLink(context.Background(), "https://go.dev")
+	require.NoError(t, err)
+	assert.Equal(t, float64(600), fake.LastRequest()["max_tokens"])
+
+	fake.Reply = "godev"
+	_, err = services.NewAISlugServiceWithProvider(provider).GenerateSlug(context.Background(), "https://go.dev")
+	require.NoError(t, err)
+	assert.Equal(t, float64(128), fake.LastRequest()["max_tokens"], "Slugs keep the provider's limit")
+}
+
+func TestURLService_CreateShortURL_Describe(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	metadata := NewMockMetadataStore()
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080", services.WithMetadataStore(metadata))
+
+	req := services.URLRequest{URL: "https://go.dev/doc", Describe: true}
+	description := &services.LinkDescription{Summary: "Go documentation", Tags: []string{"go", "docs"}}
+	mockAI.On("GenerateSlug", mock.Anything, req.URL).Return("", assert.AnError)
+	mockAI.On("DescribeLink", mock.Anything, req.URL).Return(description, nil)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.Equal(t, "Go documentation", response.Summary)
+	assert.Equal(t, []string{"go", "docs"}, response.Tags)
+
+	var stored services.LinkMetadata
+	require.NoError(t, json.Unmarshal(metadata.metadata[response.ShortCode], &stored))
+	assert.Equal(t, "Go documentation", stored.Summary)
+	assert.Equal(t, []string{"go", "docs"}, stored.Tags)
+}
+
+func TestURLService_CreateShortURL_DescribeUnavailable(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080")
+	withoutAI := services.NewURLService(mockStorage, nil, "localhost", "8080")
+
+	req := services.URLRequest{URL: "https://go.dev/doc", Describe: true}
+	mockAI.On("GenerateSlug", mock.Anything, req.URL).Return("", assert.AnError)
+	mockAI.On("DescribeLink", mock.Anything, req.URL).Return(nil, services.ErrCircuitOpen)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	for _, s := range []*services.URLService{service, withoutAI} {
+		response, err := s.CreateShortURL(context.Background(), req)
+
+		require.NoError(t, err, "Links are created without a description")
+		assert.Empty(t, response.Summary)
+		assert.Contains(t, response.Warnings, "link description is unavailable")
+	}
+}
+
+func TestURLService_CreateShortURL_ReshortenKeepsDescription(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	metadata := NewMockMetadataStore()
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080", services.WithMetadataStore(metadata))
+
+	req := services.URLRequest{URL: "https://go.dev/doc", Describe: true}
+	mockAI.On("GenerateSlug", mock.Anything, req.URL).Return("", assert.AnError)
+	mockAI.On("DescribeLink", mock.Anything, req.URL).Return(&services.LinkDescription{Summary: "Go documentation", Tags: []string{"go"}}, nil).Once()
+	mockAI.On("DescribeLink", mock.Anything, req.URL).Return(nil, services.ErrCircuitOpen)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	first, err := service.CreateShortURL(context.Background(), req)
+	require.NoError(t, err)
+	again, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: req.URL})
+	require.NoError(t, err)
+	failed, err := service.CreateShortURL(context.Background(), req)
+	require.NoError(t, err)
+
+	assert.Equal(t, first.ShortCode, again.ShortCode)
+	assert.Equal(t, "Go documentation", again.Summary, "Re-shortening without describe keeps the summary")
+	assert.Equal(t, []string{"go"}, failed.Tags, "A failed description does not wipe the stored one")
+
+	var stored services.LinkMetadata
+	require.NoError(t, json.Unmarshal(metadata.metadata[first.ShortCode], &stored))
+	assert.Equal(t, "Go documentation", stored.Summary)
+	assert.Equal(t, []string{"go"}, stored.Tags)
+}
+
+func TestURLService_CreateShortURL_DescribeIsOptIn(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080")
+
+	req := services.URLRequest{URL: "https://go.dev/doc"}
+	mockAI.On("GenerateSlug", mock.Anything, req.URL).Return("", assert.AnError)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	_, err := service.CreateShortURL(context.Background(), req)
+
+	require.NoError(t, err)
+	mockAI.AssertNotCalled(t, "DescribeLink", mock.Anything, mock.Anything)
+}
+
+func TestResilientAISlugService_DescribeLink(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	mockAI.On("DescribeLink", mock.Anything, "https://go.dev").Return(nil, errProviderUnavailable).Once()
+	mockAI.On("DescribeLink", mock.Anything, "https://go.dev").Return(&services.LinkDescription{Summary: "Go"}, nil).Once()
+	service := services.NewCachedAISlugService(newTestResilientAI(mockAI, services.ResilienceConfig{MaxRetries: 1}), NewMockAICacheStore(), services.AICacheConfig{})
+
+	description, err := service.DescribeLink(context.Background(), "https://go.dev")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Go", description.Summary)
+	mockAI.AssertNumberOfCalls(t, "DescribeLink", 2)
+}
@@ -236,3 +236,20 @@ func TestShortHash_Base62Compliance(t *testing.T) {
 			"Hash should only contain base62 characters: %c", char)
 	}
 }
+
+func TestShortHashFolded_LowercaseOnly(t *testing.T) {
+	inputs := []string{"https://example.com", "https://github.com", "https://example.com/Path?Q=1"}
+
+	for _, input := range inputs {
+		hash := utils.ShortHashFolded(input)
+		assert.NotEmpty(t, hash)
+		assert.Regexp(t, `^[0-9a-z]+$`, hash, "Folded hash should only contain lowercase letters and digits")
+		assert.Equal(t, hash, utils.ShortHashFolded(input), "Folded hash should be deterministic")
+	}
+}
+
+func TestBase36Encode(t *testing.T) {
+	assert.Equal(t, "0", utils.Base36Encode(0))
+	assert.Equal(t, "z", utils.Base36Encode(35))
+	assert.Equal(t, "10", utils.Base36Encode(36))
+}
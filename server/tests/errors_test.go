@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-url-shortner/handlers"
+	"go-url-shortner/middleware"
+	"go-url-shortner/services"
+	"go-url-shortner/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func postURL(t *testing.T, handler *handlers.URLHandler, body services.URLRequest) (int, utils.ErrorResponse) {
+	t.Helper()
+	router := setupTestRouter()
+	router.POST("/api/urls", handler.CreateShortURL)
+
+	jsonBody, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/api/urls", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response utils.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return w.Code, response
+}
+
+func TestCreateShortURL_ValidationErrorEnvelope(t *testing.T) {
+	handler := handlers.NewURLHandler(new(MockURLService))
+
+	testCases := []struct {
+		url  string
+		code string
+	}{
+		{"", services.CodeURLRequired},
+		{"not_a_url", services.CodeInvalidDomain},
+		{".example.com", services.CodeInvalidHost},
+		{"https://ex@mple.com", services.CodeCredentialsInURL},
+		{"ftp://example.com", services.CodeUnsupportedScheme},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			status, response := postURL(t, handler, services.URLRequest{URL: tc.url})
+
+			assert.Equal(t, http.StatusBadRequest, status)
+			assert.Equal(t, tc.code, response.Code)
+			assert.Equal(t, "url", response.Field)
+			assert.NotEmpty(t, response.Error)
+		})
+	}
+}
+
+func TestCreateShortURL_ServiceErrorEnvelope(t *testing.T) {
+	testCases := []struct {
+		err    error
+		status int
+		code   string
+		field  string
+	}{
+		{services.ErrInvalidAlias, http.StatusBadRequest, handlers.CodeInvalidAlias, "alias"},
+		{services.ErrOwnerRequired, http.StatusUnauthorized, handlers.CodeOwnerRequired, "alias"},
+		{fmt.Errorf("%w: eng", services.ErrNamespaceOwned), http.StatusForbidden, handlers.CodeNamespaceOwned, "alias"},
+		{fmt.Errorf("%w: promo", services.ErrAliasTaken), http.StatusConflict, handlers.CodeAliasTaken, "alias"},
+		{services.ErrThreatDetected, http.StatusUnprocessableEntity, handlers.CodeThreatDetected, "url"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.code, func(t *testing.T) {
+			mockService := new(MockURLService)
+			mockService.On("CreateShortURL", mock.Anything, mock.Anything).Return(nil, tc.err)
+			handler := handlers.NewURLHandler(mockService)
+
+			status, response := postURL(t, handler, services.URLRequest{URL: "https://example.com", Alias: "promo"})
+
+			assert.Equal(t, tc.status, status)
+			assert.Equal(t, tc.code, response.Code)
+			assert.Equal(t, tc.field, response.Field)
+			assert.Equal(t, tc.err.Error(), response.Error)
+		})
+	}
+}
+
+func TestErrorResponse_DocsURL(t *testing.T) {
+	_, response := postURL(t, handlers.NewURLHandler(new(MockURLService)), services.URLRequest{URL: "not_a_url"})
+	assert.Empty(t, response.DocsURL, "Without ERROR_DOCS_URL there is no page clients could follow")
+
+	handler := handlers.NewURLHandler(new(MockURLService), handlers.WithErrorDocsURL("https://docs.example.com/errors"))
+	_, response = postURL(t, handler, services.URLRequest{URL: "not_a_url"})
+	assert.Equal(t, "https://docs.example.com/errors#invalid_domain", response.DocsURL)
+
+	handler = handlers.NewURLHandler(new(MockURLService), handlers.WithErrorDocsURL(""))
+	_, response = postURL(t, handler, services.URLRequest{URL: "not_a_url"})
+	assert.Empty(t, response.DocsURL)
+}
+
+func TestErrorResponse_InternalErrorHidesDetails(t *testing.T) {
+	mockService := new(MockURLService)
+	mockService.On("CreateShortURL", mock.Anything, mock.Anything).Return(nil, errors.New("dial tcp 10.0.0.5:6379: connection refused"))
+
+	status, response := postURL(t, handlers.NewURLHandler(mockService), services.URLRequest{URL: "https://example.com"})
+
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, handlers.CodeInternal, response.Code)
+	assert.NotContains(t, response.Error, "10.0.0.5")
+}
+
+func TestAdminAuthMiddleware_ErrorEnvelope(t *testing.T) {
+	router := setupTestRouter()
+	router.GET("/admin", middleware.AdminAuthMiddleware("secret", "https://docs.example.com/errors"), func(c *gin.Context) {})
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response utils.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, middleware.CodeInvalidAdminToken, response.Code)
+	assert.Equal(t, "https://docs.example.com/errors#invalid_admin_token", response.DocsURL)
+}
+
+func TestValidateErrorDocsURL(t *testing.T) {
+	assert.NoError(t, utils.ValidateErrorDocsURL(""))
+	assert.NoError(t, utils.ValidateErrorDocsURL("https://docs.example.com/errors"))
+	assert.Error(t, utils.ValidateErrorDocsURL("docs/errors.md"))
+	assert.Error(t, utils.ValidateErrorDocsURL("/errors"))
+	assert.Error(t, utils.ValidateErrorDocsURL("ftp://docs.example.com/errors"))
+}
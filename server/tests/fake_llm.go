@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Token counts FakeLLMServer reports for every reply
+const (
+	fakePromptTokens     = 40
+	fakeCompletionTokens = 5
+)
+
+// FakeLLMServer answers the OpenAI, Ollama and Anthropic chat APIs with a
+// fixed reply and records the requests it receives
+type FakeLLMServer struct {
+	*httptest.Server
+
+	sync.Mutex
+	Reply    string
+	Status   int
+	Requests []map[string]interface{}
+	Headers  []http.Header
+}
+
+func NewFakeLLMServer(t *testing.T, reply string) *FakeLLMServer {
+	t.Helper()
+	fake := &FakeLLMServer{Reply: reply, Status: http.StatusOK}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat/completions", fake.handle(func(reply string) interface{} {
+		return map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": reply}},
+			},
+			"usage": map[string]int{"prompt_tokens": fakePromptTokens, "completion_tokens": fakeCompletionTokens},
+		}
+	}))
+	mux.HandleFunc("/api/chat", fake.handle(func(reply string) interface{} {
+		return map[string]interface{}{
+			"message":           map[string]string{"role": "assistant", "content": reply},
+			"done":              true,
+			"prompt_eval_count": fakePromptTokens,
+			"eval_count":        fakeCompletionTokens,
+		}
+	}))
+	mux.HandleFunc("/v1/messages", fake.handle(func(reply string) interface{} {
+		return map[string]interface{}{
+			"content": []map[string]string{{"type": "text", "text": reply}},
+			"usage":   map[string]int{"input_tokens": fakePromptTokens, "output_tokens": fakeCompletionTokens},
+		}
+	}))
+
+	fake.Server = httptest.NewServer(mux)
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *FakeLLMServer) handle(body func(reply string) interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+
+		f.Lock()
+		f.Requests = append(f.Requests, request)
+		f.Headers = append(f.Headers, r.Header.Clone())
+		reply, status := f.Reply, f.Status
+		f.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			json.NewEncoder(w).Encode(body(reply))
+		}
+	}
+}
+
+// LastRequest returns the decoded body of the most recent request
+func (f *FakeLLMServer) LastRequest() map[string]interface{} {
+	f.Lock()
+	defer f.Unlock()
+	if len(f.Requests) == 0 {
+		return nil
+	}
+	return f.Requests[len(f.Requests)-1]
+}
@@ -114,7 +114,7 @@ func TestCreateShortURL_ServiceError(t *testing.T) {
 	var response map[string]string
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, assert.AnError.Error(), response["error"], "Error message mismatch for service error")
+	assert.Equal(t, "Internal server error", response["error"], "Internal errors should not be shown to clients")
 
 	mockService.AssertExpectations(t)
 }
@@ -268,3 +268,57 @@ func TestCreateShortURL_NormalizeURL_Success(t *testing.T) {
 	assert.Equal(t, expectedResponse.ShortCode, response.ShortCode)
 	mockService.AssertExpectations(t)
 }
+
+func TestRedirectToURL_MultiSegment(t *testing.T) {
+	router := setupTestRouter()
+	mockService := new(MockURLService)
+	handler := handlers.NewURLHandler(mockService)
+
+	mockService.On("GetOriginalURL", mock.Anything, "eng/oncall").Return("https://eng.example.com", nil)
+	mockService.On("GetOriginalURL", mock.Anything, "sales/oncall").Return("https://sales.example.com", nil)
+
+	router.GET("/health", handler.HealthCheck)
+	router.GET("/:shortCode", handler.RedirectToURL)
+	router.GET("/:shortCode/*path", handler.RedirectToURL)
+
+	for path, expected := range map[string]string{
+		"/eng/oncall":   "https://eng.example.com",
+		"/sales/oncall": "https://sales.example.com",
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code, "Expected HTTP status 301 for %s", path)
+		assert.Equal(t, expected, w.Header().Get("Location"))
+	}
+
+	// Static routes still take precedence over short codes
+	req := httptest.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateShortURL_AliasConflict(t *testing.T) {
+	router := setupTestRouter()
+	mockService := new(MockURLService)
+	handler := handlers.NewURLHandler(mockService)
+
+	requestBody := services.URLRequest{URL: "https://example.com", Alias: "eng/oncall"}
+	mockService.On("CreateShortURL", mock.Anything, requestBody).Return(nil, services.ErrNamespaceOwned)
+
+	router.POST("/api/urls", handler.CreateShortURL)
+
+	jsonBody, _ := json.Marshal(requestBody)
+	req := httptest.NewRequest("POST", "/api/urls", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code, "Expected HTTP status 403 for a namespace owned by another tenant")
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLValidator_NormalizeURL_ConvertsIDNHost(t *testing.T) {
+	validator := services.NewURLValidator()
+
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{"Unicode host", "https://bücher.de/katalog?q=ü", "https://xn--bcher-kva.de/katalog?q=ü"},
+		{"Unicode host without scheme", "münchen.de", "https://xn--mnchen-3ya.de"},
+		{"Unicode host with port", "https://bücher.de:8443/", "https://xn--bcher-kva.de:8443/"},
+		{"Uppercase Unicode host", "https://BÜCHER.de", "https://xn--bcher-kva.de"},
+		{"Punycode host unchanged", "https://xn--bcher-kva.de", "https://xn--bcher-kva.de"},
+		{"ASCII host unchanged", "https://Example.com/a", "https://Example.com/a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized, err := validator.NormalizeURL(tt.url)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, normalized)
+		})
+	}
+}
+
+func TestURLValidator_InvalidIDNHost(t *testing.T) {
+	validator := services.NewURLValidator()
+
+	err := validator.ValidateURL("https://a\u200db.de")
+
+	assert.Error(t, err)
+}
+
+func TestIsConfusableHost(t *testing.T) {
+	tests := []struct {
+		host       string
+		confusable bool
+	}{
+		{"apple.com", false},
+		{"bücher.de", false},
+		{"xn--bcher-kva.de", false},
+		{"東京.jp", false},
+		{"аpple.com", true},
+		{"xn--pple-43d.com", true},
+		{"раураӏ.com", true},
+		{"пример.рф", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.confusable, services.IsConfusableHost(tt.host))
+		})
+	}
+}
+
+func TestURLValidator_RejectsConfusableHost(t *testing.T) {
+	validator := services.NewURLValidator(services.WithHomographCheck())
+
+	err := validator.ValidateURL("https://аpple.com/login")
+
+	var validationErr *services.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, services.CodeConfusableHost, validationErr.Code)
+	assert.NoError(t, validator.ValidateURL("https://bücher.de"))
+}
+
+func TestURLService_CreateShortURL_IDNDisplayAndWarning(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080")
+
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://xn--bcher-kva.de/katalog"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://bücher.de/katalog", response.DisplayURL)
+	assert.Empty(t, response.Warnings)
+
+	response, err = service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://xn--pple-43d.com/login"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://аpple.com/login", response.DisplayURL)
+	assert.Len(t, response.Warnings, 1)
+
+	response, err = service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.Empty(t, response.DisplayURL)
+}
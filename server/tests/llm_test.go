@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLLMProvider_Providers(t *testing.T) {
+	for _, name := range []string{services.ProviderOpenAI, services.ProviderOllama, services.ProviderAnthropic} {
+		t.Run(name, func(t *testing.T) {
+			fake := NewFakeLLMServer(t, "gobook")
+			provider, err := services.NewLLMProvider(services.LLMConfig{
+				Provider:    name,
+				APIKey:      "test-key",
+				BaseURL:     fake.URL + "/",
+				Model:       "local-model",
+				Temperature: 0.2,
+				MaxTokens:   12,
+			})
+			require.NoError(t, err)
+
+			completion, err := provider.Complete(context.Background(), "make a slug")
+
+			require.NoError(t, err)
+			assert.Equal(t, "gobook", completion.Text)
+			assert.Equal(t, services.TokenUsage{PromptTokens: fakePromptTokens, CompletionTokens: fakeCompletionTokens}, completion.Usage)
+			assert.Equal(t, "local-model", fake.LastRequest()["model"])
+		})
+	}
+}
+
+func TestNewLLMProvider_SendsConfiguredParameters(t *testing.T) {
+	fake := NewFakeLLMServer(t, "slug")
+	provider, err := services.NewLLMProvider(services.LLMConfig{
+		BaseURL:     fake.URL,
+		Temperature: 0.25,
+		MaxTokens:   20,
+	})
+	require.NoError(t, err)
+
+	_, err = provider.Complete(context.Background(), "make a slug")
+	require.NoError(t, err)
+
+	request := fake.LastRequest()
+	assert.Equal(t, "gpt-3.5-turbo", request["model"], "OpenAI's default model is used when none is configured")
+	assert.Equal(t, 0.25, request["temperature"])
+	assert.Equal(t, float64(20), request["max_tokens"])
+}
+
+func TestNewLLMProvider_AnthropicHeaders(t *testing.T) {
+	fake := NewFakeLLMServer(t, "slug")
+	provider, err := services.NewLLMProvider(services.LLMConfig{
+		Provider:  services.ProviderAnthropic,
+		APIKey:    "secret",
+		BaseURL:   fake.URL,
+		MaxTokens: 10,
+	})
+	require.NoError(t, err)
+
+	_, err = provider.Complete(context.Background(), "make a slug")
+	require.NoError(t, err)
+
+	assert.Equal(t, "secret", fake.Headers[0].Get("x-api-key"))
+	assert.NotEmpty(t, fake.Headers[0].Get("anthropic-version"))
+}
+
+func TestNewLLMProvider_InvalidConfig(t *testing.T) {
+	_, err := services.NewLLMProvider(services.LLMConfig{Provider: "palm"})
+	assert.Error(t, err)
+
+	_, err = services.NewLLMProvider(services.LLMConfig{Provider: services.ProviderOpenAI})
+	assert.Error(t, err, "OpenAI needs an API key unless a base URL is set")
+
+	_, err = services.NewLLMProvider(services.LLMConfig{Provider: services.ProviderAnthropic})
+	assert.Error(t, err)
+
+	_, err = services.NewLLMProvider(services.LLMConfig{Provider: services.ProviderOllama})
+	assert.NoError(t, err, "Ollama runs locally without an API key")
+}
+
+func TestAISlugService_WithProvider(t *testing.T) {
+	fake := NewFakeLLMServer(t, "  Go-Books!  ")
+	provider, err := services.NewLLMProvider(services.LLMConfig{Provider: services.ProviderOllama, BaseURL: fake.URL})
+	require.NoError(t, err)
+	service := services.NewAISlugServiceWithProvider(provider)
+
+	slug, err := service.GenerateSlug(context.Background(), "https://books.example.com/go")
+
+	require.NoError(t, err)
+	assert.Equal(t, "go-books", slug)
+
+	messages := fake.LastRequest()["messages"].([]interface{})
+	prompt := messages[0].(map[string]interface{})["content"].(string)
+	assert.True(t, strings.Contains(prompt, "books.example.com"))
+}
+
+func TestAISlugService_ProviderError(t *testing.T) {
+	fake := NewFakeLLMServer(t, "")
+	fake.Status = http.StatusServiceUnavailable
+	provider, err := services.NewLLMProvider(services.LLMConfig{BaseURL: fake.URL})
+	require.NoError(t, err)
+
+	slug, err := services.NewAISlugServiceWithProvider(provider).GenerateSlug(context.Background(), "https://example.com")
+
+	assert.Error(t, err)
+	assert.Empty(t, slug)
+	assert.Nil(t, services.NewAISlugServiceWithProvider(nil))
+}
+
+func TestNewLLMProvider_ReportsRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	t.Cleanup(server.Close)
+	provider, err := services.NewLLMProvider(services.LLMConfig{Provider: services.ProviderOllama, BaseURL: server.URL})
+	require.NoError(t, err)
+
+	_, err = provider.Complete(context.Background(), "slug please")
+
+	var statusErr *services.ProviderStatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusTooManyRequests, statusErr.StatusCode)
+	assert.Equal(t, 3*time.Second, statusErr.RetryAfter)
+}
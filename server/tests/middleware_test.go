@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-url-shortner/middleware"
+	"go-url-shortner/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantMiddleware(t *testing.T) {
+	router := setupTestRouter()
+	router.Use(middleware.TenantMiddleware())
+	router.GET("/whoami", func(c *gin.Context) {
+		c.String(http.StatusOK, services.TenantFromContext(c.Request.Context()))
+	})
+
+	// Anonymous request
+	req := httptest.NewRequest("GET", "/whoami", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "", w.Body.String())
+
+	// Same key always maps to the same tenant, and the raw key is never exposed
+	req = httptest.NewRequest("GET", "/whoami", nil)
+	req.Header.Set("X-API-Key", "secret-key")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, middleware.TenantID("secret-key"), w.Body.String())
+	assert.NotContains(t, w.Body.String(), "secret-key")
+	assert.NotEqual(t, middleware.TenantID("secret-key"), middleware.TenantID("other-key"))
+}
@@ -9,13 +9,15 @@ import (
 
 type MockRedisStorage struct {
 	mock.Mock
-	urls map[string]string
+	urls       map[string]string
+	namespaces map[string]string
 	sync.RWMutex
 }
 
 func NewMockRedisStorage() *MockRedisStorage {
 	return &MockRedisStorage{
-		urls: make(map[string]string),
+		urls:       make(map[string]string),
+		namespaces: make(map[string]string),
 	}
 }
 
@@ -33,6 +35,20 @@ func (m *MockRedisStorage) StoreURL(ctx context.Context, shortCode, originalURL
 	return args.Error(0)
 }
 
+// StoreURLIfAbsent mocks storing a shortCode unless it is already taken
+func (m *MockRedisStorage) StoreURLIfAbsent(ctx context.Context, shortCode, originalURL string) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	args := m.Called(ctx, shortCode, originalURL)
+
+	if args.Bool(0) && args.Error(1) == nil {
+		m.urls[shortCode] = originalURL
+	}
+
+	return args.Bool(0), args.Error(1)
+}
+
 // GetURL mocks retrieving the originalURL for a given shortCode
 func (m *MockRedisStorage) GetURL(ctx context.Context, shortCode string) (string, error) {
 	m.RLock()
@@ -53,6 +69,41 @@ func (m *MockRedisStorage) GetURL(ctx context.Context, shortCode string) (string
 	return "", args.Error(1)
 }
 
+// DeleteURL mocks removing a shortCode mapping
+func (m *MockRedisStorage) DeleteURL(ctx context.Context, shortCode string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	args := m.Called(ctx, shortCode)
+
+	if args.Error(0) == nil {
+		delete(m.urls, shortCode)
+	}
+
+	return args.Error(0)
+}
+
+// ClaimNamespace mocks claiming a namespace and returns the effective owner
+func (m *MockRedisStorage) ClaimNamespace(ctx context.Context, namespace, owner string) (string, error) {
+	args := m.Called(ctx, namespace, owner)
+	return args.String(0), args.Error(1)
+}
+
+// NamespaceOwner returns owners recorded with SetNamespaceOwner
+func (m *MockRedisStorage) NamespaceOwner(ctx context.Context, namespace string) (string, bool, error) {
+	m.RLock()
+	defer m.RUnlock()
+	owner, claimed := m.namespaces[namespace]
+	return owner, claimed, nil
+}
+
+// SetNamespaceOwner records owner as the owner of namespace
+func (m *MockRedisStorage) SetNamespaceOwner(namespace, owner string) {
+	m.Lock()
+	defer m.Unlock()
+	m.namespaces[namespace] = owner
+}
+
 // Close mocks closing the Redis connection
 func (m *MockRedisStorage) Close() error {
 	args := m.Called()
@@ -2,6 +2,10 @@ package tests
 
 import (
 	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
 
 	"go-url-shortner/services"
 
@@ -26,6 +30,32 @@ func (m *MockURLService) GetOriginalURL(ctx context.Context, shortCode string) (
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockURLService) DeleteURL(ctx context.Context, shortCode string) error {
+	args := m.Called(ctx, shortCode)
+	return args.Error(0)
+}
+
+func (m *MockURLService) ReleaseCode(ctx context.Context, shortCode string) error {
+	args := m.Called(ctx, shortCode)
+	return args.Error(0)
+}
+
+func (m *MockURLService) GetLinkMetadata(ctx context.Context, shortCode string) (*services.LinkMetadata, error) {
+	args := m.Called(ctx, shortCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.LinkMetadata), args.Error(1)
+}
+
+func (m *MockURLService) SuggestSlugs(ctx context.Context, rawURL string) (*services.SlugSuggestions, error) {
+	args := m.Called(ctx, rawURL)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.SlugSuggestions), args.Error(1)
+}
+
 // MockAISlugService is a mock implementation that can be used where services.AISlugServiceInterface is expected
 type MockAISlugService struct {
 	mock.Mock
@@ -35,3 +65,286 @@ func (m *MockAISlugService) GenerateSlug(ctx context.Context, originalURL string
 	args := m.Called(ctx, originalURL)
 	return args.String(0), args.Error(1)
 }
+
+func (m *MockAISlugService) GenerateSlugCandidates(ctx context.Context, originalURL string, n int) ([]string, error) {
+	args := m.Called(ctx, originalURL, n)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockAISlugService) DescribeLink(ctx context.Context, originalURL string) (*services.LinkDescription, error) {
+	args := m.Called(ctx, originalURL)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.LinkDescription), args.Error(1)
+}
+
+// MockCodePoolStore is an in-memory implementation of services.CodePoolStore
+type MockCodePoolStore struct {
+	sync.Mutex
+	codes   []string
+	counter int64
+	PopErr  error
+}
+
+func (m *MockCodePoolStore) AddPoolCodes(ctx context.Context, codes ...string) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.codes = append(m.codes, codes...)
+	return int64(len(codes)), nil
+}
+
+func (m *MockCodePoolStore) PopPoolCode(ctx context.Context) (string, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.PopErr != nil {
+		return "", m.PopErr
+	}
+	if len(m.codes) == 0 {
+		return "", errors.New("code pool is empty")
+	}
+	code := m.codes[len(m.codes)-1]
+	m.codes = m.codes[:len(m.codes)-1]
+	return code, nil
+}
+
+func (m *MockCodePoolStore) PoolSize(ctx context.Context) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	return int64(len(m.codes)), nil
+}
+
+func (m *MockCodePoolStore) IsPooled(ctx context.Context, code string) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, pooled := range m.codes {
+		if pooled == code {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MockCodePoolStore) IncrementPoolCounter(ctx context.Context, n int64) (int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.counter += n
+	return m.counter, nil
+}
+
+// MockTombstoneStore is an in-memory implementation of services.TombstoneStore
+type MockTombstoneStore struct {
+	sync.Mutex
+	tombstones map[string]string
+	ttls       map[string]time.Duration
+}
+
+func NewMockTombstoneStore() *MockTombstoneStore {
+	return &MockTombstoneStore{
+		tombstones: make(map[string]string),
+		ttls:       make(map[string]time.Duration),
+	}
+}
+
+func (m *MockTombstoneStore) StoreTombstone(ctx context.Context, shortCode, originalURL string, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	m.tombstones[shortCode] = originalURL
+	m.ttls[shortCode] = ttl
+	return nil
+}
+
+func (m *MockTombstoneStore) GetTombstone(ctx context.Context, shortCode string) (string, bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	originalURL, found := m.tombstones[shortCode]
+	return originalURL, found, nil
+}
+
+func (m *MockTombstoneStore) DeleteTombstone(ctx context.Context, shortCode string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.tombstones, shortCode)
+	delete(m.ttls, shortCode)
+	return nil
+}
+
+// StubResolver resolves hosts from a fixed table and fails for unknown hosts
+type StubResolver map[string][]string
+
+func (r StubResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
+
+// MockThreatStore is an in-memory implementation of services.ThreatFlagStore
+// and services.LinkIterator
+type MockThreatStore struct {
+	sync.Mutex
+	links map[string]string
+	flags map[string]string
+}
+
+func NewMockThreatStore() *MockThreatStore {
+	return &MockThreatStore{
+		links: make(map[string]string),
+		flags: make(map[string]string),
+	}
+}
+
+func (m *MockThreatStore) FlagURL(ctx context.Context, shortCode, reason string) error {
+	m.Lock()
+	defer m.Unlock()
+	m.flags[shortCode] = reason
+	return nil
+}
+
+func (m *MockThreatStore) GetFlag(ctx context.Context, shortCode string) (string, bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	reason, flagged := m.flags[shortCode]
+	return reason, flagged, nil
+}
+
+func (m *MockThreatStore) ClearFlag(ctx context.Context, shortCode string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.flags, shortCode)
+	return nil
+}
+
+func (m *MockThreatStore) ForEachURL(ctx context.Context, fn func(shortCode, originalURL string) error) error {
+	m.Lock()
+	links := make(map[string]string, len(m.links))
+	for code, originalURL := range m.links {
+		links[code] = originalURL
+	}
+	m.Unlock()
+
+	for code, originalURL := range links {
+		if err := fn(code, originalURL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MockMetadataStore is an in-memory implementation of services.MetadataStore
+type MockMetadataStore struct {
+	sync.Mutex
+	metadata map[string][]byte
+}
+
+func NewMockMetadataStore() *MockMetadataStore {
+	return &MockMetadataStore{metadata: make(map[string][]byte)}
+}
+
+func (m *MockMetadataStore) StoreMetadata(ctx context.Context, shortCode string, metadata []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	m.metadata[shortCode] = metadata
+	return nil
+}
+
+func (m *MockMetadataStore) GetMetadata(ctx context.Context, shortCode string) ([]byte, bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	metadata, found := m.metadata[shortCode]
+	return metadata, found, nil
+}
+
+func (m *MockMetadataStore) DeleteMetadata(ctx context.Context, shortCode string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.metadata, shortCode)
+	return nil
+}
+
+// MockAICacheStore is an in-memory implementation of services.AICacheStore
+type MockAICacheStore struct {
+	sync.Mutex
+	entries map[string][]byte
+	ttls    map[string]time.Duration
+}
+
+func NewMockAICacheStore() *MockAICacheStore {
+	return &MockAICacheStore{
+		entries: make(map[string][]byte),
+		ttls:    make(map[string]time.Duration),
+	}
+}
+
+func (m *MockAICacheStore) StoreAICache(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	m.entries[key] = value
+	m.ttls[key] = ttl
+	return nil
+}
+
+func (m *MockAICacheStore) GetAICache(ctx context.Context, key string) ([]byte, bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	value, found := m.entries[key]
+	return value, found, nil
+}
+
+func (m *MockAICacheStore) DeleteAICache(ctx context.Context, key string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.entries, key)
+	delete(m.ttls, key)
+	return nil
+}
+
+// MockAIUsageStore is an in-memory implementation of services.AIUsageStore
+type MockAIUsageStore struct {
+	sync.Mutex
+	days map[string]map[string]int64
+}
+
+func NewMockAIUsageStore() *MockAIUsageStore {
+	return &MockAIUsageStore{days: make(map[string]map[string]int64)}
+}
+
+func (m *MockAIUsageStore) AddAIUsage(ctx context.Context, day, tenant string, promptTokens, completionTokens int64) error {
+	m.Lock()
+	defer m.Unlock()
+	if m.days[day] == nil {
+		m.days[day] = make(map[string]int64)
+	}
+	m.days[day][tenant+":prompt"] += promptTokens
+	m.days[day][tenant+":completion"] += completionTokens
+	return nil
+}
+
+func (m *MockAIUsageStore) ReserveAIUsage(ctx context.Context, day, tenant string, tokens int64) (map[string]int64, error) {
+	m.Lock()
+	if m.days[day] == nil {
+		m.days[day] = make(map[string]int64)
+	}
+	m.days[day][tenant+":reserved"] += tokens
+	m.Unlock()
+	return m.GetAIUsage(ctx, day)
+}
+
+func (m *MockAIUsageStore) GetAIUsage(ctx context.Context, day string) (map[string]int64, error) {
+	m.Lock()
+	defer m.Unlock()
+	counters := make(map[string]int64)
+	for field, count := range m.days[day] {
+		counters[field] = count
+	}
+	return counters, nil
+}
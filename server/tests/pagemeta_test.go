@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPage = `<!DOCTYPE html>
+<html><head>
+<title>
+  Product 12345 | Example Shop
+</title>
+<meta property="og:title" content="Ergonomic Desk Chair">
+<meta name="description" content="A chair   for long working days.">
+</head>
+<body><title>Not the title</title></body></html>`
+
+func newTestPageSite(t *testing.T, robots string) (*httptest.Server, *int32) {
+	t.Helper()
+	var pageHits int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		if robots == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(robots))
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pageHits, 1)
+		assert.Equal(t, services.DefaultPageUserAgent, r.UserAgent())
+		if strings.HasSuffix(r.URL.Path, ".pdf") {
+			w.Header().Set("Content-Type", "application/pdf")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(testPage))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &pageHits
+}
+
+func newTestPageFetcher(server *httptest.Server) *services.PageFetcher {
+	return services.NewPageFetcher(services.PageFetcherConfig{Client: server.Client()})
+}
+
+func TestPageFetcher_Fetch(t *testing.T) {
+	server, _ := newTestPageSite(t, "")
+
+	page, err := newTestPageFetcher(server).Fetch(context.Background(), server.URL+"/p/12345")
+
+	require.NoError(t, err)
+	assert.Equal(t, "Product 12345 | Example Shop", page.Title)
+	assert.Equal(t, "Ergonomic Desk Chair", page.OGTitle)
+	assert.Equal(t, "A chair for long working days.", page.Description)
+}
+
+func TestPageFetcher_SkipsNonHTML(t *testing.T) {
+	server, _ := newTestPageSite(t, "")
+
+	_, err := newTestPageFetcher(server).Fetch(context.Background(), server.URL+"/manual.pdf")
+
+	assert.Error(t, err)
+}
+
+func TestPageFetcher_SizeCap(t *testing.T) {
+	server, _ := newTestPageSite(t, "")
+	fetcher := services.NewPageFetcher(services.PageFetcherConfig{Client: server.Client(), MaxBytes: 30})
+
+	page, err := fetcher.Fetch(context.Background(), server.URL+"/p/12345")
+
+	require.NoError(t, err)
+	assert.Empty(t, page.OGTitle, "Tags past the size cap are not read")
+}
+
+func TestPageFetcher_RespectsRobots(t *testing.T) {
+	tests := []struct {
+		name    string
+		robots  string
+		path    string
+		allowed bool
+	}{
+		{"No robots.txt", "", "/p/1", true},
+		{"Disallowed for everyone", "User-agent: *\nDisallow: /p/", "/p/1", false},
+		{"Other paths allowed", "User-agent: *\nDisallow: /p/", "/about", true},
+		{"Own group wins over wildcard", "User-agent: *\nDisallow: /\n\nUser-agent: go-url-shortner-preview\nAllow: /", "/p/1", true},
+		{"Own group disallows", "User-agent: go-url-shortner-preview\nDisallow: /p/\n\nUser-agent: *\nAllow: /", "/p/1", false},
+		{"Longest rule wins", "User-agent: *\nDisallow: /p/\nAllow: /p/public", "/p/public/1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, hits := newTestPageSite(t, tt.robots)
+
+			_, err := newTestPageFetcher(server).Fetch(context.Background(), server.URL+tt.path)
+
+			if tt.allowed {
+				assert.NoError(t, err)
+				assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+			} else {
+				assert.Error(t, err)
+				assert.Equal(t, int32(0), atomic.LoadInt32(hits), "Disallowed pages are not requested")
+			}
+		})
+	}
+}
+
+func TestAISlugService_WithPageMetadata(t *testing.T) {
+	site, _ := newTestPageSite(t, "")
+	fake := NewFakeLLMServer(t, "chair")
+	provider, err := services.NewLLMProvider(services.LLMConfig{BaseURL: fake.URL})
+	require.NoError(t, err)
+	service := services.NewAISlugServiceWithProvider(provider, services.WithPageMetadata(newTestPageFetcher(site)))
+
+	slug, err := service.GenerateSlug(context.Background(), site.URL+"/p/12345")
+
+	require.NoError(t, err)
+	assert.Equal(t, "chair", slug)
+	prompt := fake.LastRequest()["messages"].([]interface{})[0].(map[string]interface{})["content"].(string)
+	assert.Contains(t, prompt, "Page title: Ergonomic Desk Chair")
+	assert.Contains(t, prompt, "Page description: A chair for long working days.")
+}
+
+func TestAISlugService_PageMetadataUnavailable(t *testing.T) {
+	site, _ := newTestPageSite(t, "User-agent: *\nDisallow: /")
+	fake := NewFakeLLMServer(t, "shop")
+	provider, err := services.NewLLMProvider(services.LLMConfig{BaseURL: fake.URL})
+	require.NoError(t, err)
+	service := services.NewAISlugServiceWithProvider(provider, services.WithPageMetadata(newTestPageFetcher(site)))
+
+	slug, err := service.GenerateSlug(context.Background(), site.URL+"/p/12345")
+
+	require.NoError(t, err, "Slugs are still generated from the URL")
+	assert.Equal(t, "shop", slug)
+	prompt := fake.LastRequest()["messages"].([]interface{})[0].(map[string]interface{})["content"].(string)
+	assert.NotContains(t, prompt, "Page title")
+}
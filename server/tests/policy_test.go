@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticRuleSource serves a fixed rule set
+type staticRuleSource string
+
+func (s staticRuleSource) LoadRules(ctx context.Context) ([]byte, error) {
+	return []byte(s), nil
+}
+
+const testPolicyRules = `{
+	"rules": [
+		{"id": "partner-allow", "action": "allow", "type": "domain", "pattern": "partner.competitor.com"},
+		{"id": "competitor", "action": "deny", "type": "domain", "pattern": ".competitor.com"},
+		{"id": "free-hosts", "action": "deny", "type": "domain", "pattern": "*.freehost.net"},
+		{"id": "bad-page", "action": "deny", "type": "url", "pattern": "https://example.com/scam"},
+		{"id": "php-shells", "action": "deny", "type": "path_regex", "pattern": "^/wp-admin/.*\\.php$"},
+		{"id": "own-domains", "action": "allow", "type": "domain", "pattern": ".ourcompany.com", "tenants": ["tenant-a"]}
+	],
+	"allowlist_tenants": ["tenant-a"]
+}`
+
+func newTestPolicyEngine(t *testing.T, rules string) *services.PolicyEngine {
+	engine := services.NewPolicyEngine(staticRuleSource(rules))
+	require.NoError(t, engine.Reload(context.Background()))
+	return engine
+}
+
+func checkPolicy(engine *services.PolicyEngine, tenant, rawURL string) error {
+	u, _ := url.Parse(rawURL)
+	return engine.Check(tenant, u)
+}
+
+func TestPolicyEngine_Rules(t *testing.T) {
+	engine := newTestPolicyEngine(t, testPolicyRules)
+
+	denied := []string{
+		"https://competitor.com",
+		"https://www.competitor.com/pricing",
+		"https://phish.freehost.net/login",
+		"https://example.com/scam",
+		"https://blog.example.org/wp-admin/shell.php",
+	}
+	for _, rawURL := range denied {
+		err := checkPolicy(engine, "", rawURL)
+		var validationErr *services.ValidationError
+		if assert.True(t, errors.As(err, &validationErr), "%s should be denied", rawURL) {
+			assert.Equal(t, services.CodePolicyDenied, validationErr.Code)
+		}
+	}
+
+	allowed := []string{
+		"https://partner.competitor.com/offer",
+		"https://freehost.net",
+		"https://example.com/scam/other",
+		"https://blog.example.org/wp-admin/",
+	}
+	for _, rawURL := range allowed {
+		assert.NoError(t, checkPolicy(engine, "", rawURL), "%s should be allowed", rawURL)
+	}
+}
+
+func TestPolicyEngine_TenantAllowlist(t *testing.T) {
+	engine := newTestPolicyEngine(t, testPolicyRules)
+
+	assert.NoError(t, checkPolicy(engine, "tenant-a", "https://docs.ourcompany.com/guide"))
+	assert.Error(t, checkPolicy(engine, "tenant-a", "https://example.com"), "Allowlisted tenants may only link to allowed domains")
+	assert.NoError(t, checkPolicy(engine, "tenant-b", "https://example.com"))
+}
+
+func TestPolicyEngine_InvalidRulesKeepPrevious(t *testing.T) {
+	dir := t.TempDir()
+	rulesPath := filepath.Join(dir, "rules.json")
+	require.NoError(t, os.WriteFile(rulesPath, []byte(`{"rules":[{"action":"deny","type":"domain","pattern":"bad.com"}]}`), 0o644))
+
+	engine := services.NewPolicyEngine(services.FileRuleSource{Path: rulesPath})
+	require.NoError(t, engine.Reload(context.Background()))
+	assert.Error(t, checkPolicy(engine, "", "https://bad.com"))
+
+	// Broken regex is rejected and the previous rules stay active
+	require.NoError(t, os.WriteFile(rulesPath, []byte(`{"rules":[{"action":"deny","type":"path_regex","pattern":"("}]}`), 0o644))
+	assert.Error(t, engine.Reload(context.Background()))
+	assert.Error(t, checkPolicy(engine, "", "https://bad.com"))
+
+	// Hot reload picks up new rules
+	require.NoError(t, os.WriteFile(rulesPath, []byte(`{"rules":[{"action":"deny","type":"domain","pattern":"worse.com"}]}`), 0o644))
+	require.NoError(t, engine.Reload(context.Background()))
+	assert.NoError(t, checkPolicy(engine, "", "https://bad.com"))
+	assert.Error(t, checkPolicy(engine, "", "https://worse.com"))
+}
+
+func TestURLValidator_PolicyEngineUsesTenant(t *testing.T) {
+	validator := services.NewURLValidator(services.WithPolicyEngine(newTestPolicyEngine(t, testPolicyRules)))
+
+	ctx := services.ContextWithTenant(context.Background(), "tenant-a")
+	assert.Error(t, validator.ValidateURLContext(ctx, "https://example.com"))
+	assert.NoError(t, validator.ValidateURLContext(ctx, "docs.ourcompany.com"))
+	assert.NoError(t, validator.ValidateURL("https://example.com"))
+	assert.Error(t, validator.ValidateURL("https://www.competitor.com"))
+}
+
+func TestURLValidator_PolicyChecksCanonicalURL(t *testing.T) {
+	validator := services.NewURLValidator(services.WithPolicyEngine(newTestPolicyEngine(t, testPolicyRules)))
+
+	assert.Error(t, validator.ValidateURL("https://example.com/docs/../scam"))
+	assert.Error(t, validator.ValidateURL("HTTPS://EXAMPLE.COM:443/%73cam"))
+	assert.Error(t, validator.ValidateURL("https://WWW.Competitor.com"))
+	assert.Error(t, validator.ValidateURL("https://example.com/wp-admin/./x.php"))
+}
+
+func TestPolicyEngine_URLRulePatternsAreCanonical(t *testing.T) {
+	engine := newTestPolicyEngine(t, `{"rules": [
+		{"id": "promo", "action": "deny", "type": "url", "pattern": "HTTPS://Shop.Example.com:443/sale?utm_source=mail&b=2&a=1"}
+	]}`)
+
+	for _, rawURL := range []string{
+		"https://shop.example.com/sale?a=1&b=2",
+		"https://shop.example.com/sale?b=2&a=1&utm_campaign=x&fbclid=abc",
+	} {
+		assert.Error(t, checkPolicy(engine, "", rawURL), rawURL)
+	}
+	assert.NoError(t, checkPolicy(engine, "", "https://shop.example.com/sale?a=1"))
+}
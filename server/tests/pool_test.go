@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestPool(store *MockCodePoolStore, storage *MockRedisStorage, mode string) *services.CodePool {
+	return services.NewCodePool(store, storage, services.CodePoolConfig{
+		LowWatermark: 5,
+		TargetSize:   10,
+		CodeLength:   7,
+		Mode:         mode,
+	})
+}
+
+func TestCodePool_RefillRandom(t *testing.T) {
+	store := &MockCodePoolStore{}
+	mockStorage := NewMockRedisStorage()
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", assert.AnError)
+
+	pool := newTestPool(store, mockStorage, services.PoolModeRandom)
+
+	added, err := pool.Refill(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 10, added)
+
+	size, _ := store.PoolSize(context.Background())
+	assert.Equal(t, int64(10), size)
+	for _, code := range store.codes {
+		assert.Len(t, code, 7)
+		assert.Regexp(t, `^[0-9A-Za-z]+$`, code)
+	}
+
+	// Above the low watermark nothing is added
+	added, err = pool.Refill(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, added)
+}
+
+func TestCodePool_RefillCounter(t *testing.T) {
+	store := &MockCodePoolStore{}
+	mockStorage := NewMockRedisStorage()
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", assert.AnError)
+
+	pool := services.NewCodePool(store, mockStorage, services.CodePoolConfig{
+		LowWatermark: 2,
+		TargetSize:   3,
+		CodeLength:   4,
+		Mode:         services.PoolModeCounter,
+		Lowercase:    true,
+	})
+
+	added, err := pool.Refill(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, added)
+	assert.Equal(t, []string{"1001", "1002", "1003"}, store.codes)
+}
+
+func TestCodePool_RefillCounterLongCodes(t *testing.T) {
+	store := &MockCodePoolStore{}
+	mockStorage := NewMockRedisStorage()
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", assert.AnError)
+
+	pool := services.NewCodePool(store, mockStorage, services.CodePoolConfig{
+		LowWatermark: 1,
+		TargetSize:   1,
+		CodeLength:   11,
+		Mode:         services.PoolModeCounter,
+	})
+
+	_, err := pool.Refill(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10000000001"}, store.codes, "The offset is exact, not rounded through float64")
+}
+
+func TestCodePoolConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		config services.CodePoolConfig
+		valid  bool
+	}{
+		{services.CodePoolConfig{Mode: services.PoolModeRandom, CodeLength: 7}, true},
+		{services.CodePoolConfig{Mode: services.PoolModeCounter, CodeLength: 11}, true},
+		{services.CodePoolConfig{Mode: services.PoolModeCounter, CodeLength: 12}, false},
+		{services.CodePoolConfig{Mode: services.PoolModeCounter, CodeLength: 13, Lowercase: true}, true},
+		{services.CodePoolConfig{Mode: services.PoolModeCounter, CodeLength: 14, Lowercase: true}, false},
+		{services.CodePoolConfig{Mode: services.PoolModeRandom, CodeLength: 33}, false},
+		{services.CodePoolConfig{Mode: "sequential", CodeLength: 7}, false},
+	}
+
+	for _, tc := range testCases {
+		err := tc.config.Validate()
+		assert.Equal(t, tc.valid, err == nil, "%+v: %v", tc.config, err)
+	}
+}
+
+func TestCodePool_RefillSkipsTakenCodes(t *testing.T) {
+	store := &MockCodePoolStore{}
+	mockStorage := NewMockRedisStorage()
+	mockStorage.On("GetURL", mock.Anything, "1002").Return("https://taken.com", nil)
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", assert.AnError)
+
+	pool := services.NewCodePool(store, mockStorage, services.CodePoolConfig{
+		LowWatermark: 3,
+		TargetSize:   3,
+		CodeLength:   4,
+		Mode:         services.PoolModeCounter,
+		Lowercase:    true,
+	})
+
+	added, err := pool.Refill(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, added)
+	assert.NotContains(t, store.codes, "1002")
+}
+
+func TestCodePool_Take(t *testing.T) {
+	store := &MockCodePoolStore{codes: []string{"pooled1"}}
+	pool := newTestPool(store, NewMockRedisStorage(), services.PoolModeRandom)
+
+	code, ok := pool.Take(context.Background())
+	assert.True(t, ok)
+	assert.Equal(t, "pooled1", code)
+
+	_, ok = pool.Take(context.Background())
+	assert.False(t, ok, "Empty pool should report no code")
+}
+
+func TestURLService_CreateShortURL_FromPool(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	store := &MockCodePoolStore{codes: []string{"Xy7pQ2a"}}
+	pool := newTestPool(store, mockStorage, services.PoolModeRandom)
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithCodePool(pool))
+
+	req := services.URLRequest{URL: "https://example.com"}
+	mockStorage.On("StoreURL", mock.Anything, "Xy7pQ2a", req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Xy7pQ2a", response.ShortCode)
+	assert.Equal(t, "pooled", response.SlugType)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestURLService_CreateShortURL_EmptyPoolFallsBackToHash(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	pool := newTestPool(&MockCodePoolStore{}, mockStorage, services.PoolModeRandom)
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithCodePool(pool))
+
+	req := services.URLRequest{URL: "https://example.com"}
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hash_based", response.SlugType)
+}
+
+func TestURLService_CreateShortURL_PooledCodeIsReserved(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	pool := newTestPool(&MockCodePoolStore{codes: []string{"promo"}}, mockStorage, services.PoolModeRandom)
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithCodePool(pool))
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://example.com", Alias: "promo"})
+
+	assert.ErrorIs(t, err, services.ErrAliasTaken)
+	assert.Nil(t, response)
+}
+
+func TestURLService_CreateShortURL_PoolSkipsRetiredCodes(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	tombstones := NewMockTombstoneStore()
+	tombstones.tombstones["retired1"] = "https://old-owner.com"
+	store := &MockCodePoolStore{codes: []string{"retired1", "Xy7pQ2a"}}
+	pool := newTestPool(store, mockStorage, services.PoolModeRandom)
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080",
+		services.WithCodePool(pool), services.WithTombstones(tombstones, testLinkTTL, testQuarantine))
+
+	req := services.URLRequest{URL: "https://example.com"}
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, "retired1", response.ShortCode, "A quarantined code must not be handed out")
+	assert.Equal(t, "pooled", response.SlugType)
+	assert.Equal(t, "https://old-owner.com", tombstones.tombstones["retired1"])
+}
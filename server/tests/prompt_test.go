@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lastPrompt(fake *FakeLLMServer) string {
+	return fake.LastRequest()["messages"].([]interface{})[0].(map[string]interface{})["content"].(string)
+}
+
+func newPromptAI(t *testing.T, fake *FakeLLMServer, opts ...services.AISlugOption) *services.AISlugService {
+	t.Helper()
+	provider, err := services.NewLLMProvider(services.LLMConfig{BaseURL: fake.URL})
+	require.NoError(t, err)
+	return services.NewAISlugServiceWithProvider(provider, opts...)
+}
+
+func writeTemplate(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "prompt.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte(text), 0o600))
+	return path
+}
+
+func TestSlugPrompt_DefaultTemplate(t *testing.T) {
+	fake := NewFakeLLMServer(t, "godev")
+
+	_, err := newPromptAI(t, fake).GenerateSlug(context.Background(), "https://go.dev/doc")
+	require.NoError(t, err)
+
+	prompt := lastPrompt(fake)
+	assert.Contains(t, prompt, "URL: https://go.dev/doc")
+	assert.Contains(t, prompt, "Domain: go.dev")
+	assert.Contains(t, prompt, fmt.Sprintf("%d to %d characters", services.MinSlugLength, services.MaxSlugLength))
+	assert.Contains(t, prompt, "Only return the slug itself")
+	assert.NotContains(t, prompt, "locale code", "English slugs need no language line")
+}
+
+func TestSlugPrompt_TenantTemplatesAndLocales(t *testing.T) {
+	prompts, err := services.LoadSlugPrompts(services.SlugPromptConfig{
+		TemplateFile:        writeTemplate(t, "Default slug for {{.URL}} in {{.Locale}}"),
+		TenantTemplateFiles: map[string]string{"team-a": writeTemplate(t, "Team slug for {{.Domain}}, {{.MinLength}}-{{.MaxLength}} chars, {{.Locale}}")},
+		TenantLocales:       map[string]string{"team-a": "de", "team-b": "ja"},
+	})
+	require.NoError(t, err)
+	fake := NewFakeLLMServer(t, "slug")
+	service := newPromptAI(t, fake, services.WithSlugPrompts(prompts))
+
+	testCases := []struct {
+		tenant string
+		prompt string
+	}{
+		{"", "Default slug for https://go.dev in en"},
+		{"team-a", "Team slug for go.dev, 3-8 chars, de"},
+		{"team-b", "Default slug for https://go.dev in ja"},
+	}
+	for _, tc := range testCases {
+		ctx := services.ContextWithTenant(context.Background(), tc.tenant)
+		_, err := service.GenerateSlug(ctx, "https://go.dev")
+		require.NoError(t, err)
+		assert.Equal(t, tc.prompt, lastPrompt(fake), "tenant %q", tc.tenant)
+	}
+}
+
+func TestSlugPrompt_NonEnglishLocale(t *testing.T) {
+	prompts, err := services.LoadSlugPrompts(services.SlugPromptConfig{Locale: "ja"})
+	require.NoError(t, err)
+
+	fake := NewFakeLLMServer(t, "slug")
+	_, err = newPromptAI(t, fake, services.WithSlugPrompts(prompts)).GenerateSlug(context.Background(), "https://go.dev")
+	require.NoError(t, err)
+	assert.Contains(t, lastPrompt(fake), `locale code "ja", written in Latin letters`)
+
+	fake = NewFakeLLMServer(t, "すしや")
+	slug, err := newPromptAI(t, fake, services.WithSlugPrompts(prompts), services.WithUnicodeSlugOutput(true)).
+		GenerateSlug(context.Background(), "https://sushi.example.jp")
+	require.NoError(t, err)
+	assert.Contains(t, lastPrompt(fake), `locale code "ja"`)
+	assert.NotContains(t, lastPrompt(fake), "Latin letters")
+	assert.Equal(t, "すしや", slug)
+}
+
+func TestParseSlugPrompt_Invalid(t *testing.T) {
+	_, err := services.ParseSlugPrompt("Slug for {{.URL")
+	assert.Error(t, err)
+
+	_, err = services.ParseSlugPrompt("Slug for {{.Link}}")
+	assert.Error(t, err, "Unknown fields are caught when the template is loaded")
+
+	_, err = services.LoadSlugPrompts(services.SlugPromptConfig{TemplateFile: filepath.Join(t.TempDir(), "missing.tmpl")})
+	assert.Error(t, err)
+
+	_, err = services.ParseSlugPrompt(services.DefaultSlugPromptTemplate)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,233 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-url-shortner/handlers"
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/chain/", func(w http.ResponseWriter, r *http.Request) {
+		// /chain/5 redirects to /chain/4 and so on until /ok
+		hops, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/chain/"))
+		if hops <= 1 {
+			http.Redirect(w, r, "/ok", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/chain/"+strconv.Itoa(hops-1), http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestChecker(server *httptest.Server) *services.ReachabilityChecker {
+	return services.NewReachabilityChecker(services.ReachabilityConfig{
+		Client:       server.Client(),
+		MaxRedirects: 3,
+		MaxBodyBytes: 1024,
+	})
+}
+
+func TestReachabilityChecker_Check(t *testing.T) {
+	server := newTestSite(t)
+	checker := newTestChecker(server)
+
+	tests := []struct {
+		name        string
+		path        string
+		reachable   bool
+		status      int
+		finalPath   string
+		contentType string
+	}{
+		{"Reachable", "/ok", true, http.StatusOK, "/ok", "text/html; charset=utf-8"},
+		{"HEAD not allowed", "/get-only", true, http.StatusOK, "/get-only", "application/pdf"},
+		{"Redirect followed", "/moved", true, http.StatusOK, "/ok", "text/html; charset=utf-8"},
+		{"Not found", "/typo", false, http.StatusNotFound, "/typo", "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := checker.Check(context.Background(), server.URL+tt.path)
+
+			require.NotNil(t, result)
+			assert.Equal(t, tt.reachable, result.Reachable)
+			assert.Equal(t, tt.status, result.StatusCode)
+			assert.Equal(t, server.URL+tt.finalPath, result.FinalURL)
+			assert.Equal(t, tt.contentType, result.ContentType)
+		})
+	}
+}
+
+func TestReachabilityChecker_RedirectCap(t *testing.T) {
+	server := newTestSite(t)
+
+	result := newTestChecker(server).Check(context.Background(), server.URL+"/chain/6")
+
+	assert.False(t, result.Reachable, "The end of a chain longer than the cap is unknown")
+	assert.Equal(t, http.StatusFound, result.StatusCode)
+	assert.Equal(t, server.URL+"/chain/3", result.FinalURL, "Three redirects should be followed")
+	assert.Equal(t, "too many redirects", result.Error)
+
+	result = newTestChecker(server).Check(context.Background(), server.URL+"/chain/3")
+	assert.True(t, result.Reachable, "Chains within the cap reach their target")
+}
+
+func TestReachabilityChecker_NoRedirectsFollowed(t *testing.T) {
+	server := newTestSite(t)
+	checker := services.NewReachabilityChecker(services.ReachabilityConfig{Client: server.Client(), MaxRedirects: 0})
+
+	result := checker.Check(context.Background(), server.URL+"/chain/1")
+
+	assert.False(t, result.Reachable)
+	assert.Equal(t, "too many redirects", result.Error)
+}
+
+func TestReachabilityChecker_SkipsOtherSchemes(t *testing.T) {
+	checker := services.NewReachabilityChecker(services.ReachabilityConfig{})
+
+	assert.Nil(t, checker.Check(context.Background(), "mailto:someone@example.com"))
+}
+
+func TestNewSafeHTTPClient_RefusesInternalAddresses(t *testing.T) {
+	server := newTestSite(t)
+	checker := services.NewReachabilityChecker(services.ReachabilityConfig{Client: services.NewSafeHTTPClient(time.Second)})
+
+	result := checker.Check(context.Background(), server.URL+"/ok")
+
+	assert.False(t, result.Reachable)
+	assert.Contains(t, result.Error, "not allowed")
+}
+
+func TestURLService_CreateShortURL_ReachabilityRecorded(t *testing.T) {
+	server := newTestSite(t)
+	mockStorage := NewMockRedisStorage()
+	metadata := NewMockMetadataStore()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080",
+		services.WithMetadataStore(metadata),
+		services.WithReachabilityCheck(newTestChecker(server), services.ReachabilityOn))
+
+	req := services.URLRequest{URL: server.URL + "/typo"}
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	require.NoError(t, err)
+	require.NotNil(t, response.Reachability)
+	assert.False(t, response.Reachability.Reachable)
+
+	var stored services.LinkMetadata
+	require.NoError(t, json.Unmarshal(metadata.metadata[response.ShortCode], &stored))
+	assert.Equal(t, http.StatusNotFound, stored.Reachability.StatusCode)
+	assert.Equal(t, "hash_based", stored.SlugType)
+}
+
+func TestURLService_CreateShortURL_StrictReachabilityRefusesDeadLinks(t *testing.T) {
+	server := newTestSite(t)
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080",
+		services.WithReachabilityCheck(newTestChecker(server), services.ReachabilityStrict))
+
+	_, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: server.URL + "/typo"})
+
+	assert.ErrorIs(t, err, services.ErrDestinationUnreachable)
+	mockStorage.AssertNotCalled(t, "StoreURL", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestURLService_CreateShortURL_ReachabilityOnRequest(t *testing.T) {
+	server := newTestSite(t)
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080",
+		services.WithReachabilityCheck(newTestChecker(server), services.ReachabilityRequest))
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: server.URL + "/ok"})
+	require.NoError(t, err)
+	assert.Nil(t, response.Reachability, "Requests without the flag should not be checked")
+
+	response, err = service.CreateShortURL(context.Background(), services.URLRequest{URL: server.URL + "/typo", CheckReachability: true})
+	require.NoError(t, err)
+	require.NotNil(t, response.Reachability)
+	assert.False(t, response.Reachability.Reachable)
+}
+
+func TestValidateReachabilityMode(t *testing.T) {
+	for _, mode := range []string{services.ReachabilityOff, services.ReachabilityRequest, services.ReachabilityOn, services.ReachabilityStrict} {
+		assert.NoError(t, services.ValidateReachabilityMode(mode))
+	}
+	assert.Error(t, services.ValidateReachabilityMode("yes"))
+}
+
+func TestURLService_GetLinkMetadata(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	metadata := NewMockMetadataStore()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithMetadataStore(metadata))
+
+	metadata.metadata["abc123"] = []byte(`{"short_code":"abc123","original_url":"https://old.example.com","slug_type":"custom"}`)
+	mockStorage.On("GetURL", mock.Anything, "abc123").Return("https://example.com", nil)
+	mockStorage.On("GetURL", mock.Anything, "legacy").Return("https://legacy.example.com", nil)
+	mockStorage.On("GetURL", mock.Anything, "missing").Return("", assert.AnError)
+
+	result, err := service.GetLinkMetadata(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, "custom", result.SlugType)
+	assert.Equal(t, "https://example.com", result.OriginalURL, "The live destination wins over stored metadata")
+
+	result, err = service.GetLinkMetadata(context.Background(), "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "https://legacy.example.com", result.OriginalURL)
+
+	_, err = service.GetLinkMetadata(context.Background(), "missing")
+	assert.ErrorIs(t, err, services.ErrLinkNotFound)
+}
+
+func TestGetLinkMetadata_Handler(t *testing.T) {
+	router := setupTestRouter()
+	mockService := new(MockURLService)
+	handler := handlers.NewURLHandler(mockService)
+	router.GET("/api/urls/*shortCode", handler.GetLinkMetadata)
+
+	mockService.On("GetLinkMetadata", mock.Anything, "/eng/oncall").
+		Return(&services.LinkMetadata{ShortCode: "eng/oncall", OriginalURL: "https://example.com"}, nil)
+	mockService.On("GetLinkMetadata", mock.Anything, "/missing").Return(nil, services.ErrLinkNotFound)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/urls/eng/oncall", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var metadata services.LinkMetadata
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metadata))
+	assert.Equal(t, "eng/oncall", metadata.ShortCode)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/urls/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
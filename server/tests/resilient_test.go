@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"go-url-shortner/handlers"
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// errProviderUnavailable is a provider failure worth retrying
+var errProviderUnavailable = &services.ProviderStatusError{Endpoint: "http://llm.test/api/chat", StatusCode: http.StatusServiceUnavailable}
+
+func newTestResilientAI(next services.AISlugServiceInterface, config services.ResilienceConfig) *services.ResilientAISlugService {
+	config.RetryBackoff = time.Millisecond
+	return services.NewResilientAISlugService(next, config)
+}
+
+func TestResilientAISlugService_RetriesFailures(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlug", mock.Anything, "https://go.dev").Return("", errProviderUnavailable).Once()
+	mockAI.On("GenerateSlug", mock.Anything, "https://go.dev").Return("godev", nil).Once()
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{MaxRetries: 2})
+
+	slug, err := service.GenerateSlug(context.Background(), "https://go.dev")
+
+	require.NoError(t, err)
+	assert.Equal(t, "godev", slug)
+	mockAI.AssertNumberOfCalls(t, "GenerateSlug", 2)
+}
+
+func TestResilientAISlugService_GivesUpAfterMaxRetries(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", errProviderUnavailable)
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{MaxRetries: 2})
+
+	_, err := service.GenerateSlug(context.Background(), "https://go.dev")
+
+	assert.ErrorIs(t, err, errProviderUnavailable)
+	mockAI.AssertNumberOfCalls(t, "GenerateSlug", 3)
+}
+
+func TestResilientAISlugService_DoesNotRetryDeterministicFailures(t *testing.T) {
+	for _, failure := range []error{
+		errors.New("generated slug is empty after cleaning"),
+		&services.ProviderStatusError{Endpoint: "http://llm.test/api/chat", StatusCode: http.StatusBadRequest},
+	} {
+		t.Run(failure.Error(), func(t *testing.T) {
+			mockAI := new(MockAISlugService)
+			mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", failure)
+			service := newTestResilientAI(mockAI, services.ResilienceConfig{MaxRetries: 2})
+
+			_, err := service.GenerateSlug(context.Background(), "https://go.dev")
+
+			assert.ErrorIs(t, err, failure)
+			mockAI.AssertNumberOfCalls(t, "GenerateSlug", 1)
+		})
+	}
+}
+
+func TestResilientAISlugService_RetriesTransportErrors(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	transportErr := &url.Error{Op: "Post", URL: "http://llm.test/api/chat", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", transportErr).Once()
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("godev", nil).Once()
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{MaxRetries: 1})
+
+	slug, err := service.GenerateSlug(context.Background(), "https://go.dev")
+
+	require.NoError(t, err)
+	assert.Equal(t, "godev", slug)
+}
+
+func TestResilientAISlugService_CancelledTrialKeepsCircuitHalfOpen(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", errProviderUnavailable).Once()
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{
+		FailureThreshold: 1,
+		OpenDuration:     10 * time.Millisecond,
+	})
+
+	service.GenerateSlug(context.Background(), "https://go.dev")
+	time.Sleep(20 * time.Millisecond)
+
+	// The client hangs up during the trial call
+	ctx, cancel := context.WithCancel(context.Background())
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { cancel() }).
+		Return("", context.Canceled).Once()
+	_, err := service.GenerateSlug(ctx, "https://go.dev")
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, services.CircuitHalfOpen, service.State(), "A cancelled trial should not reopen the circuit")
+
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("godev", nil).Once()
+	_, err = service.GenerateSlug(context.Background(), "https://go.dev")
+	assert.NoError(t, err, "The next call should get the trial")
+	assert.Equal(t, services.CircuitClosed, service.State())
+}
+
+func TestResilientAISlugService_TimesOutSlowCalls(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return("", context.DeadlineExceeded)
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{Timeout: 10 * time.Millisecond})
+
+	start := time.Now()
+	_, err := service.GenerateSlug(context.Background(), "https://go.dev")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestResilientAISlugService_CircuitBreaker(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", errProviderUnavailable).Times(2)
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{
+		FailureThreshold: 2,
+		OpenDuration:     20 * time.Millisecond,
+	})
+	ctx := context.Background()
+
+	assert.Equal(t, services.CircuitClosed, service.State())
+	service.GenerateSlug(ctx, "https://go.dev")
+	service.GenerateSlug(ctx, "https://go.dev")
+	assert.Equal(t, services.CircuitOpen, service.State())
+
+	_, err := service.GenerateSlug(ctx, "https://go.dev")
+	assert.ErrorIs(t, err, services.ErrCircuitOpen)
+	mockAI.AssertNumberOfCalls(t, "GenerateSlug", 2)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, services.CircuitHalfOpen, service.State())
+
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("godev", nil)
+	slug, err := service.GenerateSlug(ctx, "https://go.dev")
+	require.NoError(t, err)
+	assert.Equal(t, "godev", slug)
+	assert.Equal(t, services.CircuitClosed, service.State())
+}
+
+func TestResilientAISlugService_FailedTrialReopens(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", errProviderUnavailable)
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{
+		FailureThreshold: 1,
+		OpenDuration:     10 * time.Millisecond,
+	})
+
+	service.GenerateSlug(context.Background(), "https://go.dev")
+	time.Sleep(20 * time.Millisecond)
+	service.GenerateSlug(context.Background(), "https://go.dev")
+
+	assert.Equal(t, services.CircuitOpen, service.State())
+}
+
+func TestResilientAISlugService_CandidatesPassThrough(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlugCandidates", mock.Anything, "https://go.dev", 3).Return([]string{"godev", "golang"}, nil)
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{})
+
+	candidates, err := service.GenerateSlugCandidates(context.Background(), "https://go.dev", 3)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"godev", "golang"}, candidates)
+}
+
+func TestURLService_CreateShortURL_SkipsAIWhileCircuitOpen(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", errProviderUnavailable)
+	resilient := newTestResilientAI(mockAI, services.ResilienceConfig{FailureThreshold: 1, OpenDuration: time.Minute})
+	service := services.NewURLService(mockStorage, resilient, "localhost", "8080")
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(nil)
+
+	for _, destination := range []string{"https://go.dev", "https://golang.org"} {
+		response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: destination})
+		require.NoError(t, err)
+		assert.Equal(t, "hash_based", response.SlugType)
+	}
+	mockAI.AssertNumberOfCalls(t, "GenerateSlug", 1)
+}
+
+func TestHealthCheck_ReportsAICircuit(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	router := setupTestRouter()
+	handler := handlers.NewURLHandler(new(MockURLService),
+		handlers.WithAICircuit(newTestResilientAI(mockAI, services.ResilienceConfig{})))
+	router.GET("/health", handler.HealthCheck)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, map[string]interface{}{"circuit": "closed"}, response["ai"])
+}
+
+func TestResilientAISlugService_RetriesRateLimits(t *testing.T) {
+	rateLimited := &services.ProviderStatusError{Endpoint: "http://llm.test/api/chat", StatusCode: http.StatusTooManyRequests, RetryAfter: 20 * time.Millisecond}
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", rateLimited).Once()
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("godev", nil).Once()
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{MaxRetries: 1})
+
+	start := time.Now()
+	slug, err := service.GenerateSlug(context.Background(), "https://go.dev")
+
+	require.NoError(t, err)
+	assert.Equal(t, "godev", slug)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond, "Retry-After is honoured")
+}
+
+func TestResilientAISlugService_LongRetryAfterIsNotAwaited(t *testing.T) {
+	rateLimited := &services.ProviderStatusError{Endpoint: "http://llm.test/api/chat", StatusCode: http.StatusTooManyRequests, RetryAfter: time.Minute}
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", rateLimited)
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{MaxRetries: 2, Timeout: time.Second})
+
+	_, err := service.GenerateSlug(context.Background(), "https://go.dev")
+
+	assert.ErrorIs(t, err, rateLimited)
+	mockAI.AssertNumberOfCalls(t, "GenerateSlug", 1)
+}
+
+func TestResilientAISlugService_DeterministicFailuresKeepCircuitClosed(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", &services.ProviderStatusError{Endpoint: "http://llm.test/api/chat", StatusCode: http.StatusBadRequest})
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{FailureThreshold: 2})
+
+	for i := 0; i < 3; i++ {
+		service.GenerateSlug(context.Background(), "https://go.dev")
+	}
+
+	assert.Equal(t, services.CircuitClosed, service.State(), "Bad prompts say nothing about the provider's health")
+	mockAI.AssertNumberOfCalls(t, "GenerateSlug", 3)
+}
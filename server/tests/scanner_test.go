@@ -0,0 +1,247 @@
+package tests
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-url-shortner/handlers"
+	"go-url-shortner/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testURLhausFeed = `################################################################
+# abuse.ch URLhaus Database Dump (CSV - recent URLs only)      #
+################################################################
+#
+# id,dateadded,url,url_status,last_online,threat,tags,urlhaus_link,reporter
+"1","2024-01-01 00:00:00","http://203.0.113.7/bins/mozi.m","online","2024-01-01 00:00:00","malware_download","mozi","https://urlhaus.abuse.ch/url/1/","anonymous"
+"2","2024-01-01 00:00:00","https://files.example.net/invoice.zip","offline","","malware_download","zip","https://urlhaus.abuse.ch/url/2/","anonymous"
+`
+
+func writeFeed(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func hashPrefix(expression string) string {
+	sum := sha256.Sum256([]byte(expression))
+	return hex.EncodeToString(sum[:4])
+}
+
+func newTestFeedScanner(t *testing.T) *services.FeedScanner {
+	t.Helper()
+	scanner, err := services.NewFeedScanner(services.FeedConfig{
+		HostsFiles:      []string{writeFeed(t, "hosts", "# blocklist\n0.0.0.0 phish.example.com\n127.0.0.1 localhost\nmalware.example.org # trailing comment\n")},
+		URLhausFiles:    []string{writeFeed(t, "urlhaus.csv", testURLhausFeed)},
+		HashPrefixFiles: []string{writeFeed(t, "prefixes", hashPrefix("evil.example/login/")+"\n")},
+	})
+	require.NoError(t, err)
+	return scanner
+}
+
+func TestFeedScanner_Scan(t *testing.T) {
+	scanner := newTestFeedScanner(t)
+
+	tests := []struct {
+		name    string
+		url     string
+		flagged bool
+	}{
+		{"Hosts file entry", "https://phish.example.com/account", true},
+		{"Bare hosts entry", "https://MALWARE.example.org/", true},
+		{"Sinkhole localhost ignored", "http://localhost/", false},
+		{"URLhaus URL", "http://203.0.113.7/bins/mozi.m", true},
+		{"URLhaus URL with trailing slash", "https://files.example.net/invoice.zip/", true},
+		{"URLhaus host other path", "https://files.example.net/readme.txt", false},
+		{"Hash prefix path", "https://evil.example/login/index.html?next=1", true},
+		{"Hash prefix subdomain", "https://www.evil.example/login/", true},
+		{"Hash prefix other path", "https://evil.example/about", false},
+		{"Clean URL", "https://example.com/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := scanner.Scan(context.Background(), tt.url)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.flagged, result.Flagged)
+		})
+	}
+}
+
+func TestFeedScanner_InvalidHashPrefix(t *testing.T) {
+	_, err := services.NewFeedScanner(services.FeedConfig{
+		HashPrefixFiles: []string{writeFeed(t, "prefixes", "not-hex\n")},
+	})
+
+	assert.Error(t, err)
+}
+
+func TestFeedScanner_ReloadKeepsPreviousFeedsOnError(t *testing.T) {
+	path := writeFeed(t, "hosts", "phish.example.com\n")
+	scanner, err := services.NewFeedScanner(services.FeedConfig{HostsFiles: []string{path}})
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, scanner.Reload())
+
+	result, err := scanner.Scan(context.Background(), "https://phish.example.com/")
+	assert.NoError(t, err)
+	assert.True(t, result.Flagged)
+}
+
+func TestURLService_CreateShortURL_BlocksListedDestination(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	flags := NewMockThreatStore()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080",
+		services.WithThreatScanner(newTestFeedScanner(t), flags, services.ThreatActionBlock))
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://phish.example.com/account"})
+
+	assert.ErrorIs(t, err, services.ErrThreatDetected)
+	assert.Nil(t, response)
+	mockStorage.AssertNotCalled(t, "StoreURL", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestURLService_CreateShortURL_FlagsListedDestination(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	flags := NewMockThreatStore()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080",
+		services.WithThreatScanner(newTestFeedScanner(t), flags, services.ThreatActionWarn))
+
+	req := services.URLRequest{URL: "https://phish.example.com/account"}
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.Len(t, response.Warnings, 1)
+	assert.Contains(t, flags.flags, response.ShortCode)
+
+	mockStorage.On("GetURL", mock.Anything, response.ShortCode).Return(req.URL, nil)
+	_, err = service.GetOriginalURL(context.Background(), response.ShortCode)
+
+	var flagged *services.FlaggedLinkError
+	assert.ErrorAs(t, err, &flagged)
+	assert.Equal(t, req.URL, flagged.OriginalURL)
+}
+
+func TestURLService_CreateShortURL_CleanDestinationNotFlagged(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	flags := NewMockThreatStore()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080",
+		services.WithThreatScanner(newTestFeedScanner(t), flags, services.ThreatActionWarn))
+
+	req := services.URLRequest{URL: "https://example.com"}
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	require.NoError(t, err)
+	assert.Empty(t, response.Warnings)
+	assert.Empty(t, flags.flags)
+}
+
+func TestThreatRescanner_FlagsExistingLinks(t *testing.T) {
+	store := NewMockThreatStore()
+	store.links["abc123"] = "https://phish.example.com/account"
+	store.links["def456"] = "https://example.com/"
+	rescanner := services.NewThreatRescanner(newTestFeedScanner(t), store, store)
+
+	flagged, err := rescanner.Rescan(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, flagged)
+	assert.Contains(t, store.flags, "abc123")
+	assert.NotContains(t, store.flags, "def456")
+}
+
+func TestThreatRescanner_ClearsFlagsOfCleanLinks(t *testing.T) {
+	store := NewMockThreatStore()
+	store.links["def456"] = "https://example.com/"
+	store.flags["def456"] = "hosts"
+	rescanner := services.NewThreatRescanner(newTestFeedScanner(t), store, store)
+
+	flagged, err := rescanner.Rescan(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, flagged)
+	assert.NotContains(t, store.flags, "def456", "A destination no longer listed should lose its flag")
+}
+
+func TestURLService_ReusedCodeIsNotFlagged(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	flags := NewMockThreatStore()
+	tombstones := NewMockTombstoneStore()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080",
+		services.WithThreatScanner(newTestFeedScanner(t), flags, services.ThreatActionWarn),
+		services.WithTombstones(tombstones, testLinkTTL, testQuarantine))
+
+	flagged := services.URLRequest{URL: "https://phish.example.com/account", Alias: "promo"}
+	mockStorage.On("GetURL", mock.Anything, "promo").Return("", assert.AnError).Once()
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "promo", flagged.URL).Return(true, nil)
+	_, err := service.CreateShortURL(context.Background(), flagged)
+	require.NoError(t, err)
+	require.Contains(t, flags.flags, "promo")
+
+	mockStorage.On("GetURL", mock.Anything, "promo").Return(flagged.URL, nil).Once()
+	mockStorage.On("DeleteURL", mock.Anything, "promo").Return(nil)
+	require.NoError(t, service.DeleteURL(context.Background(), "promo"))
+	require.NoError(t, service.ReleaseCode(context.Background(), "promo"))
+	assert.NotContains(t, flags.flags, "promo")
+
+	clean := services.URLRequest{URL: "https://example.com", Alias: "promo"}
+	mockStorage.On("GetURL", mock.Anything, "promo").Return("", assert.AnError).Once()
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "promo", clean.URL).Return(true, nil)
+	_, err = service.CreateShortURL(context.Background(), clean)
+	require.NoError(t, err)
+
+	mockStorage.On("GetURL", mock.Anything, "promo").Return(clean.URL, nil).Once()
+	originalURL, err := service.GetOriginalURL(context.Background(), "promo")
+
+	assert.NoError(t, err, "The new destination should not inherit the old flag")
+	assert.Equal(t, clean.URL, originalURL)
+}
+
+func TestValidateThreatAction(t *testing.T) {
+	assert.NoError(t, services.ValidateThreatAction(services.ThreatActionBlock))
+	assert.NoError(t, services.ValidateThreatAction(services.ThreatActionWarn))
+	assert.Error(t, services.ValidateThreatAction("Warn"))
+	assert.Error(t, services.ValidateThreatAction(""))
+}
+
+func TestRedirectToURL_FlaggedLinkShowsInterstitial(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockURLService)
+	handler := handlers.NewURLHandler(mockService)
+
+	router := gin.New()
+	router.GET("/:shortCode", handler.RedirectToURL)
+
+	destination := `https://phish.example.com/?q="><script>`
+	mockService.On("GetOriginalURL", mock.Anything, "abc123").
+		Return("", &services.FlaggedLinkError{OriginalURL: destination, Reason: "hosts"})
+
+	req, _ := http.NewRequest("GET", "/abc123", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "Continue anyway")
+	assert.NotContains(t, w.Body.String(), "<script>")
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-url-shortner/handlers"
+	"go-url-shortner/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newSchemeValidator(t *testing.T) *services.URLValidator {
+	t.Helper()
+	policy, err := services.NewSchemePolicy([]string{"mailto", "tel", "sms", "geo", "myapp"}, []string{"myapp"})
+	require.NoError(t, err)
+	return services.NewURLValidator(services.WithSchemePolicy(policy))
+}
+
+func TestURLValidator_AllowedSchemes(t *testing.T) {
+	validator := newSchemeValidator(t)
+
+	testCases := []string{
+		"mailto:support@example.com",
+		"mailto:a@example.com,b@example.com?subject=Hello%20there",
+		"tel:+1-201-555-0123",
+		"tel:+44(20)7946.0958;ext=12",
+		"sms:+15551234567?body=hi",
+		"sms:+15551234567,+15557654321",
+		"geo:37.786971,-122.399677",
+		"geo:48.2010,16.3695,183;u=35",
+		"myapp://product/42?ref=qr",
+		"MYAPP://product/42",
+	}
+
+	for _, url := range testCases {
+		t.Run(url, func(t *testing.T) {
+			normalized, err := validator.NormalizeURL(url)
+
+			assert.NoError(t, err)
+			assert.Equal(t, url, normalized, "Non-web schemes should not be rewritten")
+		})
+	}
+}
+
+func TestURLValidator_InvalidSchemeURLs(t *testing.T) {
+	validator := newSchemeValidator(t)
+
+	testCases := []struct {
+		url  string
+		code string
+	}{
+		{"mailto:", services.CodeInvalidSchemeURL},
+		{"mailto:not-an-address", services.CodeInvalidSchemeURL},
+		{"tel:12", services.CodeInvalidSchemeURL},
+		{"tel:call-me", services.CodeInvalidSchemeURL},
+		{"sms:+15551234567,abc", services.CodeInvalidSchemeURL},
+		{"geo:91,0", services.CodeInvalidSchemeURL},
+		{"geo:0", services.CodeInvalidSchemeURL},
+		{"myapp://", services.CodeInvalidSchemeURL},
+		{"myapp://open item", services.CodeInvalidSchemeURL},
+		{"ftp://example.com", services.CodeUnsupportedScheme},
+		{"otherapp://home", services.CodeUnsupportedScheme},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			err := validator.ValidateURL(tc.url)
+
+			var validationErr *services.ValidationError
+			require.True(t, errors.As(err, &validationErr), "expected a validation error, got %v", err)
+			assert.Equal(t, tc.code, validationErr.Code)
+		})
+	}
+}
+
+func TestURLValidator_SchemesDisabledByDefault(t *testing.T) {
+	validator := services.NewURLValidator()
+
+	for _, url := range []string{"mailto:support@example.com", "tel:+12015550123", "myapp://product/42", "javascript:alert(1)"} {
+		assert.Error(t, validator.ValidateURL(url), "URL should be invalid without a scheme policy: %s", url)
+	}
+	assert.NoError(t, validator.ValidateURL("example.com:8080"))
+}
+
+func TestNewSchemePolicy_Invalid(t *testing.T) {
+	_, err := services.NewSchemePolicy([]string{"javascript"}, nil)
+	assert.Error(t, err, "Script schemes may never be allowed")
+
+	_, err = services.NewSchemePolicy([]string{"tel"}, []string{"myapp"})
+	assert.Error(t, err, "Handoff schemes must be allowed")
+
+	_, err = services.NewSchemePolicy([]string{"my app"}, nil)
+	assert.Error(t, err)
+}
+
+func TestRedirectToURL_SchemeHandoff(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	policy, err := services.NewSchemePolicy([]string{"tel", "myapp"}, []string{"myapp"})
+	require.NoError(t, err)
+
+	mockService := new(MockURLService)
+	handler := handlers.NewURLHandler(mockService, handlers.WithSchemePolicy(policy))
+
+	router := gin.New()
+	router.GET("/:shortCode", handler.RedirectToURL)
+
+	mockService.On("GetOriginalURL", mock.Anything, "app").Return("myapp://product/42", nil)
+	mockService.On("GetOriginalURL", mock.Anything, "call").Return("tel:+12015550123", nil)
+
+	req, _ := http.NewRequest("GET", "/app", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `href="myapp://product/42"`)
+
+	req, _ = http.NewRequest("GET", "/call", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "tel:+12015550123", w.Header().Get("Location"))
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"go-url-shortner/services"
@@ -29,8 +30,8 @@ func TestURLService_CreateShortURL_WithAI(t *testing.T) {
 	// Mock storage check - slug is available
 	mockStorage.On("GetURL", mock.Anything, aiSlug).Return("", assert.AnError)
 
-	// Mock storage store
-	mockStorage.On("StoreURL", mock.Anything, aiSlug, req.URL).Return(nil)
+	// Mock storage claim
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, aiSlug, req.URL).Return(true, nil)
 
 	// Execute
 	response, err := service.CreateShortURL(context.Background(), req)
@@ -159,3 +160,64 @@ func TestURLService_GetOriginalURL_NotFound(t *testing.T) {
 
 	mockStorage.AssertExpectations(t)
 }
+
+func TestURLService_CreateShortURL_CaseInsensitiveHash(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithCaseInsensitiveCodes(true))
+
+	req := services.URLRequest{URL: "https://example.com"}
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, strings.ToLower(response.ShortCode), response.ShortCode, "Generated codes should be case-folded")
+	mockStorage.AssertExpectations(t)
+}
+
+func TestURLService_CreateShortURL_CaseInsensitiveAlias(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithCaseInsensitiveCodes(true))
+
+	req := services.URLRequest{URL: "https://example.com", Alias: "SummerSale"}
+	mockStorage.On("GetURL", mock.Anything, "summersale").Return("", assert.AnError)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "summersale", req.URL).Return(true, nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "summersale", response.ShortCode)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestURLService_GetOriginalURL_CaseInsensitive(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithCaseInsensitiveCodes(true))
+
+	// Legacy mixed-case code still matches exactly
+	mockStorage.On("GetURL", mock.Anything, "4KjP4hmE2uW").Return("https://legacy.com", nil).Once()
+	result, err := service.GetOriginalURL(context.Background(), "4KjP4hmE2uW")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://legacy.com", result)
+
+	// A lowercased-by-email-client code falls back to the folded form
+	mockStorage.On("GetURL", mock.Anything, "SummerSale").Return("", assert.AnError).Once()
+	mockStorage.On("GetURL", mock.Anything, "summersale").Return("https://sale.com", nil).Once()
+	result, err = service.GetOriginalURL(context.Background(), "SummerSale")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://sale.com", result)
+
+	mockStorage.AssertExpectations(t)
+}
+
+func TestURLService_GetOriginalURL_CaseSensitiveByDefault(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080")
+
+	mockStorage.On("GetURL", mock.Anything, "SummerSale").Return("", assert.AnError)
+
+	_, err := service.GetOriginalURL(context.Background(), "SummerSale")
+
+	assert.Error(t, err)
+	mockStorage.AssertNotCalled(t, "GetURL", mock.Anything, "summersale")
+}
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"go-url-shortner/handlers"
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSSRFValidator() *services.URLValidator {
+	return services.NewURLValidator(services.WithDestinationPolicy(services.DestinationPolicy{
+		Resolver: StubResolver{
+			"example.com":       {"93.184.216.34"},
+			"10.0.0.5.nip.io":   {"10.0.0.5"},
+			"metadata.evil.com": {"169.254.169.254"},
+			"v6.evil.com":       {"2606:4700::1", "::1"},
+		},
+		InternalSuffixes: []string{"internal", ".corp.example.com"},
+	}))
+}
+
+func TestIsBlockedIP(t *testing.T) {
+	blocked := []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.100.100.200", "0.0.0.0", "::1", "fe80::1", "fd00:ec2::254",
+		"::ffff:127.0.0.1", "64:ff9b::a00:5",
+	}
+	for _, ip := range blocked {
+		assert.True(t, services.IsBlockedIP(netip.MustParseAddr(ip)), "%s should be blocked", ip)
+	}
+
+	allowed := []string{"93.184.216.34", "8.8.8.8", "2606:4700::1", "64:ff9b::808:808"}
+	for _, ip := range allowed {
+		assert.False(t, services.IsBlockedIP(netip.MustParseAddr(ip)), "%s should be allowed", ip)
+	}
+}
+
+func TestURLValidator_DestinationPolicy(t *testing.T) {
+	validator := newSSRFValidator()
+
+	testCases := []struct {
+		url  string
+		code string
+	}{
+		{"http://127.0.0.1/admin", services.CodePrivateAddress},
+		{"http://169.254.169.254/latest/meta-data", services.CodePrivateAddress},
+		{"http://0x7f.0.0.1/", services.CodePrivateAddress},
+		{"http://10.0.0.5.nip.io/", services.CodePrivateAddress},
+		{"https://metadata.evil.com/", services.CodePrivateAddress},
+		{"https://v6.evil.com/", services.CodePrivateAddress},
+		{"https://vault.internal/", services.CodeInternalDomain},
+		{"https://wiki.corp.example.com/page", services.CodeInternalDomain},
+		{"https://unknown-host.example.org/", services.CodeUnresolvableHost},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			err := validator.ValidateURL(tc.url)
+
+			var validationErr *services.ValidationError
+			if assert.True(t, errors.As(err, &validationErr), "Expected a validation error for %s", tc.url) {
+				assert.Equal(t, tc.code, validationErr.Code)
+			}
+		})
+	}
+
+	assert.NoError(t, validator.ValidateURL("https://example.com/path"))
+}
+
+func TestURLValidator_NoDestinationPolicyByDefault(t *testing.T) {
+	validator := services.NewURLValidator()
+
+	assert.NoError(t, validator.ValidateURL("http://10.0.0.5/"))
+}
+
+func TestCreateShortURL_RejectsPrivateDestination(t *testing.T) {
+	router := setupTestRouter()
+	mockService := new(MockURLService)
+	handler := handlers.NewURLHandler(mockService, handlers.WithValidator(newSSRFValidator()))
+
+	router.POST("/api/urls", handler.CreateShortURL)
+
+	jsonBody, _ := json.Marshal(services.URLRequest{URL: "http://169.254.169.254/latest/meta-data"})
+	req := httptest.NewRequest("POST", "/api/urls", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]string
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, services.CodePrivateAddress, response["code"])
+	mockService.AssertNotCalled(t, "CreateShortURL")
+}
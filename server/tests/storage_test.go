@@ -133,3 +133,14 @@ func TestStorageStats_JSONTags(t *testing.T) {
 	assert.Equal(t, int64(20), unmarshaled.ExpiredURLs)
 	assert.Equal(t, int64(2048), unmarshaled.StorageSize)
 }
+
+func TestLegacyKey_RefusesOtherKeyTypes(t *testing.T) {
+	key, ok := storage.LegacyKey("4KjP4hm")
+	assert.True(t, ok)
+	assert.Equal(t, "4KjP4hm", key)
+
+	for _, code := range []string{"tomb:4KjP4hm", "meta:4KjP4hm", "policy:rules", "pool:codes", "ns:eng", "flag:abc", "eng/oncall", ""} {
+		_, ok := storage.LegacyKey(code)
+		assert.False(t, ok, code)
+	}
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-url-shortner/handlers"
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeywordSlugs(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected []string
+	}{
+		{"https://best-books.com/good-books/book", []string{"bgood", "good", "books", "book", "best"}},
+		{"https://www.example.co.uk/p/12345/desk-chair", []string{"edesk", "desk", "chair", "example", "dce"}},
+		{"https://www.example.com/blue-lamp", []string{"bluelamp", "eblue", "blue", "lamp", "example"}},
+		{"https://github.com", []string{"github"}},
+		{"https://go.dev/index.html", nil},
+		{"https://news.bbc.com/world-news", []string{"bworld", "world", "news", "bbc", "wnb"}},
+		{"https://go.dev/doc/tutorial", []string{"gdoc", "doc", "tutorial", "dtg"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			assert.Equal(t, tt.expected, services.KeywordSlugs(tt.url, false, 5))
+		})
+	}
+}
+
+func TestURLService_SuggestSlugs(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080", services.WithAISlugCandidates(2))
+
+	destination := "https://github.com/trending"
+	mockAI.On("GenerateSlugCandidates", mock.Anything, destination, 2).Return([]string{"ghub", "trending"}, nil)
+	mockStorage.On("GetURL", mock.Anything, "ghub").Return("https://other.example.com", nil)
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", assert.AnError)
+
+	result, err := service.SuggestSlugs(context.Background(), destination)
+
+	require.NoError(t, err)
+	assert.Equal(t, destination, result.URL)
+	assert.Equal(t, services.SlugSuggestion{Slug: "ghub", Source: "ai", Available: false}, result.Suggestions[0])
+	assert.Equal(t, services.SlugSuggestion{Slug: "trending", Source: "ai", Available: true}, result.Suggestions[1])
+
+	sources := map[string]int{}
+	for _, suggestion := range result.Suggestions {
+		sources[suggestion.Source]++
+	}
+	assert.Equal(t, 2, sources["ai"])
+	assert.Equal(t, 1, sources["hash"])
+	assert.Positive(t, sources["keyword"])
+	assert.Equal(t, 1, countSlug(result.Suggestions, "trending"), "Duplicates across generators are listed once")
+
+	mockStorage.AssertNotCalled(t, "StoreURL", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestURLService_SuggestSlugs_WithoutAI(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080")
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", assert.AnError)
+
+	result, err := service.SuggestSlugs(context.Background(), "https://github.com")
+
+	require.NoError(t, err)
+	require.Len(t, result.Suggestions, 2)
+	assert.Equal(t, "github", result.Suggestions[0].Slug)
+	assert.Equal(t, "hash", result.Suggestions[1].Source)
+}
+
+func countSlug(suggestions []services.SlugSuggestion, slug string) int {
+	count := 0
+	for _, suggestion := range suggestions {
+		if suggestion.Slug == slug {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSuggestSlugs_Handler(t *testing.T) {
+	router := setupTestRouter()
+	mockService := new(MockURLService)
+	handler := handlers.NewURLHandler(mockService)
+	router.POST("/api/slugs/suggest", handler.SuggestSlugs)
+
+	suggestions := &services.SlugSuggestions{
+		URL:         "https://github.com",
+		Suggestions: []services.SlugSuggestion{{Slug: "github", Source: "keyword", Available: true}},
+	}
+	mockService.On("SuggestSlugs", mock.Anything, "https://github.com").Return(suggestions, nil)
+
+	body, _ := json.Marshal(map[string]string{"url": "github.com"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/slugs/suggest", bytes.NewBuffer(body)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response services.SlugSuggestions
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, *suggestions, response)
+}
+
+func TestSuggestSlugs_Handler_InvalidURL(t *testing.T) {
+	router := setupTestRouter()
+	mockService := new(MockURLService)
+	handler := handlers.NewURLHandler(mockService)
+	router.POST("/api/slugs/suggest", handler.SuggestSlugs)
+
+	body, _ := json.Marshal(map[string]string{"url": "not-a-url"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/slugs/suggest", bytes.NewBuffer(body)))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"url"`)
+	mockService.AssertNotCalled(t, "SuggestSlugs", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-url-shortner/handlers"
+	"go-url-shortner/middleware"
+	"go-url-shortner/services"
+	"go-url-shortner/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	testLinkTTL    = 365 * 24 * time.Hour
+	testQuarantine = 90 * 24 * time.Hour
+)
+
+func newTombstoneService(mockStorage *MockRedisStorage, tombstones *MockTombstoneStore) *services.URLService {
+	return services.NewURLService(mockStorage, nil, "localhost", "8080",
+		services.WithTombstones(tombstones, testLinkTTL, testQuarantine))
+}
+
+func TestURLService_CreateShortURL_RecordsTombstone(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	tombstones := NewMockTombstoneStore()
+	service := newTombstoneService(mockStorage, tombstones)
+
+	req := services.URLRequest{URL: "https://example.com"}
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, req.URL, tombstones.tombstones[response.ShortCode])
+	assert.Equal(t, testLinkTTL+testQuarantine, tombstones.ttls[response.ShortCode], "Tombstone should outlive the link by the quarantine period")
+}
+
+func TestURLService_CreateShortURL_RetiredAliasUnavailable(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	tombstones := NewMockTombstoneStore()
+	tombstones.tombstones["promo"] = "https://old-owner.com"
+	service := newTombstoneService(mockStorage, tombstones)
+
+	mockStorage.On("GetURL", mock.Anything, "promo").Return("", assert.AnError)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://new-owner.com", Alias: "promo"})
+
+	assert.ErrorIs(t, err, services.ErrAliasTaken)
+	assert.Nil(t, response)
+}
+
+func TestURLService_CreateShortURL_RetiredAliasSameDestination(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	tombstones := NewMockTombstoneStore()
+	tombstones.tombstones["promo"] = "https://example.com"
+	service := newTombstoneService(mockStorage, tombstones)
+
+	req := services.URLRequest{URL: "https://example.com", Alias: "promo"}
+	mockStorage.On("GetURL", mock.Anything, "promo").Return("", assert.AnError)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "promo", req.URL).Return(true, nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "promo", response.ShortCode)
+}
+
+func TestURLService_CreateShortURL_RetiredHashIsRehashed(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	tombstones := NewMockTombstoneStore()
+	req := services.URLRequest{URL: "https://example.com"}
+	retired := utils.ShortHash(req.URL)
+	tombstones.tombstones[retired] = "https://someone-else.com"
+	service := newTombstoneService(mockStorage, tombstones)
+
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), req.URL).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, retired, response.ShortCode)
+	assert.Equal(t, "hash_based", response.SlugType)
+}
+
+func TestURLService_DeleteURL_StartsQuarantine(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	tombstones := NewMockTombstoneStore()
+	service := newTombstoneService(mockStorage, tombstones)
+
+	mockStorage.On("GetURL", mock.Anything, "promo").Return("https://example.com", nil)
+	mockStorage.On("DeleteURL", mock.Anything, "promo").Return(nil)
+
+	err := service.DeleteURL(context.Background(), "promo")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", tombstones.tombstones["promo"])
+	assert.Equal(t, testQuarantine, tombstones.ttls["promo"])
+	mockStorage.AssertExpectations(t)
+}
+
+func TestURLService_DeleteURL_NotFound(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := newTombstoneService(mockStorage, NewMockTombstoneStore())
+
+	mockStorage.On("GetURL", mock.Anything, "missing").Return("", assert.AnError)
+
+	err := service.DeleteURL(context.Background(), "missing")
+
+	assert.ErrorIs(t, err, services.ErrLinkNotFound)
+	mockStorage.AssertNotCalled(t, "DeleteURL", mock.Anything, mock.Anything)
+}
+
+func TestURLService_ReleaseCode(t *testing.T) {
+	tombstones := NewMockTombstoneStore()
+	tombstones.tombstones["promo"] = "https://old-owner.com"
+	service := newTombstoneService(NewMockRedisStorage(), tombstones)
+
+	err := service.ReleaseCode(context.Background(), "promo")
+
+	assert.NoError(t, err)
+	assert.NotContains(t, tombstones.tombstones, "promo")
+}
+
+func TestURLService_ReleaseCode_NotRetired(t *testing.T) {
+	service := newTombstoneService(NewMockRedisStorage(), NewMockTombstoneStore())
+
+	err := service.ReleaseCode(context.Background(), "promo")
+
+	assert.ErrorIs(t, err, services.ErrNoTombstone)
+}
+
+func TestURLService_ReleaseCode_QuarantineDisabled(t *testing.T) {
+	service := services.NewURLService(NewMockRedisStorage(), nil, "localhost", "8080")
+
+	err := service.ReleaseCode(context.Background(), "promo")
+
+	assert.ErrorIs(t, err, services.ErrNoTombstone)
+}
+
+func TestAdminRoutes(t *testing.T) {
+	router := setupTestRouter()
+	mockService := new(MockURLService)
+	handler := handlers.NewURLHandler(mockService)
+
+	admin := router.Group("/api/admin", middleware.AdminAuthMiddleware("admin-secret", ""))
+	admin.DELETE("/urls/*shortCode", handler.DeleteURL)
+	admin.DELETE("/tombstones/*shortCode", handler.ReleaseCode)
+
+	mockService.On("DeleteURL", mock.Anything, "/eng/oncall").Return(nil)
+	mockService.On("DeleteURL", mock.Anything, "/missing").Return(services.ErrLinkNotFound)
+	mockService.On("ReleaseCode", mock.Anything, "/promo").Return(nil)
+	mockService.On("ReleaseCode", mock.Anything, "/unknown").Return(services.ErrNoTombstone)
+
+	testCases := []struct {
+		path   string
+		token  string
+		status int
+	}{
+		{"/api/admin/urls/eng/oncall", "", http.StatusUnauthorized},
+		{"/api/admin/urls/eng/oncall", "wrong", http.StatusUnauthorized},
+		{"/api/admin/urls/eng/oncall", "admin-secret", http.StatusNoContent},
+		{"/api/admin/urls/missing", "admin-secret", http.StatusNotFound},
+		{"/api/admin/tombstones/promo", "admin-secret", http.StatusNoContent},
+		{"/api/admin/tombstones/unknown", "admin-secret", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest("DELETE", tc.path, nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tc.status, w.Code, "%s with token %q", tc.path, tc.token)
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAdminAuthMiddleware_DisabledWithoutToken(t *testing.T) {
+	router := setupTestRouter()
+	router.Use(middleware.AdminAuthMiddleware("", ""))
+	router.GET("/admin", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-url-shortner/handlers"
+	"go-url-shortner/services"
+	"go-url-shortner/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNormalizeNFC(t *testing.T) {
+	// "e" followed by a combining acute accent composes into "é"
+	assert.Equal(t, "café", utils.NormalizeNFC("café"))
+	assert.Equal(t, "café", utils.NormalizeNFC("café"))
+}
+
+func TestIsMixedScript(t *testing.T) {
+	assert.False(t, utils.IsMixedScript("paypal"))
+	assert.False(t, utils.IsMixedScript("привет"))
+	assert.False(t, utils.IsMixedScript("東京タワー"))
+	assert.False(t, utils.IsMixedScript("tokyo東京"))
+	assert.True(t, utils.IsMixedScript("pаypal"), "Latin with Cyrillic а")
+	assert.True(t, utils.IsMixedScript("αβcd"), "Greek with Latin")
+}
+
+func TestIsWholeScriptConfusable(t *testing.T) {
+	assert.True(t, utils.IsWholeScriptConfusable("сор"), "Cyrillic lookalike of cop")
+	assert.False(t, utils.IsWholeScriptConfusable("привет"))
+	assert.False(t, utils.IsWholeScriptConfusable("cop"))
+	assert.Equal(t, "cop", utils.ConfusableSkeleton("сор"))
+}
+
+func TestParseAlias_Unicode(t *testing.T) {
+	valid := map[string]string{
+		"café":         "café",
+		"café":        "café",
+		"🎉":            "🎉",
+		"👩‍💻":          "👩‍💻",
+		"東京":           "東京",
+		"party/🎉":      "party/🎉",
+		"über-deals_1": "über-deals_1",
+	}
+	for input, expected := range valid {
+		t.Run(input, func(t *testing.T) {
+			code, _, err := services.ParseAlias(input, true)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, code)
+		})
+	}
+
+	invalid := []string{
+		"pаypal",     // mixed Latin/Cyrillic
+		"сор",        // whole-script confusable
+		"ｃａｆｅ",       // fullwidth letters
+		"zero​width", // invisible character
+		"a‍b",        // joiner outside an emoji sequence
+		"rtl‮evil",   // bidi override
+	}
+	for _, input := range invalid {
+		t.Run(input, func(t *testing.T) {
+			_, _, err := services.ParseAlias(input, true)
+			assert.ErrorIs(t, err, services.ErrInvalidAlias)
+		})
+	}
+
+	// Without the switch only ASCII aliases are accepted
+	_, _, err := services.ParseAlias("café", false)
+	assert.ErrorIs(t, err, services.ErrInvalidAlias)
+}
+
+func TestURLService_CreateShortURL_UnicodeAlias(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithUnicodeSlugs(true))
+
+	req := services.URLRequest{URL: "https://example.com", Alias: "café"}
+
+	mockStorage.On("GetURL", mock.Anything, "café").Return("", assert.AnError)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "café", req.URL).Return(true, nil)
+
+	response, err := service.CreateShortURL(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "café", response.ShortCode)
+	assert.Equal(t, "http://localhost:8080/caf%C3%A9", response.ShortURL)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestURLService_GetOriginalURL_UnicodeNormalization(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithUnicodeSlugs(true))
+
+	mockStorage.On("GetURL", mock.Anything, "café").Return("https://example.com", nil)
+
+	// Decomposed and double-encoded forms resolve to the stored NFC code
+	for _, code := range []string{"café", "café", "caf%C3%A9"} {
+		result, err := service.GetOriginalURL(context.Background(), code)
+		assert.NoError(t, err, code)
+		assert.Equal(t, "https://example.com", result)
+	}
+}
+
+func TestRedirectToURL_PercentEncodedUnicode(t *testing.T) {
+	router := setupTestRouter()
+	mockStorage := NewMockRedisStorage()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithUnicodeSlugs(true))
+	handler := handlers.NewURLHandler(service)
+
+	mockStorage.On("GetURL", mock.Anything, "🎉").Return("https://party.example.com", nil)
+	mockStorage.On("GetURL", mock.Anything, "fr/café").Return("https://cafe.example.com", nil)
+
+	router.GET("/:shortCode", handler.RedirectToURL)
+	router.GET("/:shortCode/*path", handler.RedirectToURL)
+
+	for path, expected := range map[string]string{
+		"/%F0%9F%8E%89":  "https://party.example.com",
+		"/fr/cafe%CC%81": "https://cafe.example.com",
+		"/fr/caf%C3%A9":  "https://cafe.example.com",
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMovedPermanently, w.Code, path)
+		assert.Equal(t, expected, w.Header().Get("Location"), path)
+	}
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeShortener serves redirects from a fixed table of paths.
+func newFakeShortener(t *testing.T, redirects map[string]string) (*httptest.Server, string) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead && r.URL.Path == "/no-head" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		location, ok := redirects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+	}))
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	return server, u.Host
+}
+
+func newChainService(mockStorage *MockRedisStorage, config services.ChainResolverConfig) *services.URLService {
+	return services.NewURLService(mockStorage, nil, "sho.rt", "443",
+		services.WithChainResolver(services.NewChainResolver(config)))
+}
+
+func TestURLService_CreateShortURL_UnwrapsOwnShortLink(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := newChainService(mockStorage, services.ChainResolverConfig{OwnHosts: []string{"sho.rt"}})
+
+	mockStorage.On("GetURL", mock.Anything, "abc123").Return("https://example.com/article", nil)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), "https://example.com/article").Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://sho.rt/abc123"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://sho.rt/abc123", response.OriginalURL)
+	assert.Equal(t, "https://example.com/article", response.CanonicalURL)
+}
+
+func TestURLService_CreateShortURL_UnknownOwnShortLink(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	service := newChainService(mockStorage, services.ChainResolverConfig{OwnHosts: []string{"sho.rt"}})
+
+	mockStorage.On("GetURL", mock.Anything, "missing").Return("", assert.AnError)
+
+	_, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://sho.rt/missing"})
+
+	assert.ErrorIs(t, err, services.ErrUnknownShortLink)
+}
+
+func TestChainResolver_FollowsShorteners(t *testing.T) {
+	server, host := newFakeShortener(t, map[string]string{
+		"/a":       "/b",
+		"/b":       "https://example.com/final",
+		"/no-head": "https://example.com/from-get",
+	})
+	resolver := services.NewChainResolver(services.ChainResolverConfig{
+		Shorteners: []string{host},
+		Follow:     true,
+		Client:     server.Client(),
+	})
+
+	final, err := resolver.Resolve(context.Background(), server.URL+"/a", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/final", final)
+
+	final, err = resolver.Resolve(context.Background(), server.URL+"/no-head", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/from-get", final)
+
+	final, err = resolver.Resolve(context.Background(), server.URL+"/landing", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/landing", final, "Non-redirecting links are kept")
+}
+
+func TestChainResolver_DoesNotFollowWhenDisabled(t *testing.T) {
+	server, host := newFakeShortener(t, map[string]string{"/a": "https://example.com/final"})
+	resolver := services.NewChainResolver(services.ChainResolverConfig{Shorteners: []string{host}, Client: server.Client()})
+
+	final, err := resolver.Resolve(context.Background(), server.URL+"/a", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/a", final)
+}
+
+func TestChainResolver_RejectsLoops(t *testing.T) {
+	server, host := newFakeShortener(t, map[string]string{})
+	resolver := services.NewChainResolver(services.ChainResolverConfig{
+		OwnHosts:   []string{"sho.rt"},
+		Shorteners: []string{host},
+		Follow:     true,
+		Client:     server.Client(),
+	})
+
+	// sho.rt/x -> sho.rt/y -> sho.rt/x
+	links := map[string]string{"x": "https://sho.rt/y", "y": "https://sho.rt/x"}
+	lookup := func(ctx context.Context, code string) (string, error) {
+		return links[code], nil
+	}
+
+	_, err := resolver.Resolve(context.Background(), "https://sho.rt/x", lookup)
+
+	assert.ErrorIs(t, err, services.ErrRedirectLoop)
+}
+
+func TestChainResolver_RejectsLongChains(t *testing.T) {
+	resolver := services.NewChainResolver(services.ChainResolverConfig{OwnHosts: []string{"sho.rt"}, MaxHops: 2})
+
+	links := map[string]string{"a": "https://sho.rt/b", "b": "https://sho.rt/c", "c": "https://sho.rt/d", "d": "https://example.com"}
+	lookup := func(ctx context.Context, code string) (string, error) {
+		return links[code], nil
+	}
+
+	_, err := resolver.Resolve(context.Background(), "https://sho.rt/a", lookup)
+	assert.ErrorIs(t, err, services.ErrChainTooLong)
+
+	final, err := resolver.Resolve(context.Background(), "https://sho.rt/c", lookup)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com", final)
+}
+
+func TestChainResolver_ValidatesFinalDestination(t *testing.T) {
+	server, host := newFakeShortener(t, map[string]string{"/a": "http://169.254.169.254/latest/meta-data"})
+	resolver := services.NewChainResolver(services.ChainResolverConfig{
+		Shorteners: []string{host},
+		Follow:     true,
+		Client:     server.Client(),
+		Checker:    newSSRFValidator(),
+	})
+
+	_, err := resolver.Resolve(context.Background(), server.URL+"/a", nil)
+
+	var validationErr *services.ValidationError
+	require.True(t, errors.As(err, &validationErr), "expected a validation error, got %v", err)
+	assert.Equal(t, services.CodePrivateAddress, validationErr.Code)
+}
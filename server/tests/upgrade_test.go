@@ -0,0 +1,320 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestWebhook returns a webhook server that forwards the events it receives
+func newTestWebhook(t *testing.T) (*httptest.Server, chan services.SlugUpgradeEvent) {
+	t.Helper()
+	events := make(chan services.SlugUpgradeEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event services.SlugUpgradeEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	t.Cleanup(server.Close)
+	return server, events
+}
+
+func waitForEvent(t *testing.T, events chan services.SlugUpgradeEvent) services.SlugUpgradeEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("No webhook received")
+		return services.SlugUpgradeEvent{}
+	}
+}
+
+func TestURLService_AsyncAISlugUpgrade(t *testing.T) {
+	webhook, events := newTestWebhook(t)
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	metadata := NewMockMetadataStore()
+	upgrader := services.NewSlugUpgrader(services.SlugUpgradeConfig{Workers: 1, WebhookURL: webhook.URL, Client: webhook.Client()})
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080",
+		services.WithMetadataStore(metadata),
+		services.WithAsyncAISlugs(upgrader))
+
+	destination := "https://go.dev"
+	mockAI.On("GenerateSlug", mock.Anything, destination).Return("godev", nil)
+	mockStorage.On("GetURL", mock.Anything, "godev").Return("", assert.AnError)
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", nil)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), destination).Return(nil)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "godev", destination).Return(true, nil)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: destination})
+
+	require.NoError(t, err)
+	assert.Equal(t, "hash_based", response.SlugType)
+	assert.Equal(t, services.AISlugPending, response.AISlugStatus)
+	mockAI.AssertNotCalled(t, "GenerateSlug", mock.Anything, mock.Anything)
+
+	pending, err := service.GetLinkMetadata(context.Background(), response.ShortCode)
+	require.NoError(t, err)
+	assert.Equal(t, services.AISlugPending, pending.AISlugStatus)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.RunSlugUpgrades(ctx)
+
+	event := waitForEvent(t, events)
+	assert.Equal(t, services.SlugUpgradeEvent{
+		ShortCode:   response.ShortCode,
+		OriginalURL: destination,
+		Status:      services.AISlugReady,
+		AISlug:      "godev",
+		VanityURL:   "http://localhost:8080/godev",
+	}, event)
+	mockStorage.AssertCalled(t, "StoreURLIfAbsent", mock.Anything, "godev", destination)
+
+	ready, err := service.GetLinkMetadata(context.Background(), response.ShortCode)
+	require.NoError(t, err)
+	assert.Equal(t, services.AISlugReady, ready.AISlugStatus)
+	assert.Equal(t, "godev", ready.AISlug)
+	assert.Equal(t, "http://localhost:8080/godev", ready.VanityURL)
+
+	var alias services.LinkMetadata
+	require.NoError(t, json.Unmarshal(metadata.metadata["godev"], &alias))
+	assert.Equal(t, response.ShortCode, alias.AliasOf)
+}
+
+func TestURLService_AsyncAISlugUpgrade_Failure(t *testing.T) {
+	webhook, events := newTestWebhook(t)
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	metadata := NewMockMetadataStore()
+	upgrader := services.NewSlugUpgrader(services.SlugUpgradeConfig{Workers: 1, WebhookURL: webhook.URL, Client: webhook.Client()})
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080",
+		services.WithMetadataStore(metadata),
+		services.WithAsyncAISlugs(upgrader))
+
+	destination := "https://go.dev"
+	mockAI.On("GenerateSlug", mock.Anything, destination).Return("", assert.AnError)
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", nil)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), destination).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: destination})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.RunSlugUpgrades(ctx)
+
+	event := waitForEvent(t, events)
+	assert.Equal(t, services.AISlugFailed, event.Status)
+	assert.Empty(t, event.VanityURL)
+
+	var stored services.LinkMetadata
+	require.NoError(t, json.Unmarshal(metadata.metadata[response.ShortCode], &stored))
+	assert.Equal(t, services.AISlugFailed, stored.AISlugStatus)
+}
+
+func TestURLService_AsyncAISlugUpgrade_QueueFull(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	upgrader := services.NewSlugUpgrader(services.SlugUpgradeConfig{QueueSize: 1})
+	service := services.NewURLService(mockStorage, new(MockAISlugService), "localhost", "8080",
+		services.WithMetadataStore(NewMockMetadataStore()),
+		services.WithAsyncAISlugs(upgrader))
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(nil)
+
+	first, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://go.dev"})
+	require.NoError(t, err)
+	second, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://golang.org"})
+	require.NoError(t, err)
+
+	assert.Equal(t, services.AISlugPending, first.AISlugStatus)
+	assert.Equal(t, services.AISlugFailed, second.AISlugStatus, "Creation never waits for queue space")
+}
+
+func TestURLService_AsyncAISlugUpgrade_SlugClaimedConcurrently(t *testing.T) {
+	webhook, events := newTestWebhook(t)
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	metadata := NewMockMetadataStore()
+	upgrader := services.NewSlugUpgrader(services.SlugUpgradeConfig{Workers: 1, WebhookURL: webhook.URL, Client: webhook.Client()})
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080",
+		services.WithMetadataStore(metadata),
+		services.WithAsyncAISlugs(upgrader))
+
+	destination := "https://go.dev"
+	mockAI.On("GenerateSlug", mock.Anything, destination).Return("godev", nil)
+	mockStorage.On("GetURL", mock.Anything, "godev").Return("", assert.AnError)
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", nil)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), destination).Return(nil)
+	// Another link stores the slug after it was found free
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "godev", destination).Return(false, nil)
+
+	_, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: destination})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.RunSlugUpgrades(ctx)
+
+	event := waitForEvent(t, events)
+	assert.Equal(t, services.AISlugFailed, event.Status)
+	assert.NotContains(t, metadata.metadata, "godev")
+}
+
+func TestURLService_AsyncAISlugUpgrade_LinkDeletedDuringUpgrade(t *testing.T) {
+	webhook, events := newTestWebhook(t)
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	metadata := NewMockMetadataStore()
+	upgrader := services.NewSlugUpgrader(services.SlugUpgradeConfig{Workers: 1, WebhookURL: webhook.URL, Client: webhook.Client()})
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080",
+		services.WithMetadataStore(metadata),
+		services.WithAsyncAISlugs(upgrader))
+
+	destination := "https://go.dev"
+	mockStorage.On("GetURL", mock.Anything, "godev").Return("", assert.AnError)
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", nil)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), destination).Return(nil)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "godev", destination).Return(true, nil)
+	mockStorage.On("DeleteURL", mock.Anything, mock.Anything).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: destination})
+	require.NoError(t, err)
+
+	// The link is deleted while the AI service is still working on its slug
+	mockAI.On("GenerateSlug", mock.Anything, destination).Return("godev", nil).Run(func(mock.Arguments) {
+		require.NoError(t, service.DeleteURL(context.Background(), response.ShortCode))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.RunSlugUpgrades(ctx)
+
+	event := waitForEvent(t, events)
+	assert.Equal(t, services.AISlugFailed, event.Status)
+	mockStorage.AssertCalled(t, "DeleteURL", mock.Anything, "godev")
+	assert.NotContains(t, metadata.metadata, "godev")
+}
+
+func TestURLService_AsyncAISlugUpgrade_CopiesThreatFlag(t *testing.T) {
+	webhook, events := newTestWebhook(t)
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	flags := NewMockThreatStore()
+	upgrader := services.NewSlugUpgrader(services.SlugUpgradeConfig{Workers: 1, WebhookURL: webhook.URL, Client: webhook.Client()})
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080",
+		services.WithThreatScanner(newTestFeedScanner(t), flags, services.ThreatActionWarn),
+		services.WithAsyncAISlugs(upgrader))
+
+	destination := "https://phish.example.com/account"
+	mockAI.On("GenerateSlug", mock.Anything, destination).Return("account", nil)
+	mockStorage.On("GetURL", mock.Anything, "account").Return("", assert.AnError)
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", nil)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), destination).Return(nil)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "account", destination).Return(true, nil)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: destination})
+	require.NoError(t, err)
+	require.Contains(t, flags.flags, response.ShortCode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.RunSlugUpgrades(ctx)
+
+	event := waitForEvent(t, events)
+	require.Equal(t, services.AISlugReady, event.Status)
+	assert.Equal(t, flags.flags[response.ShortCode], flags.flags["account"], "The AI slug should warn like the original")
+}
+
+func TestURLService_AsyncAISlugUpgrade_ReshortenKeepsUpgrade(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	upgrader := services.NewSlugUpgrader(services.SlugUpgradeConfig{QueueSize: 1})
+	service := services.NewURLService(mockStorage, new(MockAISlugService), "localhost", "8080",
+		services.WithMetadataStore(NewMockMetadataStore()),
+		services.WithAsyncAISlugs(upgrader))
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(nil)
+
+	first, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://go.dev"})
+	require.NoError(t, err)
+	second, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://go.dev"})
+	require.NoError(t, err)
+
+	assert.Equal(t, first.ShortCode, second.ShortCode)
+	assert.Equal(t, services.AISlugPending, second.AISlugStatus, "The first upgrade should be kept, not queued again")
+
+	third, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://golang.org"})
+	require.NoError(t, err)
+	assert.Equal(t, services.AISlugFailed, third.AISlugStatus, "Only one upgrade should be queued")
+}
+
+func TestURLService_AsyncAISlugUpgrade_ShutdownFailsQueuedUpgrades(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	mockAI := new(MockAISlugService)
+	metadata := NewMockMetadataStore()
+	upgrader := services.NewSlugUpgrader(services.SlugUpgradeConfig{Workers: 1})
+	service := services.NewURLService(mockStorage, mockAI, "localhost", "8080",
+		services.WithMetadataStore(metadata),
+		services.WithAsyncAISlugs(upgrader))
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", context.Canceled)
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("", nil)
+	mockStorage.On("StoreURL", mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(nil)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://go.dev"})
+	require.NoError(t, err)
+	require.Equal(t, services.AISlugPending, response.AISlugStatus)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	service.RunSlugUpgrades(ctx)
+
+	var stored services.LinkMetadata
+	require.NoError(t, json.Unmarshal(metadata.metadata[response.ShortCode], &stored))
+	assert.Equal(t, services.AISlugFailed, stored.AISlugStatus)
+
+	// Links created after the workers stopped are not left pending either
+	late, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://go.dev/doc"})
+	require.NoError(t, err)
+	assert.Equal(t, services.AISlugFailed, late.AISlugStatus)
+	require.NoError(t, json.Unmarshal(metadata.metadata[late.ShortCode], &stored))
+	assert.Equal(t, services.AISlugFailed, stored.AISlugStatus)
+}
+
+func TestURLService_AsyncAISlugUpgrade_SkipsCustomAliases(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	upgrader := services.NewSlugUpgrader(services.SlugUpgradeConfig{})
+	service := services.NewURLService(mockStorage, new(MockAISlugService), "localhost", "8080",
+		services.WithAsyncAISlugs(upgrader))
+	mockStorage.On("GetURL", mock.Anything, "mine").Return("", assert.AnError)
+	mockStorage.On("StoreURLIfAbsent", mock.Anything, "mine", "https://go.dev").Return(true, nil)
+
+	response, err := service.CreateShortURL(context.Background(), services.URLRequest{URL: "https://go.dev", Alias: "mine"})
+
+	require.NoError(t, err)
+	assert.Empty(t, response.AISlugStatus)
+}
+
+func TestURLService_DeleteURL_RemovesAISlug(t *testing.T) {
+	mockStorage := NewMockRedisStorage()
+	metadata := NewMockMetadataStore()
+	service := services.NewURLService(mockStorage, nil, "localhost", "8080", services.WithMetadataStore(metadata))
+
+	metadata.metadata["abc123"] = []byte(`{"short_code":"abc123","ai_slug":"godev","ai_slug_status":"ready"}`)
+	metadata.metadata["godev"] = []byte(`{"short_code":"godev","alias_of":"abc123"}`)
+	mockStorage.On("GetURL", mock.Anything, mock.Anything).Return("https://go.dev", nil)
+	mockStorage.On("DeleteURL", mock.Anything, mock.Anything).Return(nil)
+
+	require.NoError(t, service.DeleteURL(context.Background(), "abc123"))
+
+	mockStorage.AssertCalled(t, "DeleteURL", mock.Anything, "abc123")
+	mockStorage.AssertCalled(t, "DeleteURL", mock.Anything, "godev")
+	assert.Empty(t, metadata.metadata)
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-url-shortner/handlers"
+	"go-url-shortner/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newMeteredAI(t *testing.T, fake *FakeLLMServer, meter *services.UsageMeter) *services.AISlugService {
+	t.Helper()
+	provider, err := services.NewLLMProvider(services.LLMConfig{BaseURL: fake.URL})
+	require.NoError(t, err)
+	return services.NewAISlugServiceWithProvider(meter.Wrap(provider))
+}
+
+func TestUsageMeter_RecordsTokensPerTenant(t *testing.T) {
+	fake := NewFakeLLMServer(t, "godev")
+	meter := services.NewUsageMeter(NewMockAIUsageStore(), services.UsageConfig{PromptPrice: 0.5, CompletionPrice: 1.5})
+	service := newMeteredAI(t, fake, meter)
+
+	_, err := service.GenerateSlug(services.ContextWithTenant(context.Background(), "team-a"), "https://go.dev")
+	require.NoError(t, err)
+	_, err = service.GenerateSlug(services.ContextWithTenant(context.Background(), "team-a"), "https://go.dev/doc")
+	require.NoError(t, err)
+	_, err = service.GenerateSlug(context.Background(), "https://go.dev/blog")
+	require.NoError(t, err)
+
+	report, err := meter.Usage(context.Background(), time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Now().UTC().Format("2006-01-02"), report.Date)
+	assert.Equal(t, int64(2*fakePromptTokens), report.Tenants["team-a"].PromptTokens)
+	assert.Equal(t, int64(2*fakeCompletionTokens), report.Tenants["team-a"].CompletionTokens)
+	assert.Equal(t, int64(fakePromptTokens+fakeCompletionTokens), report.Tenants[services.AnonymousTenant].TotalTokens)
+	assert.Equal(t, int64(3*(fakePromptTokens+fakeCompletionTokens)), report.Total.TotalTokens)
+	assert.InDelta(t, (3*fakePromptTokens*0.5+3*fakeCompletionTokens*1.5)/1e6, report.Total.EstimatedCost, 1e-12)
+}
+
+func TestUsageMeter_DailyBudget(t *testing.T) {
+	fake := NewFakeLLMServer(t, "godev")
+	meter := services.NewUsageMeter(NewMockAIUsageStore(), services.UsageConfig{DailyBudget: fakePromptTokens + fakeCompletionTokens})
+	service := newMeteredAI(t, fake, meter)
+
+	_, err := service.GenerateSlug(context.Background(), "https://go.dev")
+	require.NoError(t, err)
+	_, err = service.GenerateSlug(services.ContextWithTenant(context.Background(), "team-b"), "https://go.dev/doc")
+
+	assert.ErrorIs(t, err, services.ErrAIBudgetExceeded)
+	assert.Len(t, fake.Requests, 1, "The provider is not called once the budget is spent")
+}
+
+func TestUsageMeter_TenantDailyBudget(t *testing.T) {
+	fake := NewFakeLLMServer(t, "godev")
+	meter := services.NewUsageMeter(NewMockAIUsageStore(), services.UsageConfig{TenantDailyBudget: fakePromptTokens + fakeCompletionTokens})
+	service := newMeteredAI(t, fake, meter)
+	teamA := services.ContextWithTenant(context.Background(), "team-a")
+
+	_, err := service.GenerateSlug(teamA, "https://go.dev")
+	require.NoError(t, err)
+	_, err = service.GenerateSlug(teamA, "https://go.dev/doc")
+	assert.ErrorIs(t, err, services.ErrAIBudgetExceeded)
+
+	_, err = service.GenerateSlug(services.ContextWithTenant(context.Background(), "team-b"), "https://go.dev/doc")
+	assert.NoError(t, err, "Other tenants keep their own budget")
+}
+
+func TestResilientAISlugService_BudgetIsNotAFailure(t *testing.T) {
+	mockAI := new(MockAISlugService)
+	mockAI.On("GenerateSlug", mock.Anything, mock.Anything).Return("", services.ErrAIBudgetExceeded)
+	service := newTestResilientAI(mockAI, services.ResilienceConfig{MaxRetries: 2, FailureThreshold: 1})
+
+	_, err := service.GenerateSlug(context.Background(), "https://go.dev")
+
+	assert.ErrorIs(t, err, services.ErrAIBudgetExceeded)
+	mockAI.AssertNumberOfCalls(t, "GenerateSlug", 1)
+	assert.Equal(t, services.CircuitClosed, service.State())
+}
+
+func TestGetAIUsage_Handler(t *testing.T) {
+	store := NewMockAIUsageStore()
+	store.AddAIUsage(context.Background(), "2025-06-01", "team-a", 100, 10)
+	meter := services.NewUsageMeter(store, services.UsageConfig{DailyBudget: 1000})
+
+	router := setupTestRouter()
+	router.GET("/api/admin/ai/usage", handlers.NewURLHandler(new(MockURLService), handlers.WithAIUsage(meter)).GetAIUsage)
+	router.GET("/disabled", handlers.NewURLHandler(new(MockURLService)).GetAIUsage)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/ai/usage?date=2025-06-01", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report services.AIUsageReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, int64(110), report.Total.TotalTokens)
+	assert.Equal(t, int64(100), report.Tenants["team-a"].PromptTokens)
+	assert.Equal(t, int64(1000), report.DailyBudget)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/ai/usage?date=June", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), handlers.CodeInvalidDate)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/disabled", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUsageMeter_ReservationBlocksConcurrentCalls(t *testing.T) {
+	store := NewMockAIUsageStore()
+	fake := NewFakeLLMServer(t, "godev")
+	meter := services.NewUsageMeter(store, services.UsageConfig{DailyBudget: 100, CompletionReserve: 100})
+	service := newMeteredAI(t, fake, meter)
+
+	// Another instance holds a reservation for a call still in flight
+	day := time.Now().UTC().Format("2006-01-02")
+	_, err := store.ReserveAIUsage(context.Background(), day, "team-a", 100)
+	require.NoError(t, err)
+
+	_, err = service.GenerateSlug(context.Background(), "https://go.dev")
+	assert.ErrorIs(t, err, services.ErrAIBudgetExceeded)
+	assert.Empty(t, fake.Requests)
+
+	_, err = store.ReserveAIUsage(context.Background(), day, "team-a", -100)
+	require.NoError(t, err)
+	_, err = service.GenerateSlug(context.Background(), "https://go.dev")
+	require.NoError(t, err)
+
+	counters, _ := store.GetAIUsage(context.Background(), day)
+	assert.Zero(t, counters[services.AnonymousTenant+":reserved"], "Finished calls give their reservation back")
+	assert.Equal(t, int64(fakePromptTokens), counters[services.AnonymousTenant+":prompt"])
+}
+
+func TestUsageConfig_Validate(t *testing.T) {
+	assert.NoError(t, services.UsageConfig{}.Validate())
+	assert.NoError(t, services.UsageConfig{DailyBudget: 1000}.Validate())
+	assert.NoError(t, services.UsageConfig{DailyBudget: 1000, TenantDailyBudget: 100}.Validate())
+	assert.Error(t, services.UsageConfig{TenantDailyBudget: 100}.Validate(), "New API keys would bypass a per-tenant budget alone")
+	assert.Error(t, services.UsageConfig{DailyBudget: -1}.Validate())
+}
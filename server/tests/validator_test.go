@@ -116,6 +116,20 @@ func TestURLValidator_NormalizeURL_ValidURLs(t *testing.T) {
 	}
 }
 
+func TestURLValidator_UppercaseScheme(t *testing.T) {
+	validator := services.NewURLValidator()
+	rules, err := services.ParseCanonicalRules([]string{"lowercase", "default_port"}, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, validator.ValidateURL("HTTPS://Example.com:443/a"))
+
+	normalized, err := validator.NormalizeURL("HTTPS://Example.com:443/a")
+	assert.NoError(t, err)
+	canonical, err := services.Canonicalize(normalized, rules)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", canonical, "The scheme should not be added twice")
+}
+
 func TestURLValidator_NormalizeURL_InvalidURLs(t *testing.T) {
 	validator := services.NewURLValidator()
 
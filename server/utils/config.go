@@ -3,15 +3,106 @@ package utils
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	RedisAddr     string
-	RedisPassword string
-	RedisDB       int
-	ServerHost    string
-	ServerPort    string
-	OpenAIAPIKey  string
+	RedisAddr            string
+	RedisPassword        string
+	RedisDB              int
+	ServerHost           string
+	ServerPort           string
+	OpenAIAPIKey         string
+	UnicodeSlugs         bool
+	CaseInsensitiveCodes bool
+	AdminToken           string
+
+	SSRFProtection         bool
+	InternalDomainSuffixes []string
+
+	PolicyRulesFile      string
+	PolicyRulesSource    string
+	PolicyReloadInterval time.Duration
+
+	TombstonesEnabled bool
+	CodeQuarantine    time.Duration
+
+	CodePoolEnabled        bool
+	CodePoolMode           string
+	CodePoolLowWatermark   int
+	CodePoolTargetSize     int
+	CodePoolCodeLength     int
+	CodePoolRefillInterval time.Duration
+
+	ThreatFeedHosts        []string
+	ThreatFeedURLhaus      []string
+	ThreatFeedHashPrefixes []string
+	ThreatAction           string
+	ThreatRescanInterval   time.Duration
+
+	CanonicalRules []string
+	TrackingParams []string
+
+	HomographAction string
+
+	AllowedSchemes []string
+	HandoffSchemes []string
+
+	ErrorDocsURL string
+
+	CredentialsPolicy string
+
+	BaseURL          string
+	KnownShorteners  []string
+	FollowShorteners bool
+	MaxRedirectHops  int
+	ShortenerTimeout time.Duration
+
+	ReachabilityCheck        string
+	ReachabilityTimeout      time.Duration
+	ReachabilityMaxRedirects int
+	ReachabilityMaxBytes     int
+
+	AIProvider    string
+	AIAPIKey      string
+	AIBaseURL     string
+	AIModel       string
+	AITemperature float32
+	AIMaxTokens   int
+	AICandidates  int
+
+	AIDescribeMaxTokens int
+
+	AIPromptTemplate        string
+	AISlugLocale            string
+	AITenantPromptTemplates []string
+	AITenantSlugLocales     []string
+
+	AITimeout             time.Duration
+	AIMaxRetries          int
+	AIRetryBackoff        time.Duration
+	AIBreakerThreshold    int
+	AIBreakerOpenDuration time.Duration
+
+	AISlugMode       string
+	AIUpgradeWorkers int
+	AIUpgradeQueue   int
+	AIUpgradeWebhook string
+
+	AICacheEnabled     bool
+	AICacheTTL         time.Duration
+	AICacheDomainHints int
+
+	AIDailyTokenBudget       int
+	AITenantDailyTokenBudget int
+	AIPromptTokenPrice       float32
+	AICompletionTokenPrice   float32
+
+	PageMetadataFetch     bool
+	PageMetadataTimeout   time.Duration
+	PageMetadataMaxBytes  int
+	PageMetadataUserAgent string
 }
 
 func Load() *Config {
@@ -22,12 +113,101 @@ func Load() *Config {
 	}
 
 	return &Config{
-		RedisAddr:     getEnv("REDIS_ADDR", "localhost:6379"),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		RedisDB:       redisDB,
-		ServerHost:    getEnv("SERVER_HOST", "localhost"),
-		ServerPort:    getEnv("SERVER_PORT", "8080"),
-		OpenAIAPIKey:  getEnv("OPENAI_API_KEY", ""),
+		RedisAddr:            getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:        getEnv("REDIS_PASSWORD", ""),
+		RedisDB:              redisDB,
+		ServerHost:           getEnv("SERVER_HOST", "localhost"),
+		ServerPort:           getEnv("SERVER_PORT", "8080"),
+		OpenAIAPIKey:         getEnv("OPENAI_API_KEY", ""),
+		UnicodeSlugs:         getEnvBool("UNICODE_SLUGS", false),
+		CaseInsensitiveCodes: getEnvBool("CASE_INSENSITIVE_CODES", false),
+		AdminToken:           getEnv("ADMIN_TOKEN", ""),
+
+		SSRFProtection:         getEnvBool("SSRF_PROTECTION", true),
+		InternalDomainSuffixes: getEnvList("INTERNAL_DOMAIN_SUFFIXES", []string{"localhost", "local", "internal", "localdomain", "home.arpa"}),
+
+		PolicyRulesFile:      getEnv("POLICY_RULES_FILE", ""),
+		PolicyRulesSource:    getEnv("POLICY_RULES_SOURCE", "file"),
+		PolicyReloadInterval: getEnvDuration("POLICY_RELOAD_INTERVAL", 30*time.Second),
+
+		TombstonesEnabled: getEnvBool("TOMBSTONES_ENABLED", true),
+		CodeQuarantine:    getEnvDuration("CODE_QUARANTINE_PERIOD", 90*24*time.Hour),
+
+		CodePoolEnabled:        getEnvBool("CODE_POOL_ENABLED", false),
+		CodePoolMode:           getEnv("CODE_POOL_MODE", "random"),
+		CodePoolLowWatermark:   getEnvInt("CODE_POOL_LOW_WATERMARK", 100),
+		CodePoolTargetSize:     getEnvInt("CODE_POOL_TARGET_SIZE", 1000),
+		CodePoolCodeLength:     getEnvInt("CODE_POOL_CODE_LENGTH", 7),
+		CodePoolRefillInterval: getEnvDuration("CODE_POOL_REFILL_INTERVAL", 30*time.Second),
+
+		ThreatFeedHosts:        getEnvList("THREAT_FEED_HOSTS", nil),
+		ThreatFeedURLhaus:      getEnvList("THREAT_FEED_URLHAUS", nil),
+		ThreatFeedHashPrefixes: getEnvList("THREAT_FEED_HASH_PREFIXES", nil),
+		ThreatAction:           getEnv("THREAT_ACTION", "block"),
+		ThreatRescanInterval:   getEnvDuration("THREAT_RESCAN_INTERVAL", time.Hour),
+
+		CanonicalRules: getEnvList("URL_CANONICAL_RULES", []string{"lowercase", "default_port", "dot_segments", "percent_encoding"}),
+		TrackingParams: getEnvList("URL_TRACKING_PARAMS", []string{"utm_*", "fbclid"}),
+
+		HomographAction: getEnv("IDN_HOMOGRAPH_ACTION", "warn"),
+
+		AllowedSchemes: getEnvList("ALLOWED_SCHEMES", nil),
+		HandoffSchemes: getEnvList("HANDOFF_SCHEMES", nil),
+
+		ErrorDocsURL: getEnv("ERROR_DOCS_URL", ""),
+
+		CredentialsPolicy: getEnv("URL_CREDENTIALS_POLICY", "reject"),
+
+		BaseURL:          getEnv("BASE_URL", ""),
+		KnownShorteners:  getEnvList("KNOWN_SHORTENERS", []string{"bit.ly", "tinyurl.com", "t.co", "goo.gl", "ow.ly", "is.gd", "buff.ly", "rebrand.ly", "cutt.ly", "shorturl.at"}),
+		FollowShorteners: getEnvBool("FOLLOW_SHORTENERS", false),
+		MaxRedirectHops:  getEnvInt("MAX_REDIRECT_HOPS", 5),
+		ShortenerTimeout: getEnvDuration("SHORTENER_TIMEOUT", 5*time.Second),
+
+		ReachabilityCheck:        getEnv("REACHABILITY_CHECK", "off"),
+		ReachabilityTimeout:      getEnvDuration("REACHABILITY_TIMEOUT", 5*time.Second),
+		ReachabilityMaxRedirects: getEnvInt("REACHABILITY_MAX_REDIRECTS", 5),
+		ReachabilityMaxBytes:     getEnvInt("REACHABILITY_MAX_BYTES", 1<<20),
+
+		AIProvider:    getEnv("AI_PROVIDER", "openai"),
+		AIAPIKey:      getEnv("AI_API_KEY", getEnv("OPENAI_API_KEY", "")),
+		AIBaseURL:     getEnv("AI_BASE_URL", ""),
+		AIModel:       getEnv("AI_MODEL", ""),
+		AITemperature: getEnvFloat("AI_TEMPERATURE", 0.7),
+		AIMaxTokens:   getEnvInt("AI_MAX_TOKENS", 128),
+		AICandidates:  getEnvInt("AI_SLUG_CANDIDATES", 3),
+
+		AIDescribeMaxTokens: getEnvInt("AI_DESCRIBE_MAX_TOKENS", 400),
+
+		AIPromptTemplate:        getEnv("AI_PROMPT_TEMPLATE", ""),
+		AISlugLocale:            getEnv("AI_SLUG_LOCALE", "en"),
+		AITenantPromptTemplates: getEnvList("AI_TENANT_PROMPT_TEMPLATES", nil),
+		AITenantSlugLocales:     getEnvList("AI_TENANT_SLUG_LOCALES", nil),
+
+		AITimeout:             getEnvDuration("AI_TIMEOUT", 5*time.Second),
+		AIMaxRetries:          getEnvInt("AI_MAX_RETRIES", 2),
+		AIRetryBackoff:        getEnvDuration("AI_RETRY_BACKOFF", 200*time.Millisecond),
+		AIBreakerThreshold:    getEnvInt("AI_BREAKER_THRESHOLD", 5),
+		AIBreakerOpenDuration: getEnvDuration("AI_BREAKER_OPEN_DURATION", 30*time.Second),
+
+		AISlugMode:       getEnv("AI_SLUG_MODE", "sync"),
+		AIUpgradeWorkers: getEnvInt("AI_UPGRADE_WORKERS", 4),
+		AIUpgradeQueue:   getEnvInt("AI_UPGRADE_QUEUE_SIZE", 1000),
+		AIUpgradeWebhook: getEnv("AI_UPGRADE_WEBHOOK_URL", ""),
+
+		AICacheEnabled:     getEnvBool("AI_CACHE_ENABLED", true),
+		AICacheTTL:         getEnvDuration("AI_CACHE_TTL", 7*24*time.Hour),
+		AICacheDomainHints: getEnvInt("AI_CACHE_DOMAIN_HINTS", 10),
+
+		AIDailyTokenBudget:       getEnvInt("AI_DAILY_TOKEN_BUDGET", 0),
+		AITenantDailyTokenBudget: getEnvInt("AI_TENANT_DAILY_TOKEN_BUDGET", 0),
+		AIPromptTokenPrice:       getEnvFloat("AI_PROMPT_TOKEN_PRICE", 0),
+		AICompletionTokenPrice:   getEnvFloat("AI_COMPLETION_TOKEN_PRICE", 0),
+
+		PageMetadataFetch:     getEnvBool("PAGE_METADATA_FETCH", false),
+		PageMetadataTimeout:   getEnvDuration("PAGE_METADATA_TIMEOUT", 3*time.Second),
+		PageMetadataMaxBytes:  getEnvInt("PAGE_METADATA_MAX_BYTES", 512<<10),
+		PageMetadataUserAgent: getEnv("PAGE_METADATA_USER_AGENT", "go-url-shortner-preview/1.0"),
 	}
 }
 
@@ -37,3 +217,46 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value >= 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float32) float32 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 32); err == nil && value >= 0 {
+		return float32(value)
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,31 +1,53 @@
 package utils
 
 import (
+	"crypto/rand"
 	"crypto/sha1"
 	"encoding/binary"
 	"strings"
 )
 
-const base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+const (
+	base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	base36Chars = "0123456789abcdefghijklmnopqrstuvwxyz"
+)
 
 // ShortHash generates a hash-based short code from input string
 func ShortHash(input string) string {
+	return Base62Encode(hashNumber(input))
+}
+
+// ShortHashFolded generates a hash-based short code that only uses lowercase
+// letters and digits, for deployments where codes are case-insensitive
+func ShortHashFolded(input string) string {
+	return Base36Encode(hashNumber(input))
+}
+
+func hashNumber(input string) uint64 {
 	hash := sha1.Sum([]byte(input))
-	num := binary.BigEndian.Uint64(hash[:8])
-	return Base62Encode(num)
+	return binary.BigEndian.Uint64(hash[:8])
 }
 
 // Base62Encode encodes a number to base62
 func Base62Encode(num uint64) string {
+	return encode(num, base62Chars)
+}
+
+// Base36Encode encodes a number to lowercase base36
+func Base36Encode(num uint64) string {
+	return encode(num, base36Chars)
+}
+
+func encode(num uint64, alphabet string) string {
 	if num == 0 {
 		return "0"
 	}
 
 	var result strings.Builder
-	base := uint64(len(base62Chars))
+	base := uint64(len(alphabet))
 
 	for num > 0 {
-		result.WriteByte(base62Chars[num%base])
+		result.WriteByte(alphabet[num%base])
 		num /= base
 	}
 
@@ -38,3 +60,33 @@ func Base62Encode(num uint64) string {
 
 	return string(runes)
 }
+
+// RandomCode returns a random code of the given length drawn from the base62
+// alphabet, or from lowercase base36 when lowercase is set
+func RandomCode(length int, lowercase bool) (string, error) {
+	alphabet := base62Chars
+	if lowercase {
+		alphabet = base36Chars
+	}
+
+	buf := make([]byte, length)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	// Reject bytes beyond the largest multiple of the alphabet size to avoid modulo bias
+	limit := byte(256 - 256%len(alphabet))
+	code := make([]byte, 0, length)
+	for len(code) < length {
+		for _, b := range buf {
+			if b < limit && len(code) < length {
+				code = append(code, alphabet[int(b)%len(alphabet)])
+			}
+		}
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+	}
+
+	return string(code), nil
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrorResponse is the JSON body of every API error.
+type ErrorResponse struct {
+	// Error is a human-readable message that may be shown to users.
+	Error string `json:"error"`
+	// Code is a stable machine-readable identifier for the error.
+	Code string `json:"code"`
+	// Field names the request field that caused the error, if any.
+	Field string `json:"field,omitempty"`
+	// DocsURL links to the documentation of Code.
+	DocsURL string `json:"docs_url,omitempty"`
+}
+
+// ValidateErrorDocsURL returns an error unless docsURL is empty or an
+// absolute http(s) URL that clients can follow.
+func ValidateErrorDocsURL(docsURL string) error {
+	if docsURL == "" {
+		return nil
+	}
+	u, err := url.Parse(docsURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http or https URL", docsURL)
+	}
+	return nil
+}
+
+// NewErrorResponse builds the envelope of an error. docsURL is the page
+// documenting error codes; the response links to the anchor of code on that
+// page, and an empty docsURL omits the link.
+func NewErrorResponse(code, field, message, docsURL string) ErrorResponse {
+	response := ErrorResponse{Error: message, Code: code, Field: field}
+	if docsURL != "" {
+		response.DocsURL = strings.TrimSuffix(docsURL, "#") + "#" + code
+	}
+	return response
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeNFC returns s in Unicode Normalization Form C, so that visually
+// identical strings such as a precomposed "é" and "e" + U+0301 compare equal.
+func NormalizeNFC(s string) string {
+	return norm.NFC.String(s)
+}
+
+// FoldCase applies Unicode case folding to s, so that "Promo", "PROMO" and
+// "promo" all map to the same string.
+func FoldCase(s string) string {
+	return cases.Fold().String(s)
+}
+
+// HasCompatibilityChars reports whether s contains characters that NFKC
+// folds into something else, such as fullwidth letters or ligatures.
+func HasCompatibilityChars(s string) bool {
+	return norm.NFKC.String(s) != norm.NFC.String(s)
+}
+
+// Scripts that may be combined with each other and with Latin, mirroring the
+// "highly restrictive" profile of Unicode TS #39 used by IDNA registries.
+var cjkScripts = map[string]bool{
+	"Han":      true,
+	"Hiragana": true,
+	"Katakana": true,
+	"Hangul":   true,
+	"Bopomofo": true,
+}
+
+var letterScripts = []string{
+	"Latin", "Cyrillic", "Greek", "Armenian", "Georgian", "Hebrew", "Arabic",
+	"Devanagari", "Bengali", "Tamil", "Thai", "Han", "Hiragana", "Katakana",
+	"Hangul", "Bopomofo",
+}
+
+// Scripts returns the set of scripts used by the letters in s. Digits,
+// symbols, emoji, punctuation and letters shared between scripts (such as the
+// Japanese prolonged sound mark) are ignored.
+func Scripts(s string) map[string]bool {
+	scripts := make(map[string]bool)
+	for _, r := range s {
+		if !unicode.IsLetter(r) || unicode.In(r, unicode.Common, unicode.Inherited) {
+			continue
+		}
+		script := "Other"
+		for _, name := range letterScripts {
+			if unicode.Is(unicode.Scripts[name], r) {
+				script = name
+				break
+			}
+		}
+		scripts[script] = true
+	}
+	return scripts
+}
+
+// IsMixedScript reports whether s combines letters from scripts that are not
+// normally written together, e.g. Latin and Cyrillic in "pаypal".
+func IsMixedScript(s string) bool {
+	var other []string
+	for script := range Scripts(s) {
+		if script != "Latin" && !cjkScripts[script] {
+			other = append(other, script)
+		}
+	}
+
+	switch len(other) {
+	case 0:
+		return false
+	case 1:
+		// A single non-CJK script may not be combined with Latin or CJK
+		return len(Scripts(s)) > 1
+	default:
+		return true
+	}
+}
+
+// Cyrillic and Greek letters that are visually indistinguishable from Latin
+// ones, a subset of the Unicode confusables data.
+var confusables = map[rune]rune{
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j',
+	'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'һ': 'h', 'ӏ': 'l',
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O',
+	'Р': 'P', 'С': 'C', 'Т': 'T', 'Х': 'X', 'І': 'I', 'Ј': 'J', 'Ѕ': 'S',
+	'α': 'a', 'ο': 'o', 'ρ': 'p', 'ν': 'v', 'ι': 'i', 'κ': 'k', 'υ': 'u',
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K',
+	'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+}
+
+// ConfusableSkeleton maps lookalike characters in s to their Latin
+// counterparts so that strings can be compared by appearance.
+func ConfusableSkeleton(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if latin, ok := confusables[r]; ok {
+			runes[i] = latin
+		}
+	}
+	return string(runes)
+}
+
+// IsWholeScriptConfusable reports whether s is written in a single non-Latin
+// script but looks entirely like Latin text, e.g. Cyrillic "сор" for "cop".
+func IsWholeScriptConfusable(s string) bool {
+	scripts := Scripts(s)
+	if len(scripts) != 1 || scripts["Latin"] {
+		return false
+	}
+
+	changed := false
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if _, ok := confusables[r]; !ok {
+			return false
+		}
+		changed = true
+	}
+	return changed
+}